@@ -2,12 +2,8 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"errors"
 	"flag"
 	"fmt"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -31,8 +27,16 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	events, errs := netonline.Watch(ctx)
-	wakes := netonline.StartWakeGapWatcher(ctx, *wakeSample, *wakeGap)
+	checker := netonline.DefaultChecker()
+	checker.Timeout = *timeout
+	checker.Require = *require
+
+	var opts []netonline.Option
+	if *validate {
+		opts = append(opts, netonline.WithActiveValidation(checker))
+	}
+	events, errs := netonline.Watch(ctx, opts...)
+	wakes := netonline.StartWakeWatcher(ctx, *wakeSample, *wakeGap)
 
 	// Make local aliases so we can nil-out closed channels and remove cases from select.
 	eventsCh := events
@@ -59,11 +63,10 @@ func main() {
 				eventsCh = nil
 				continue
 			}
-			if ev.Online && *validate {
-				okv, why := connectivityCheck(ctx, *timeout, *require)
-				logEvent(ev.ChangedAt, ev.Online, ev.Cause, &okv, why)
+			if res := ev.CheckResult; res != nil {
+				logEvent(ev.ChangedAt, ev.Online, ev.CauseDetail, &res.OK, res.Reason)
 			} else {
-				logEvent(ev.ChangedAt, ev.Online, ev.Cause, nil, "")
+				logEvent(ev.ChangedAt, ev.Online, ev.CauseDetail, nil, "")
 			}
 
 		case _, ok := <-wakesCh:
@@ -74,8 +77,8 @@ func main() {
 			online, cause, _ := netonline.Evaluate()
 			ts := time.Now()
 			if online && *validate {
-				okv, why := connectivityCheck(ctx, *timeout, *require)
-				logEvent(ts, online, "wake; "+cause, &okv, why)
+				res := netonline.ConnectivityCheck(ctx, netonline.ConnectivityOptions{Timeout: *timeout, Require: *require})
+				logEvent(ts, online, "wake; "+cause, &res.OK, res.Reason)
 			} else {
 				logEvent(ts, online, "wake; "+cause, nil, "")
 			}
@@ -91,85 +94,3 @@ func main() {
 		}
 	}
 }
-
-func connectivityCheck(parent context.Context, timeout time.Duration, require int) (bool, string) {
-	if require <= 0 {
-		require = 1
-	}
-	ctx, cancel := context.WithTimeout(parent, timeout)
-	defer cancel()
-	type probe struct {
-		name string
-		fn   func(context.Context) error
-	}
-	probes := []probe{
-		{name: "dns:example.com", fn: probeDNS("example.com")},
-		{name: "dns:one.one.one.one", fn: probeDNS("one.one.one.one")},
-		{name: "tcp:1.1.1.1:443", fn: probeTCP("1.1.1.1:443")},
-		{name: "tcp:8.8.8.8:443", fn: probeTCP("8.8.8.8:443")},
-		{name: "http:gstatic204", fn: probeHTTP204("http://connectivitycheck.gstatic.com/generate_204")},
-		{name: "http:clients3.google", fn: probeHTTP204("http://clients3.google.com/generate_204")},
-	}
-	res := make(chan error, len(probes))
-	for _, p := range probes {
-		p := p
-		go func() { res <- p.fn(ctx) }()
-	}
-	ok := 0
-	for i := 0; i < len(probes); i++ {
-		select {
-		case <-ctx.Done():
-			if ok >= require {
-				return true, "ok (timeout after quorum)"
-			}
-			return false, "timeout"
-		case err := <-res:
-			if err == nil {
-				ok++
-				if ok >= require {
-					return true, "ok"
-				}
-			}
-		}
-	}
-	if ok >= require {
-		return true, "ok"
-	}
-	return false, "insufficient successes"
-}
-
-func probeDNS(host string) func(context.Context) error {
-	return func(ctx context.Context) error {
-		var r net.Resolver
-		_, err := r.LookupHost(ctx, host)
-		return err
-	}
-}
-func probeTCP(addr string) func(context.Context) error {
-	return func(ctx context.Context) error {
-		d := net.Dialer{Timeout: 1200 * time.Millisecond}
-		c, err := d.DialContext(ctx, "tcp", addr)
-		if err != nil {
-			return err
-		}
-		_ = c.Close()
-		return nil
-	}
-}
-func probeHTTP204(url string) func(context.Context) error {
-	return func(ctx context.Context) error {
-		tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-		cl := &http.Client{Transport: tr, Timeout: 1500 * time.Millisecond}
-		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-		resp, err := cl.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		fmt.Print("HTTP response status: ", resp.Status)
-		if resp.StatusCode == http.StatusNoContent {
-			return nil
-		}
-		return errors.New("non-204")
-	}
-}
@@ -0,0 +1,70 @@
+package netonline
+
+import (
+	"net"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// mockAdapter builds a GetAdaptersAddresses entry with the given operational
+// status and DNS servers.
+func mockAdapter(operStatus uint32, servers ...string) *ipAdapterAddresses {
+	aa := &ipAdapterAddresses{OperStatus: operStatus}
+	next := &aa.FirstDnsServerAddress
+	for _, s := range servers {
+		ip := net.ParseIP(s)
+		sa := new(windows.RawSockaddrAny)
+		if v4 := ip.To4(); v4 != nil {
+			in := (*windows.RawSockaddrInet4)(unsafe.Pointer(sa))
+			in.Family = AF_INET
+			copy(in.Addr[:], v4)
+		} else {
+			in := (*windows.RawSockaddrInet6)(unsafe.Pointer(sa))
+			in.Family = AF_INET6
+			copy(in.Addr[:], ip)
+		}
+		*next = &ipAdapterDNSServerAddress{Address: socketAddress{Sockaddr: sa, Len: int32(unsafe.Sizeof(*sa))}}
+		next = &(*next).Next
+	}
+	return aa
+}
+
+// adapterList links adapters into a list as GetAdaptersAddresses returns it.
+func adapterList(adapters ...*ipAdapterAddresses) *ipAdapterAddresses {
+	for i := 0; i+1 < len(adapters); i++ {
+		adapters[i].Next = adapters[i+1]
+	}
+	if len(adapters) == 0 {
+		return nil
+	}
+	return adapters[0]
+}
+
+func TestAdaptersHaveDNS(t *testing.T) {
+	const up, down = 1, 2
+	tests := []struct {
+		name     string
+		adapters []*ipAdapterAddresses
+		want     bool
+	}{
+		{"no adapters", nil, false},
+		{"up with IPv4 server", []*ipAdapterAddresses{mockAdapter(up, "192.168.1.1")}, true},
+		{"up with IPv6 server", []*ipAdapterAddresses{mockAdapter(up, "2001:db8::53")}, true},
+		{"up without servers", []*ipAdapterAddresses{mockAdapter(up)}, false},
+		{"APIPA only", []*ipAdapterAddresses{mockAdapter(up, "169.254.1.1", "169.254.200.7")}, false},
+		{"unspecified", []*ipAdapterAddresses{mockAdapter(up, "0.0.0.0", "::")}, false},
+		{"APIPA then real", []*ipAdapterAddresses{mockAdapter(up, "169.254.1.1", "10.0.0.53")}, true},
+		{"down with server", []*ipAdapterAddresses{mockAdapter(down, "192.168.1.1")}, false},
+		{"down with server, up with APIPA", []*ipAdapterAddresses{mockAdapter(down, "192.168.1.1"), mockAdapter(up, "169.254.1.1")}, false},
+		{"down with server, up with server", []*ipAdapterAddresses{mockAdapter(down, "192.168.1.1"), mockAdapter(up, "8.8.8.8")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := adaptersHaveDNS(adapterList(tt.adapters...)); got != tt.want {
+				t.Errorf("adaptersHaveDNS = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+//go:build android && cgo
+// +build android,cgo
+
+package netonline
+
+/*
+#include <jni.h>
+#include <stdarg.h>
+#include <stdint.h>
+#include <string.h>
+
+// netonlineFailed clears a pending Java exception, after which no other JNI
+// call would be allowed, and reports whether there was one.
+static int netonlineFailed(JNIEnv *env) {
+	if (!(*env)->ExceptionCheck(env)) return 0;
+	(*env)->ExceptionClear(env);
+	return 1;
+}
+
+// netonlineCall calls the instance method name with signature sig on obj
+// and returns its result as a local reference, or NULL if it threw.
+static jobject netonlineCall(JNIEnv *env, jobject obj, const char *name, const char *sig, ...) {
+	jclass cls = (*env)->GetObjectClass(env, obj);
+	jmethodID m = (*env)->GetMethodID(env, cls, name, sig);
+	(*env)->DeleteLocalRef(env, cls);
+	if (netonlineFailed(env)) return NULL;
+	va_list args;
+	va_start(args, sig);
+	jobject r = (*env)->CallObjectMethodV(env, obj, m, args);
+	va_end(args);
+	if (netonlineFailed(env)) {
+		if (r != NULL) (*env)->DeleteLocalRef(env, r);
+		return NULL;
+	}
+	return r;
+}
+
+// netonlineManager returns ctx's ConnectivityManager as a local reference.
+static jobject netonlineManager(JNIEnv *env, jobject ctx) {
+	jstring name = (*env)->NewStringUTF(env, "connectivity");
+	if (name == NULL || netonlineFailed(env)) return NULL;
+	jobject cm = netonlineCall(env, ctx, "getSystemService", "(Ljava/lang/String;)Ljava/lang/Object;", name);
+	(*env)->DeleteLocalRef(env, name);
+	return cm;
+}
+
+// netonlineIfaceName copies the interface name of the active network to
+// buf, which stays empty if there is none or it cannot be read (API < 23).
+static void netonlineIfaceName(JNIEnv *env, jobject cm, char *buf, int len) {
+	jobject net = netonlineCall(env, cm, "getActiveNetwork", "()Landroid/net/Network;");
+	if (net == NULL) return;
+	jobject lp = netonlineCall(env, cm, "getLinkProperties", "(Landroid/net/Network;)Landroid/net/LinkProperties;", net);
+	(*env)->DeleteLocalRef(env, net);
+	if (lp == NULL) return;
+	jstring s = netonlineCall(env, lp, "getInterfaceName", "()Ljava/lang/String;");
+	(*env)->DeleteLocalRef(env, lp);
+	if (s == NULL) return;
+	const char *c = (*env)->GetStringUTFChars(env, s, NULL);
+	if (c != NULL) {
+		strncpy(buf, c, len - 1);
+		(*env)->ReleaseStringUTFChars(env, s, c);
+	}
+	netonlineFailed(env);
+	(*env)->DeleteLocalRef(env, s);
+}
+
+// netonlineActiveNetwork reads the NetworkInfo of the active network. It
+// returns 1 if there is one, 0 if there is none and -1 if the call failed,
+// e.g. for lack of the ACCESS_NETWORK_STATE permission.
+static int netonlineActiveNetwork(uintptr_t envp, uintptr_t ctxp, int *connected, int *type, char *iface, int len) {
+	JNIEnv *env = (JNIEnv *)envp;
+	jobject cm = netonlineManager(env, (jobject)ctxp);
+	if (cm == NULL) return -1;
+	// Not netonlineCall: a null result means no active network, a
+	// SecurityException that the permission is missing.
+	jclass cls = (*env)->GetObjectClass(env, cm);
+	jmethodID m = (*env)->GetMethodID(env, cls, "getActiveNetworkInfo", "()Landroid/net/NetworkInfo;");
+	(*env)->DeleteLocalRef(env, cls);
+	jobject info = NULL;
+	int threw = netonlineFailed(env);
+	if (!threw) {
+		info = (*env)->CallObjectMethod(env, cm, m);
+		threw = netonlineFailed(env);
+	}
+	if (threw || info == NULL) {
+		(*env)->DeleteLocalRef(env, cm);
+		return threw ? -1 : 0;
+	}
+	int rc = -1;
+	cls = (*env)->GetObjectClass(env, info);
+	jmethodID isConnected = (*env)->GetMethodID(env, cls, "isConnected", "()Z");
+	if (!netonlineFailed(env)) {
+		jmethodID getType = (*env)->GetMethodID(env, cls, "getType", "()I");
+		if (!netonlineFailed(env)) {
+			*connected = (*env)->CallBooleanMethod(env, info, isConnected);
+			if (!netonlineFailed(env)) {
+				*type = (*env)->CallIntMethod(env, info, getType);
+				if (!netonlineFailed(env)) rc = 1;
+			}
+		}
+	}
+	(*env)->DeleteLocalRef(env, cls);
+	(*env)->DeleteLocalRef(env, info);
+	if (rc == 1) netonlineIfaceName(env, cm, iface, len);
+	(*env)->DeleteLocalRef(env, cm);
+	return rc;
+}
+
+// netonlineRegister registers a NetworkCallbackBridge for handle and
+// returns a global reference to it, or NULL if the app does not include
+// the class or registering failed. The class is loaded with ctx's class
+// loader: FindClass on a thread attached from Go only sees system classes.
+static jobject netonlineRegister(uintptr_t envp, uintptr_t ctxp, jlong handle) {
+	JNIEnv *env = (JNIEnv *)envp;
+	jobject ctx = (jobject)ctxp;
+	jobject loader = netonlineCall(env, ctx, "getClassLoader", "()Ljava/lang/ClassLoader;");
+	if (loader == NULL) return NULL;
+	jstring name = (*env)->NewStringUTF(env, "com.example.netonline.NetworkCallbackBridge");
+	jclass cls = NULL;
+	if (name != NULL && !netonlineFailed(env)) {
+		cls = (jclass)netonlineCall(env, loader, "loadClass", "(Ljava/lang/String;)Ljava/lang/Class;", name);
+		(*env)->DeleteLocalRef(env, name);
+	}
+	(*env)->DeleteLocalRef(env, loader);
+	if (cls == NULL) return NULL;
+	jobject cb = NULL;
+	jmethodID reg = (*env)->GetStaticMethodID(env, cls, "register", "(Landroid/content/Context;J)Lcom/example/netonline/NetworkCallbackBridge;");
+	if (!netonlineFailed(env)) {
+		cb = (*env)->CallStaticObjectMethod(env, cls, reg, ctx, handle);
+		if (netonlineFailed(env)) cb = NULL;
+	}
+	(*env)->DeleteLocalRef(env, cls);
+	if (cb == NULL) return NULL;
+	jobject g = (*env)->NewGlobalRef(env, cb);
+	(*env)->DeleteLocalRef(env, cb);
+	return g;
+}
+
+// netonlineUnregister unregisters cb and deletes its global reference.
+static void netonlineUnregister(uintptr_t envp, uintptr_t ctxp, jobject cb) {
+	JNIEnv *env = (JNIEnv *)envp;
+	jclass cls = (*env)->GetObjectClass(env, cb);
+	jmethodID m = (*env)->GetMethodID(env, cls, "unregister", "(Landroid/content/Context;)V");
+	(*env)->DeleteLocalRef(env, cls);
+	if (!netonlineFailed(env)) {
+		(*env)->CallVoidMethod(env, cb, m, (jobject)ctxp);
+		netonlineFailed(env);
+	}
+	(*env)->DeleteGlobalRef(env, cb);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/mobile/app"
+)
+
+// androidPollInterval is how often the event stream polls the active
+// network of apps that do not include NetworkCallbackBridge.
+const androidPollInterval = 5 * time.Second
+
+// The ConnectivityManager network types NetworkInfo.getType reports.
+const (
+	androidTypeMobile   = 0
+	androidTypeWiFi     = 1
+	androidTypeWiMAX    = 6
+	androidTypeEthernet = 9
+	androidTypeVPN      = 17
+)
+
+var errAndroidNoContext = errors.New("no Android context; netonline needs an app built with gomobile")
+
+// androidNetwork is the active network as NetworkInfo describes it.
+type androidNetwork struct {
+	active    bool
+	connected bool
+	typ       int
+	iface     string // from LinkProperties, "" if unknown
+}
+
+// androidActiveNetwork asks ConnectivityManager for the active network.
+func androidActiveNetwork() (androidNetwork, error) {
+	var rc, connected, typ C.int
+	var iface [64]C.char
+	err := app.RunOnJVM(func(vm, env, ctx uintptr) error {
+		if ctx == 0 {
+			return errAndroidNoContext
+		}
+		rc = C.netonlineActiveNetwork(C.uintptr_t(env), C.uintptr_t(ctx), &connected, &typ, &iface[0], C.int(len(iface)))
+		return nil
+	})
+	if err == nil && rc < 0 {
+		err = errors.New("ConnectivityManager.getActiveNetworkInfo failed; does the app have the ACCESS_NETWORK_STATE permission?")
+	}
+	if err != nil {
+		return androidNetwork{}, newWatchError(ErrKindOSSocket, fmt.Errorf("%w: %w", ErrAndroidAPI, err))
+	}
+	if rc == 0 {
+		return androidNetwork{}, nil
+	}
+	return androidNetwork{active: true, connected: connected != 0, typ: int(typ), iface: C.GoString(&iface[0])}, nil
+}
+
+// androidKind classifies a NetworkInfo type.
+func androidKind(typ int) InterfaceKind {
+	switch typ {
+	case androidTypeMobile, androidTypeWiMAX:
+		return InterfaceCellular
+	case androidTypeWiFi:
+		return InterfaceWiFi
+	case androidTypeEthernet:
+		return InterfaceEthernet
+	case androidTypeVPN:
+		return InterfaceTunnel
+	}
+	return InterfaceOther
+}
+
+// recomputeOnline reports the host online when ConnectivityManager's
+// active network is connected, as NetworkInfo.isConnected says.
+// WithNetworkNamespace does not apply on Android.
+func recomputeOnline(cfg *config) (netState, error) {
+	n, err := androidActiveNetwork()
+	if err != nil {
+		return netState{why: "active network check failed"}, err
+	}
+	if !n.active {
+		return netState{why: "no active network"}, nil
+	}
+	kind := androidKind(n.typ)
+	if why := cfg.ifaceFilteredKind(n.iface, kind); why != "" {
+		return netState{why: why, iface: n.iface, kind: kind}, nil
+	}
+	if !n.connected {
+		return netState{why: "active network not connected", iface: n.iface, kind: kind}, nil
+	}
+	why := "active " + kind.String() + " network"
+	if n.iface != "" {
+		why += " via " + n.iface
+	}
+	return netState{online: true, why: why, iface: n.iface, kind: kind}, nil
+}
+
+// androidCallbacks routes the calls of each registered NetworkCallbackBridge,
+// which carry the handle it was registered with, to its event stream. A
+// call that races with unregistering finds no stream and is dropped.
+var androidCallbacks struct {
+	sync.Mutex
+	next    int64
+	streams map[int64]chan string
+}
+
+// The osEvent reasons for NetworkCallbackBridge's onChange codes.
+var androidCallbackReasons = [...]string{
+	"android network available",
+	"android network lost",
+	"android network capabilities changed",
+}
+
+func addAndroidCallback() (int64, <-chan string) {
+	androidCallbacks.Lock()
+	defer androidCallbacks.Unlock()
+	if androidCallbacks.streams == nil {
+		androidCallbacks.streams = make(map[int64]chan string)
+	}
+	androidCallbacks.next++
+	ch := make(chan string, 1)
+	androidCallbacks.streams[androidCallbacks.next] = ch
+	return androidCallbacks.next, ch
+}
+
+func removeAndroidCallback(handle int64) {
+	androidCallbacks.Lock()
+	delete(androidCallbacks.streams, handle)
+	androidCallbacks.Unlock()
+}
+
+// androidOnChange is called by NetworkCallbackBridge.onChange, on one of
+// ConnectivityManager's threads.
+func androidOnChange(handle int64, what int) {
+	if what < 0 || what >= len(androidCallbackReasons) {
+		return
+	}
+	androidCallbacks.Lock()
+	ch := androidCallbacks.streams[handle]
+	androidCallbacks.Unlock()
+	select {
+	case ch <- androidCallbackReasons[what]:
+	default: // one pending change is enough, the watcher re-evaluates anyway
+	}
+}
+
+// startOSEventStream registers a NetworkCallbackBridge through
+// ConnectivityManager.registerNetworkCallback, so that its onAvailable,
+// onLost and onCapabilitiesChanged calls become OS events. Apps that do not
+// include the class (see android/NetworkCallbackBridge.java) get the active
+// network polled every androidPollInterval instead.
+func startOSEventStream(ctx context.Context, cfg *config) (<-chan osEvent, <-chan error) {
+	out := make(chan osEvent, 8)
+	errc := make(chan error, 1)
+	handle, in := addAndroidCallback()
+	var cb C.jobject
+	err := app.RunOnJVM(func(vm, env, jctx uintptr) error {
+		if jctx == 0 {
+			return errAndroidNoContext
+		}
+		cb = C.netonlineRegister(C.uintptr_t(env), C.uintptr_t(jctx), C.jlong(handle))
+		return nil
+	})
+	if err != nil {
+		removeAndroidCallback(handle)
+		errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("%w: %w", ErrAndroidAPI, err))
+		close(out)
+		close(errc)
+		return out, errc
+	}
+	var poll *time.Ticker
+	var last androidNetwork
+	if cb == 0 {
+		removeAndroidCallback(handle)
+		if cfg.logger != nil {
+			cfg.logger.Info("NetworkCallbackBridge not in the app, polling the active network", "interval", androidPollInterval)
+		}
+		poll = time.NewTicker(androidPollInterval)
+		last, _ = androidActiveNetwork()
+	}
+	go func() {
+		defer close(out)
+		defer close(errc)
+		var tick <-chan time.Time
+		if poll != nil {
+			defer poll.Stop()
+			tick = poll.C
+		} else {
+			defer func() {
+				removeAndroidCallback(handle)
+				app.RunOnJVM(func(vm, env, jctx uintptr) error {
+					C.netonlineUnregister(C.uintptr_t(env), C.uintptr_t(jctx), cb)
+					return nil
+				})
+			}()
+		}
+		for {
+			var reason string
+			select {
+			case <-ctx.Done():
+				return
+			case reason = <-in:
+			case <-tick:
+				n, err := androidActiveNetwork()
+				if err != nil || n == last { // recomputeOnline reports the error
+					continue
+				}
+				last, reason = n, "android network poll"
+			}
+			select {
+			case out <- osEvent{reason: reason}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errc
+}
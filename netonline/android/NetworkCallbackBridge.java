@@ -0,0 +1,62 @@
+package com.example.netonline;
+
+import android.content.Context;
+import android.net.ConnectivityManager;
+import android.net.Network;
+import android.net.NetworkCapabilities;
+import android.net.NetworkRequest;
+
+/**
+ * Forwards ConnectivityManager's network callbacks to the netonline Go
+ * package. Add this file to an Android app that uses netonline through
+ * gomobile; each Watcher then registers one instance through JNI and gets
+ * an event for every change. Without it the watcher polls the active
+ * network instead. The app needs the ACCESS_NETWORK_STATE permission.
+ */
+public final class NetworkCallbackBridge extends ConnectivityManager.NetworkCallback {
+    // The changes reported to onChange, as netonline expects them.
+    private static final int AVAILABLE = 0;
+    private static final int LOST = 1;
+    private static final int CAPABILITIES_CHANGED = 2;
+
+    private final long handle;
+
+    private NetworkCallbackBridge(long handle) {
+        this.handle = handle;
+    }
+
+    static NetworkCallbackBridge register(Context context, long handle) {
+        NetworkCallbackBridge cb = new NetworkCallbackBridge(handle);
+        NetworkRequest req = new NetworkRequest.Builder()
+                .addCapability(NetworkCapabilities.NET_CAPABILITY_INTERNET)
+                .build();
+        manager(context).registerNetworkCallback(req, cb);
+        return cb;
+    }
+
+    void unregister(Context context) {
+        manager(context).unregisterNetworkCallback(this);
+    }
+
+    private static ConnectivityManager manager(Context context) {
+        return (ConnectivityManager) context.getSystemService(Context.CONNECTIVITY_SERVICE);
+    }
+
+    @Override
+    public void onAvailable(Network network) {
+        onChange(handle, AVAILABLE);
+    }
+
+    @Override
+    public void onLost(Network network) {
+        onChange(handle, LOST);
+    }
+
+    @Override
+    public void onCapabilitiesChanged(Network network, NetworkCapabilities caps) {
+        onChange(handle, CAPABILITIES_CHANGED);
+    }
+
+    // Implemented in netonline's android.go.
+    private static native void onChange(long handle, int what);
+}
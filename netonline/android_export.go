@@ -0,0 +1,15 @@
+//go:build android && cgo
+// +build android,cgo
+
+package netonline
+
+// The callback lives apart from android.go: a file with //export may only
+// declare C functions in its preamble, not define them.
+
+// #include <jni.h>
+import "C"
+
+//export Java_com_example_netonline_NetworkCallbackBridge_onChange
+func Java_com_example_netonline_NetworkCallbackBridge_onChange(env *C.JNIEnv, cls C.jclass, handle C.jlong, what C.jint) {
+	androidOnChange(int64(handle), int(what))
+}
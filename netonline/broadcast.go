@@ -0,0 +1,95 @@
+package netonline
+
+import (
+	"context"
+	"sync"
+)
+
+// broadcastBuffer is the capacity of each Broadcast subscriber channel.
+const broadcastBuffer = 16
+
+// BroadcastOption configures Broadcast.
+type BroadcastOption func(*broadcaster)
+
+// WithBlockingBroadcast makes Broadcast wait for slow subscribers instead of
+// dropping their events. A subscriber that stops reading then holds up all
+// others until its context is cancelled.
+func WithBlockingBroadcast() BroadcastOption {
+	return func(b *broadcaster) { b.blocking = true }
+}
+
+type broadcaster struct {
+	blocking bool
+
+	mu     sync.Mutex
+	subs   map[*broadcastSub]struct{}
+	closed bool
+}
+
+type broadcastSub struct {
+	ctx context.Context
+	ch  chan Event
+}
+
+// Broadcast fans the events of src out to any number of subscribers, so
+// that one Watch (and one OS subscription) can serve a whole application.
+// The returned function subscribes: it returns a channel of the events
+// received from then on, buffered for 16 events, which is closed when ctx
+// is cancelled or src is closed. A subscriber whose buffer is full misses
+// events rather than holding up the others, unless WithBlockingBroadcast is
+// given. Subscribing and unsubscribing are safe while events flow.
+func Broadcast(src <-chan Event, opts ...BroadcastOption) func(ctx context.Context) <-chan Event {
+	b := &broadcaster{subs: make(map[*broadcastSub]struct{})}
+	for _, o := range opts {
+		o(b)
+	}
+	go b.run(src)
+	return b.subscribe
+}
+
+func (b *broadcaster) run(src <-chan Event) {
+	for ev := range src {
+		b.mu.Lock()
+		for s := range b.subs {
+			if b.blocking {
+				select {
+				case s.ch <- ev:
+				case <-s.ctx.Done():
+				}
+				continue
+			}
+			select {
+			case s.ch <- ev:
+			default:
+			}
+		}
+		b.mu.Unlock()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		close(s.ch)
+		delete(b.subs, s)
+	}
+}
+
+func (b *broadcaster) subscribe(ctx context.Context) <-chan Event {
+	s := &broadcastSub{ctx: ctx, ch: make(chan Event, broadcastBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s.ch
+	}
+	b.subs[s] = struct{}{}
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[s]; ok {
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	})
+	return s.ch
+}
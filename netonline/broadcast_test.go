@@ -0,0 +1,44 @@
+package netonline_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// TestBroadcastSlowSubscriber checks that a subscriber that never reads
+// does not hold up another one: the reader gets every transition, each
+// within a deadline, long after the stalled subscriber's buffer is full.
+func TestBroadcastSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := make(chan netonline.Event)
+	defer close(src)
+	subscribe := netonline.Broadcast(src)
+	stalled := subscribe(ctx)
+	reader := subscribe(ctx)
+
+	const transitions = 100 // several times the subscriber buffer
+	for i := range transitions {
+		want := netonline.Event{Online: i%2 == 0, CauseDetail: fmt.Sprint("transition ", i)}
+		select {
+		case src <- want:
+		case <-time.After(time.Second):
+			t.Fatalf("broadcaster stuck before transition %d", i)
+		}
+		select {
+		case got := <-reader:
+			if got.Online != want.Online || got.CauseDetail != want.CauseDetail {
+				t.Fatalf("reader got %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("reader missed transition %d", i)
+		}
+	}
+	if n := len(stalled); n != cap(stalled) {
+		t.Errorf("stalled subscriber holds %d events, want its full buffer of %d", n, cap(stalled))
+	}
+}
@@ -8,25 +8,30 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
 
 	"golang.org/x/net/route"
 	"golang.org/x/sys/unix"
 )
 
-func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
+func startOSEventStream(ctx context.Context, cfg *config) (<-chan osEvent, <-chan error) {
 	out := make(chan osEvent, 8)
 	errc := make(chan error, 1)
 	go func() {
 		defer close(out); defer close(errc)
 		fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
-		if err != nil { errc <- fmt.Errorf("route socket: %w", err); return }
+		if err != nil { errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("%w: socket: %w", ErrRouteSocket, err)); return }
 		defer unix.Close(fd)
 		buf := make([]byte, 1<<16)
 		for {
 			select { case <-ctx.Done(): return; default: }
 			n, err := unix.Read(fd, buf)
-			if err != nil { errc <- fmt.Errorf("route recv: %w", err); return }
-			if _, err := route.ParseRIB(route.RIBTypeKernel, buf[:n]); err != nil {
+			if err != nil { errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("%w: recv: %w", ErrRouteSocket, err)); return }
+			if _, err := route.ParseRIB(route.RIBTypeRoute, buf[:n]); err != nil {
 				out <- osEvent{reason: "net change"}; continue
 			}
 			out <- osEvent{reason: "net change"}
@@ -35,36 +40,127 @@ func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
 	return out, errc
 }
 
-func recomputeOnline() (bool, string, error) {
-	hasDef, ifname, err := bsdDefaultRoute()
-	if err != nil { return false, "default route check failed", err }
-	if !hasDef { return false, "no default route", nil }
-	if ifname == "" { return false, "default route no iface", nil }
-	ifi, err := net.InterfaceByName(ifname)
-	if err != nil || (ifi.Flags&net.FlagUp) == 0 || (ifi.Flags&net.FlagLoopback) != 0 { return false, "default iface down/loopback", nil }
-	if !ifaceHasUsableAddr(ifname) { return false, "default iface has no usable IP", nil }
-	if !hasDNSResolver() { return false, "no DNS resolver", nil }
-	return true, "default via " + ifname, nil
+func recomputeOnline(cfg *config) (netState, error) {
+	// The interface list is far smaller than the routing table, which can
+	// hold thousands of entries with a routing daemon; skip the latter when
+	// no interface could carry a default route anyway.
+	if !bsdInterfaceReachable(0) { return netState{why: "no interface up"}, nil }
+	hasDef, ifidx, err := bsdDefaultRoute(cfg)
+	if err != nil { return netState{why: "default route check failed"}, err }
+	if !hasDef { return netState{why: "no default route"}, nil }
+	// The route carries the interface index, which survives a rename; the
+	// name is only resolved here.
+	ifname := ifNameFromIndex(ifidx)
+	if ifname == "" { return netState{why: "default route no iface"}, nil }
+	if why := cfg.ifaceFiltered(ifname); why != "" { return netState{why: why, iface: ifname, index: ifidx}, nil }
+	if !bsdInterfaceReachable(ifidx) { return netState{why: "default iface down/loopback", iface: ifname, index: ifidx}, nil }
+	addr := ifaceUsableAddr(ifname)
+	if addr == "" { return netState{why: "default iface has no usable IP", iface: ifname, index: ifidx}, nil }
+	if !hasDNSResolver() { return netState{why: "no DNS resolver", iface: ifname, index: ifidx, addr: addr}, nil }
+	return netState{online: true, why: "default via " + ifname, iface: ifname, index: ifidx, addr: addr}, nil
 }
 
-func bsdDefaultRoute() (bool, string, error) {
+// interfaceKind classifies ifname by its name, then by the media type that
+// SIOCGIFMEDIA reports, which tells WiFi from Ethernet on en* interfaces.
+// Without media information the remaining interfaces count as Ethernet.
+func interfaceKind(ifname string) InterfaceKind {
+	for _, p := range []struct{ prefix string; kind InterfaceKind }{
+		{"wlan", InterfaceWiFi}, {"pdp_ip", InterfaceCellular},
+		{"utun", InterfaceTunnel}, {"tun", InterfaceTunnel}, {"tap", InterfaceTunnel}, {"ipsec", InterfaceTunnel},
+		{"ppp", InterfaceTunnel}, {"gif", InterfaceTunnel}, {"stf", InterfaceTunnel}, {"wg", InterfaceTunnel},
+		{"lo", InterfaceLoopback}, {"bridge", InterfaceVirtual}, {"vmenet", InterfaceVirtual}, {"awdl", InterfaceVirtual}, {"llw", InterfaceVirtual},
+	} {
+		if strings.HasPrefix(ifname, p.prefix) { return p.kind }
+	}
+	if ifMediaType(ifname) == ifmIEEE80211 { return InterfaceWiFi }
+	return InterfaceEthernet
+}
+
+// ifmediareq mirrors struct ifmediareq, which <net/if.h> packs to 4 bytes.
+type ifmediareq struct {
+	name                                 [unix.IFNAMSIZ]byte
+	current, mask, status, active, count int32
+	ulist                                [2]uint32 // int *, unused
+}
+
+const ( ifmTypeMask = 0xe0; ifmEther = 0x20; ifmIEEE80211 = 0x80 ) // IFM_TMASK, IFM_ETHER, IFM_IEEE80211
+
+// ifMediaType returns the IFM_TMASK bits of ifname's current media, or 0
+// when SIOCGIFMEDIA fails, as it does for interfaces without media.
+func ifMediaType(ifname string) int {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0); if err != nil { return 0 }
+	defer unix.Close(fd)
+	var req ifmediareq; copy(req.name[:], ifname)
+	if _, _, e := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(unix.SIOCGIFMEDIA), uintptr(unsafe.Pointer(&req))); e != 0 { return 0 }
+	return int(req.current) & ifmTypeMask
+}
+
+func bsdDefaultRoute(cfg *config) (bool, int, error) {
 	msgs, err := route.FetchRIB(unix.AF_INET, route.RIBTypeRoute, 0)
-	if err == nil { if ok, ifn := pickDefaultFromRIB(msgs); ok { return true, ifn, nil } }
+	if err == nil { if ok, idx := pickDefaultFromRIB(msgs); ok { return true, idx, nil } }
+	if legacyDarwin() {
+		// FetchRIB fails for AF_INET6 route dumps on OS X 10.11 and
+		// earlier; only IPv4 default routes are detected there.
+		legacyDarwinLog.Do(func() {
+			if cfg.logger != nil { cfg.logger.Info("IPv6 default route detection disabled on OS X 10.11 and earlier") }
+		})
+		return false, 0, nil
+	}
 	msgs6, err := route.FetchRIB(unix.AF_INET6, route.RIBTypeRoute, 0)
-	if err == nil { if ok, ifn := pickDefaultFromRIB(msgs6); ok { return true, ifn, nil } }
-	return false, "", nil
+	if err == nil { if ok, idx := pickDefaultFromRIB(msgs6); ok { return true, idx, nil } }
+	return false, 0, nil
 }
 
-func pickDefaultFromRIB(b []byte) (bool, string) {
+// bsdInterfaceReachable reports whether the interface with index ifidx, or
+// with ifidx 0 any interface, is up, running and not a loopback. It reads
+// only the interface list entry (sysctl CTL_NET, AF_ROUTE, 0, AF_UNSPEC,
+// NET_RT_IFLIST, ifidx), not the routing table.
+func bsdInterfaceReachable(ifidx int) bool {
+	b, err := route.FetchRIB(unix.AF_UNSPEC, route.RIBTypeInterface, ifidx); if err != nil { return false }
+	ms, err := route.ParseRIB(route.RIBTypeInterface, b); if err != nil { return false }
+	for _, m := range ms {
+		im, ok := m.(*route.InterfaceMessage); if !ok { continue }
+		if ifidx != 0 && im.Index != ifidx { continue }
+		if im.Flags&unix.IFF_LOOPBACK != 0 { continue }
+		if im.Flags&unix.IFF_UP != 0 && im.Flags&unix.IFF_RUNNING != 0 { return true }
+	}
+	return false
+}
+
+var legacyDarwinLog sync.Once
+
+// legacyDarwin reports whether this is macOS (OS X) 10.11 or earlier.
+func legacyDarwin() bool {
+	if runtime.GOOS != "darwin" { return false }
+	major, minor := darwinVersion()
+	return major == 10 && minor <= 11
+}
+
+// darwinVersion returns the macOS version derived from the kernel release:
+// Darwin 15 is OS X 10.11 up to Darwin 19 for 10.15, and Darwin 20 onwards
+// is macOS 11 onwards. It returns 0, 0 if the release cannot be read.
+func darwinVersion() (major, minor int) {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil { return 0, 0 }
+	rel := unix.ByteSliceToString(u.Release[:])
+	k, err := strconv.Atoi(strings.SplitN(rel, ".", 2)[0]); if err != nil { return 0, 0 }
+	switch {
+	case k >= 20: return k - 9, 0
+	case k >= 5: return 10, k - 4
+	}
+	return 0, 0
+}
+
+func pickDefaultFromRIB(b []byte) (bool, int) {
 	ms, err := route.ParseRIB(route.RIBTypeRoute, b)
-	if err != nil { return false, "" }
+	if err != nil { return false, 0 }
 	for _, m := range ms {
 		rm, ok := m.(*route.RouteMessage); if !ok { continue }
 		var dst route.Addr
-		for i, a := range rm.Addrs { if i == route.RTAB_DST { dst = a; break } }
-		if isZeroAddr(dst) { return true, ifNameFromIndex(rm.Index) }
+		if len(rm.Addrs) > unix.RTAX_DST { dst = rm.Addrs[unix.RTAX_DST] }
+		if isZeroAddr(dst) { return true, rm.Index }
 	}
-	return false, ""
+	return false, 0
 }
 
 func isZeroAddr(a route.Addr) bool {
@@ -83,16 +179,16 @@ func ifNameFromIndex(idx int) string {
 	return ifi.Name
 }
 
-func ifaceHasUsableAddr(ifname string) bool {
-	ifi, err := net.InterfaceByName(ifname); if err != nil { return false }
-	addrs, err := ifi.Addrs(); if err != nil { return false }
+func ifaceUsableAddr(ifname string) string {
+	ifi, err := net.InterfaceByName(ifname); if err != nil { return "" }
+	addrs, err := ifi.Addrs(); if err != nil { return "" }
 	for _, a := range addrs {
 		var ip net.IP
 		switch v := a.(type) { case *net.IPNet: ip = v.IP; case *net.IPAddr: ip = v.IP }
 		if ip == nil || ip.IsLoopback() { continue }
-		if v4 := ip.To4(); v4 != nil { if !v4.IsUnspecified() { return true }; continue }
+		if v4 := ip.To4(); v4 != nil { if !v4.IsUnspecified() { return v4.String() }; continue }
 		if ip.IsLinkLocalUnicast() || ip.IsUnspecified() { continue }
-		return true
+		return ip.String()
 	}
-	return false
+	return ""
 }
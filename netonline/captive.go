@@ -0,0 +1,55 @@
+package netonline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// captivePortalProbeURL answers 204 No Content unless a captive portal
+// intercepts the request.
+const captivePortalProbeURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// WithCaptivePortalDetection makes the passive check, once it has found a
+// default route, a usable address and a DNS resolver, request a known
+// 204 No Content URL over plain HTTP. A redirect or any other response
+// means a captive portal intercepts traffic: the state is then reported
+// offline with the cause "captive portal" and Event.CaptivePortalURL set.
+// It is opt-in because it adds a network round trip, of up to 3 seconds,
+// to every evaluation. A failed request (no answer at all) does not count
+// as a portal.
+func WithCaptivePortalDetection() Option {
+	return newOption(func(cfg *config) { cfg.captive = true })
+}
+
+// detectCaptivePortal requests captivePortalProbeURL without following
+// redirects. It reports whether a portal answered and, if so, its URL: the
+// redirect target, or the probe URL when the portal served its page in
+// place.
+func detectCaptivePortal(ctx context.Context) (string, bool) {
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	defer tr.CloseIdleConnections()
+	cl := &http.Client{
+		Transport:     tr,
+		Timeout:       3 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := newHTTPProbeOptions(nil).newRequest(ctx, captivePortalProbeURL)
+	if err != nil {
+		return "", false
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return "", false
+	case resp.StatusCode >= 300 && resp.StatusCode <= 399:
+		if loc, err := resp.Location(); err == nil {
+			return loc.String(), true
+		}
+	}
+	return captivePortalProbeURL, true
+}
@@ -0,0 +1,84 @@
+package netonline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CauseCode classifies why an Event was emitted. Event.CauseDetail holds
+// the human-readable explanation.
+type CauseCode int
+
+const (
+	CauseUnknown          CauseCode = iota // not classified, or an event decoded from an older format
+	CauseInitial                           // the state found when the watcher started
+	CauseRouteChange                       // the routing table changed
+	CauseAddrChange                        // an interface address was added or removed
+	CauseLinkChange                        // an interface went up or down or changed state
+	CauseWake                              // re-evaluation after the system resumed from sleep
+	CauseCaptivePortal                     // a captive portal intercepts traffic (WithCaptivePortalDetection)
+	CauseValidationFailed                  // the active ConnectivityChecker rejected a passive online state
+	CauseHeartbeat                         // periodic repeat of the current state (WithHeartbeatInterval)
+	CauseInterfaceRenamed                  // the default interface was renamed while online
+	CauseRestart                           // the first event after SupervisedWatch restarted the watcher
+	CauseOther                             // any other OS notification, or a fallback poll
+)
+
+var causeNames = [...]string{
+	CauseUnknown:          "unknown",
+	CauseInitial:          "initial",
+	CauseRouteChange:      "route change",
+	CauseAddrChange:       "addr change",
+	CauseLinkChange:       "link change",
+	CauseWake:             "wake",
+	CauseCaptivePortal:    "captive portal",
+	CauseValidationFailed: "validation failed",
+	CauseHeartbeat:        "heartbeat",
+	CauseInterfaceRenamed: "interface renamed",
+	CauseRestart:          "restart",
+	CauseOther:            "other",
+}
+
+func (c CauseCode) String() string {
+	if c >= 0 && int(c) < len(causeNames) {
+		return causeNames[c]
+	}
+	return fmt.Sprintf("CauseCode(%d)", int(c))
+}
+
+// MarshalText encodes c as its String form, so that JSON carries
+// "route change" rather than a number.
+func (c CauseCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes the String form of a CauseCode.
+func (c *CauseCode) UnmarshalText(b []byte) error {
+	for i, name := range causeNames {
+		if name == string(b) {
+			*c = CauseCode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("netonline: unknown cause %q", b)
+}
+
+// causeFromReason maps the reason of an OS event to a CauseCode. Sources
+// added with WithEventSource can use the same reasons, including "wake".
+func causeFromReason(reason string) CauseCode {
+	// NetworkManager reasons carry the states after a colon.
+	if strings.HasPrefix(reason, "nm device state change") {
+		return CauseLinkChange
+	}
+	switch reason {
+	case "route change":
+		return CauseRouteChange
+	case "addr change":
+		return CauseAddrChange
+	case "link change", "ip interface change", "android network available", "android network lost":
+		return CauseLinkChange
+	case "wake":
+		return CauseWake
+	}
+	return CauseOther
+}
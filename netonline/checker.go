@@ -0,0 +1,457 @@
+package netonline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ConnectivityChecker validates connectivity by running a set of active
+// probes concurrently. Connectivity is accepted once Require probes have
+// succeeded within Timeout.
+type ConnectivityChecker struct {
+	Timeout time.Duration
+	Require int
+	// Health, when set, records every probe outcome and skips endpoints
+	// that keep failing. The quorum is capped at the number of probes that
+	// actually run.
+	Health *ProbeEndpointHealth
+
+	probes        []namedProbe
+	dialer        proxy.Dialer
+	onCheck       []func(*CheckResult)
+	happyEyeballs bool
+	weights       map[string]float64
+	threshold     float64
+	adaptive      *Watcher
+	profile       ProbeProfile
+}
+
+type namedProbe struct {
+	name     string
+	fn       ProbeFunc
+	optional bool
+	family   AddressFamily
+}
+
+// happyEyeballsDelay is how long IPv4 probes wait for IPv6 ones under
+// WithHappyEyeballs (RFC 8305, section 5).
+const happyEyeballsDelay = 250 * time.Millisecond
+
+// ProbeOption configures a probe registered with ConnectivityChecker.Register.
+type ProbeOption func(*namedProbe)
+
+// WithProbeOptional marks a probe as informational: it runs and its result
+// appears in CheckResult.Probes, but it never counts towards the quorum.
+// Useful for quality probes such as ProbeJitter or ProbeUDPLoss.
+func WithProbeOptional() ProbeOption {
+	return func(p *namedProbe) { p.optional = true }
+}
+
+// WithProbeFamily tags a probe as testing one address family, for
+// ConnectivityChecker.WithHappyEyeballs. Probes are untagged (FamilyUnspec)
+// by default.
+func WithProbeFamily(f AddressFamily) ProbeOption {
+	return func(p *namedProbe) { p.family = f }
+}
+
+// CheckResult is the outcome of ConnectivityChecker.Check.
+type CheckResult struct {
+	OK     bool
+	Reason string
+	// Probes holds the probes that finished before Check returned, in
+	// completion order.
+	Probes []ProbeResult
+	// PassedProbes and FailedProbes split Probes by outcome, keeping the
+	// order. Optional probes are included.
+	PassedProbes []ProbeResult
+	FailedProbes []ProbeResult
+}
+
+// Summary returns a human-readable report of r: the outcome on the first
+// line, then one line per finished probe with its latency and, for failed
+// probes, the error. Probes that had not finished when Check returned are
+// not listed.
+func (r *CheckResult) Summary() string {
+	var b strings.Builder
+	outcome := "failed"
+	if r.OK {
+		outcome = "ok"
+	}
+	fmt.Fprintf(&b, "connectivity check %s: %s (%d passed, %d failed)", outcome, r.Reason, len(r.PassedProbes), len(r.FailedProbes))
+	for _, p := range r.FailedProbes {
+		fmt.Fprintf(&b, "\n  FAIL %s (%s): %v", p.Name, p.Latency.Round(time.Millisecond), p.Err)
+	}
+	for _, p := range r.PassedProbes {
+		fmt.Fprintf(&b, "\n  ok   %s (%s)", p.Name, p.Latency.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// ProbeResult is the outcome of a single probe.
+type ProbeResult struct {
+	Name     string
+	Err      error
+	Latency  time.Duration
+	Optional bool // registered with WithProbeOptional
+	// Extra holds probe-specific measurements such as "loss_pct"; see
+	// SetProbeExtra.
+	Extra map[string]float64
+}
+
+type probeResultKey struct{}
+
+// SetProbeExtra records a probe-specific measurement in the ProbeResult of
+// the probe running with ctx. It does nothing outside a
+// ConnectivityChecker.Check.
+func SetProbeExtra(ctx context.Context, key string, value float64) {
+	r, ok := ctx.Value(probeResultKey{}).(*ProbeResult)
+	if !ok {
+		return
+	}
+	if r.Extra == nil {
+		r.Extra = make(map[string]float64)
+	}
+	r.Extra[key] = value
+}
+
+// NewChecker returns a checker without any probes registered.
+func NewChecker(timeout time.Duration, require int) *ConnectivityChecker {
+	return &ConnectivityChecker{Timeout: timeout, Require: require}
+}
+
+// DefaultChecker returns a checker with the built-in DNS, TCP and HTTP probes
+// and a quorum of 3 within 5 seconds. HTTP probes go through the system
+// proxy (see ProbeHTTPWithProxy); register ProbeHTTPDirect probes on a
+// NewChecker to bypass it.
+func DefaultChecker() *ConnectivityChecker {
+	c := NewChecker(5*time.Second, 3)
+	c.probes = defaultProbes()
+	return c
+}
+
+// StrictChecker returns a checker for enterprise networks that only trusts
+// HTTPS: six verified-TLS probes (ProbeHTTPS) against independent endpoints,
+// with a quorum of 4 within 8 seconds. A captive portal or intercepting
+// proxy cannot satisfy it, so unlike DefaultChecker it does not report
+// online behind one. The price is more false negatives where TLS is broken
+// or inspected, such as networks with a TLS-rewriting proxy whose CA is not
+// in the system store, or hosts with a wrong clock.
+func StrictChecker() *ConnectivityChecker {
+	c := NewChecker(8*time.Second, 4)
+	for _, u := range []string{
+		"https://www.gstatic.com/generate_204",
+		"https://connectivitycheck.gstatic.com/generate_204",
+		"https://clients3.google.com/generate_204",
+		"https://www.google.com/generate_204",
+		"https://cp.cloudflare.com/generate_204",
+		"https://edge-http.microsoft.com/captiveportal/generate_204",
+	} {
+		c.Register("https:"+strings.TrimSuffix(strings.TrimPrefix(u, "https://"), "/generate_204"), ProbeHTTPS(u))
+	}
+	return c
+}
+
+// WithQuorum sets the number of probes that must succeed (Require). It
+// returns c for chaining.
+func (c *ConnectivityChecker) WithQuorum(n int) *ConnectivityChecker {
+	c.Require = n
+	return c
+}
+
+// WithHappyEyeballs orders probes as in Happy Eyeballs v2 (RFC 8305) when
+// both IPv6 and IPv4 probes are registered (see WithProbeFamily): IPv6 and
+// untagged probes start right away, IPv4 probes 250ms later. If an IPv6
+// probe that counts towards the quorum succeeds before then, the IPv4
+// probes do not run and the quorum is capped at the probes that did; if all
+// IPv6 probes fail first, IPv4 starts immediately. It returns c for
+// chaining.
+func (c *ConnectivityChecker) WithHappyEyeballs(enabled bool) *ConnectivityChecker {
+	c.happyEyeballs = enabled
+	return c
+}
+
+// ProbeProfile selects the probes to run per type of the default interface;
+// see ConnectivityChecker.WithAdaptiveProbeSelection. An entry ending in ":"
+// selects every probe whose name starts with it (such as "dns:"), any other
+// entry the probe with that name.
+type ProbeProfile map[InterfaceKind][]string
+
+// DefaultProbeProfile favours HTTP probes on WiFi, where captive portals are
+// common, and DNS and TCP probes on Ethernet. It matches the probe names of
+// DefaultChecker and StrictChecker.
+var DefaultProbeProfile = ProbeProfile{
+	InterfaceWiFi:     {"dns:", "http:", "https:"},
+	InterfaceEthernet: {"dns:", "tcp:"},
+}
+
+// WithAdaptiveProbeSelection makes Check run only the probes that the probe
+// profile (DefaultProbeProfile unless set with WithProbeProfile) lists for
+// the type of w's current interface, taken from the last event w reported.
+// All probes run before the first event, for interface types without an
+// entry and when no registered probe matches the entry. The quorum is capped
+// at the probes that run. It returns c for chaining.
+func (c *ConnectivityChecker) WithAdaptiveProbeSelection(w *Watcher) *ConnectivityChecker {
+	c.adaptive = w
+	return c
+}
+
+// WithProbeProfile sets the profile used by WithAdaptiveProbeSelection. It
+// returns c for chaining.
+func (c *ConnectivityChecker) WithProbeProfile(p ProbeProfile) *ConnectivityChecker {
+	c.profile = p
+	return c
+}
+
+// selectProbes applies WithAdaptiveProbeSelection to probes.
+func (c *ConnectivityChecker) selectProbes(probes []namedProbe) []namedProbe {
+	if c.adaptive == nil {
+		return probes
+	}
+	c.adaptive.mu.Lock()
+	iface := c.adaptive.iface
+	c.adaptive.mu.Unlock()
+	if iface == "" {
+		return probes
+	}
+	profile := c.profile
+	if profile == nil {
+		profile = DefaultProbeProfile
+	}
+	want, ok := profile[interfaceKind(iface)]
+	if !ok {
+		return probes
+	}
+	var sel []namedProbe
+	for _, p := range probes {
+		for _, name := range want {
+			if p.name == name || strings.HasSuffix(name, ":") && strings.HasPrefix(p.name, name) {
+				sel = append(sel, p)
+				break
+			}
+		}
+	}
+	if len(sel) == 0 {
+		return probes
+	}
+	return sel
+}
+
+// WithDialer routes the TCP probes (ProbeTCP, ProbeJitter) through d instead
+// of dialing directly. With a SOCKS5 dialer from golang.org/x/net/proxy this
+// checks the reachability of the network behind the proxy, for example a
+// remote system reached over an SSH tunnel. It returns c for chaining.
+func (c *ConnectivityChecker) WithDialer(d proxy.Dialer) *ConnectivityChecker {
+	c.dialer = d
+	return c
+}
+
+// OnCheck registers fn to be called with the result of every Check, on the
+// goroutine that called Check. Like Register, it must not be called
+// concurrently with Check. It returns c for chaining.
+func (c *ConnectivityChecker) OnCheck(fn func(*CheckResult)) *ConnectivityChecker {
+	c.onCheck = append(c.onCheck, fn)
+	return c
+}
+
+// WithProbeWeight sets the weight of the probe registered as name for
+// WithWeightedQuorum. Weights default to 2 for probes whose name starts with
+// "dns:", since a DNS failure breaks all name-based connectivity, and to 1
+// otherwise. It returns c for chaining.
+func (c *ConnectivityChecker) WithProbeWeight(name string, weight float64) *ConnectivityChecker {
+	if c.weights == nil {
+		c.weights = make(map[string]float64)
+	}
+	c.weights[name] = weight
+	return c
+}
+
+// WithWeightedQuorum replaces the Require count by a weighted vote: Check
+// succeeds once the weights (see WithProbeWeight) of the successful probes
+// add up to threshold. A threshold of 0 restores the count. It returns c for
+// chaining.
+func (c *ConnectivityChecker) WithWeightedQuorum(threshold float64) *ConnectivityChecker {
+	c.threshold = threshold
+	return c
+}
+
+// weight returns how much a success of p counts towards the quorum.
+func (c *ConnectivityChecker) weight(p namedProbe) float64 {
+	switch {
+	case p.optional:
+		return 0
+	case c.threshold <= 0:
+		return 1
+	}
+	if w, ok := c.weights[p.name]; ok {
+		return w
+	}
+	if strings.HasPrefix(p.name, "dns:") {
+		return 2
+	}
+	return 1
+}
+
+// countedWeight returns the largest score the probes ps can reach.
+func (c *ConnectivityChecker) countedWeight(ps []namedProbe) float64 {
+	var n float64
+	for _, p := range ps {
+		n += c.weight(p)
+	}
+	return n
+}
+
+// Register adds a named probe to the checker.
+func (c *ConnectivityChecker) Register(name string, fn ProbeFunc, opts ...ProbeOption) {
+	p := namedProbe{name: name, fn: fn}
+	for _, o := range opts {
+		o(&p)
+	}
+	c.probes = append(c.probes, p)
+}
+
+// Check runs all registered probes and reports whether the quorum was met.
+func (c *ConnectivityChecker) Check(parent context.Context) *CheckResult {
+	require := float64(c.Require)
+	if c.threshold > 0 {
+		require = c.threshold
+	} else if require <= 0 {
+		require = 1
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	probes := c.selectProbes(c.probes)
+	if len(probes) < len(c.probes) || c.Health != nil {
+		all := probes
+		probes = nil
+		for _, p := range all {
+			if c.Health == nil || c.Health.shouldRun(p.name) {
+				probes = append(probes, p)
+			}
+		}
+		if counted := c.countedWeight(probes); counted > 0 && require > counted {
+			require = counted
+		}
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	if c.dialer != nil {
+		ctx = context.WithValue(ctx, probeDialerKey{}, c.dialer)
+	}
+	// Under Happy Eyeballs the IPv4 probes are held back as late.
+	early, late := probes, []namedProbe(nil)
+	if c.happyEyeballs {
+		var v6, v4, other []namedProbe
+		for _, p := range probes {
+			switch p.family {
+			case FamilyIPv6:
+				v6 = append(v6, p)
+			case FamilyIPv4:
+				v4 = append(v4, p)
+			default:
+				other = append(other, p)
+			}
+		}
+		if len(v6) > 0 && len(v4) > 0 {
+			early, late = append(v6, other...), v4
+		}
+	}
+	type done struct {
+		r      *ProbeResult
+		family AddressFamily
+		weight float64
+	}
+	res := make(chan done, len(probes))
+	pending := 0
+	start := func(ps []namedProbe) {
+		pending += len(ps)
+		for _, p := range ps {
+			p := p
+			go func() {
+				r := &ProbeResult{Name: p.name, Optional: p.optional}
+				begin := time.Now()
+				r.Err = p.fn(context.WithValue(ctx, probeResultKey{}, r))
+				r.Latency = time.Since(begin)
+				res <- done{r, p.family, c.weight(p)}
+			}()
+		}
+	}
+	start(early)
+	var lateC <-chan time.Time
+	v6Pending := 0
+	if late != nil {
+		t := time.NewTimer(happyEyeballsDelay)
+		defer t.Stop()
+		lateC = t.C
+		for _, p := range early {
+			if p.family == FamilyIPv6 {
+				v6Pending++
+			}
+		}
+	}
+	startLate := func() {
+		start(late)
+		late, lateC = nil, nil
+	}
+	result := &CheckResult{}
+	finish := func(ok bool, reason string) *CheckResult {
+		result.OK, result.Reason = ok, reason
+		for _, p := range result.Probes {
+			if p.Err == nil {
+				result.PassedProbes = append(result.PassedProbes, p)
+			} else {
+				result.FailedProbes = append(result.FailedProbes, p)
+			}
+		}
+		for _, fn := range c.onCheck {
+			fn(result)
+		}
+		return result
+	}
+	var ok float64
+	for pending > 0 {
+		select {
+		case <-ctx.Done():
+			if ok >= require {
+				return finish(true, "ok (timeout after quorum)")
+			}
+			return finish(false, "timeout")
+		case <-lateC:
+			startLate()
+		case d := <-res:
+			r := d.r
+			pending--
+			result.Probes = append(result.Probes, *r)
+			if c.Health != nil && parent.Err() == nil {
+				c.Health.Record(r.Name, r.Err == nil)
+			}
+			if late != nil && d.family == FamilyIPv6 {
+				v6Pending--
+				if r.Err == nil && !r.Optional {
+					// IPv6 works: the IPv4 probes are not needed.
+					late, lateC = nil, nil
+					if n := c.countedWeight(early); n > 0 && require > n {
+						require = n
+					}
+				} else if v6Pending == 0 {
+					startLate()
+				}
+			}
+			if r.Err == nil && !r.Optional {
+				ok += d.weight
+				if ok >= require {
+					return finish(true, "ok")
+				}
+			}
+		}
+	}
+	if ok >= require {
+		return finish(true, "ok")
+	}
+	return finish(false, "insufficient successes")
+}
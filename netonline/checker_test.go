@@ -0,0 +1,188 @@
+package netonline_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+var errProbe = errors.New("probe failed")
+
+// stubProbe returns a probe called name that returns err after delay, or
+// the context's error if it ends first.
+func stubProbe(name string, delay time.Duration, err error) netonline.Probe {
+	return netonline.NamedProbe(name, func(ctx context.Context) error {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func TestConnectivityCheckQuorum(t *testing.T) {
+	const hang = time.Hour // never finishes within the test's timeout
+	tests := []struct {
+		name    string
+		require int
+		timeout time.Duration
+		probes  []netonline.Probe
+		ok      bool
+		reason  string
+		passed  []string
+		failed  []string
+		// maxLatency bounds how long the check may take.
+		maxLatency time.Duration
+	}{
+		{
+			name:    "quorum reached",
+			require: 2,
+			probes: []netonline.Probe{
+				stubProbe("a", 0, nil),
+				stubProbe("b", 20*time.Millisecond, errProbe),
+				stubProbe("c", 40*time.Millisecond, nil),
+			},
+			ok: true, reason: "ok",
+			passed: []string{"a", "c"}, failed: []string{"b"},
+			maxLatency: time.Second,
+		},
+		{
+			name:    "quorum missed",
+			require: 2,
+			probes: []netonline.Probe{
+				stubProbe("a", 0, nil),
+				stubProbe("b", 0, errProbe),
+				stubProbe("c", 20*time.Millisecond, errProbe),
+			},
+			ok: false, reason: "insufficient successes",
+			passed: []string{"a"}, failed: []string{"b", "c"},
+			maxLatency: time.Second,
+		},
+		{
+			// The slow probe is still running when the quorum is reached:
+			// the check does not wait for it or for the timeout.
+			name:    "timeout after quorum",
+			require: 2,
+			timeout: 5 * time.Second,
+			probes: []netonline.Probe{
+				stubProbe("a", 0, nil),
+				stubProbe("b", 10*time.Millisecond, nil),
+				stubProbe("slow", hang, nil),
+			},
+			ok: true, reason: "ok",
+			passed:     []string{"a", "b"},
+			maxLatency: time.Second,
+		},
+		{
+			name:    "timeout before quorum",
+			require: 2,
+			timeout: 100 * time.Millisecond,
+			probes: []netonline.Probe{
+				stubProbe("a", 0, nil),
+				stubProbe("slow", hang, nil),
+			},
+			ok: false, reason: "timeout",
+			passed:     []string{"a"},
+			maxLatency: time.Second,
+		},
+		{
+			name:       "zero probes",
+			require:    1,
+			probes:     []netonline.Probe{},
+			ok:         false,
+			reason:     "no probes",
+			maxLatency: 100 * time.Millisecond,
+		},
+		{
+			name:    "zero require counts as one",
+			require: 0,
+			probes: []netonline.Probe{
+				stubProbe("a", 0, nil),
+			},
+			ok: true, reason: "ok",
+			passed:     []string{"a"},
+			maxLatency: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := netonline.ConnectivityCheck(context.Background(), netonline.ConnectivityOptions{
+				Timeout: tt.timeout,
+				Require: tt.require,
+				Probes:  tt.probes,
+			})
+			if r.OK != tt.ok || r.Reason != tt.reason {
+				t.Errorf("OK, Reason = %v, %q; want %v, %q", r.OK, r.Reason, tt.ok, tt.reason)
+			}
+			if !slices.Equal(r.PassedProbes, tt.passed) {
+				t.Errorf("PassedProbes = %q, want %q", r.PassedProbes, tt.passed)
+			}
+			if !slices.Equal(r.FailedProbes, tt.failed) {
+				t.Errorf("FailedProbes = %q, want %q", r.FailedProbes, tt.failed)
+			}
+			if r.Latency > tt.maxLatency {
+				t.Errorf("Latency = %v, want at most %v", r.Latency, tt.maxLatency)
+			}
+		})
+	}
+}
+
+// brokerProbe is a user-defined Probe, standing in for something like an
+// MQTT broker check.
+type brokerProbe struct{ err error }
+
+func (brokerProbe) Name() string                  { return "mqtt:broker" }
+func (p brokerProbe) Check(context.Context) error { return p.err }
+
+// TestConnectivityCheckCustomProbes checks that user-supplied probes, both
+// a Probe implementation and a bare ProbeFunc, count towards the quorum
+// alongside a built-in one.
+func TestConnectivityCheckCustomProbes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	builtin := netonline.TCPProbe(ln.Addr().String())
+
+	tests := []struct {
+		name   string
+		broker error
+		ok     bool
+	}{
+		{"custom probes complete the quorum", nil, true},
+		{"failing custom probe misses it", errProbe, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := netonline.ConnectivityCheck(context.Background(), netonline.ConnectivityOptions{
+				Timeout: 5 * time.Second,
+				Require: 3,
+				Probes: []netonline.Probe{
+					builtin,
+					brokerProbe{tt.broker},
+					netonline.ProbeFunc(func(context.Context) error { return nil }),
+				},
+			})
+			if r.OK != tt.ok {
+				t.Fatalf("OK = %v (%s, passed %q, failed %q), want %v", r.OK, r.Reason, r.PassedProbes, r.FailedProbes, tt.ok)
+			}
+			for _, name := range []string{"tcp:" + ln.Addr().String(), "custom"} {
+				if !slices.Contains(r.PassedProbes, name) {
+					t.Errorf("PassedProbes = %q, want %q among them", r.PassedProbes, name)
+				}
+			}
+			if got := slices.Contains(r.PassedProbes, "mqtt:broker"); got != tt.ok {
+				t.Errorf("mqtt:broker passed = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
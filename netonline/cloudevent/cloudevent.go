@@ -0,0 +1,135 @@
+// Package cloudevent converts netonline events to CloudEvents 1.0
+// (https://cloudevents.io/) and publishes them to any CloudEvents sink, such
+// as an HTTP endpoint, NATS or Kafka.
+package cloudevent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+
+	"example.com/netonline/netonline"
+)
+
+// EventType is the CloudEvents type of every converted event.
+const EventType = "com.netonline.connectivity.changed"
+
+// sendTimeout bounds a single delivery to the sink.
+const sendTimeout = 10 * time.Second
+
+// EventToCloudEvent wraps e in a CloudEvent of type EventType from source,
+// with e as its JSON data and a random ID.
+func EventToCloudEvent(e netonline.Event, source string) (cloudevents.Event, error) {
+	ce := cloudevents.NewEvent()
+	ce.SetID(uuid.NewString())
+	ce.SetType(EventType)
+	ce.SetSource(source)
+	ce.SetTime(e.ChangedAt)
+	if err := ce.SetData(cloudevents.ApplicationJSON, e); err != nil {
+		return ce, err
+	}
+	return ce, ce.Validate()
+}
+
+// CloudEventPublisher sends every event of the watchers it is subscribed to
+// to a CloudEvents client. Deliveries happen on a dedicated goroutine; events
+// arriving while its queue is full are dropped and counted.
+type CloudEventPublisher struct {
+	sink   cloudevents.Client
+	source string
+
+	queue   chan netonline.Event
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewCloudEventPublisher starts a publisher sending to sink. Events carry
+// the source "netonline"; use WithSource to change it.
+func NewCloudEventPublisher(sink cloudevents.Client) *CloudEventPublisher {
+	p := &CloudEventPublisher{
+		sink:   sink,
+		source: "netonline",
+		queue:  make(chan netonline.Event, 64),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// WithSource sets the CloudEvents source attribute of published events. It
+// must be called before Subscribe.
+func (p *CloudEventPublisher) WithSource(source string) *CloudEventPublisher {
+	p.source = source
+	return p
+}
+
+// Subscribe publishes all events emitted by w from now on. The returned
+// function stops publishing them.
+func (p *CloudEventPublisher) Subscribe(w *netonline.Watcher) (cancel func()) {
+	return w.Notify(func(ev netonline.Event) {
+		select {
+		case p.queue <- ev:
+		case <-p.quit:
+		default:
+			p.dropped.Add(1)
+		}
+	})
+}
+
+// Dropped reports how many events were not published because the queue was
+// full.
+func (p *CloudEventPublisher) Dropped() uint64 { return p.dropped.Load() }
+
+// Close sends queued events and stops the publisher. It returns the last
+// delivery error, if any.
+func (p *CloudEventPublisher) Close() error {
+	p.once.Do(func() { close(p.quit) })
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *CloudEventPublisher) run() {
+	defer close(p.done)
+	send := func(ev netonline.Event) {
+		ce, err := EventToCloudEvent(ev, p.source)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			if res := p.sink.Send(ctx, ce); !cloudevents.IsACK(res) {
+				err = res
+			}
+			cancel()
+		}
+		if err != nil {
+			p.mu.Lock()
+			p.err = err
+			p.mu.Unlock()
+		}
+	}
+	for {
+		select {
+		case ev := <-p.queue:
+			send(ev)
+		case <-p.quit:
+			for {
+				select {
+				case ev := <-p.queue:
+					send(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package netonline
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Probe is a named active connectivity probe for ConnectivityCheck. The
+// built-in DNSProbe, DOHProbe, NTPProbe, STUNCheckProbe, TCPProbe and
+// HTTP204Probe can be mixed freely with user-defined ones, such as a probe
+// of an internal proxy or an MQTT broker.
+type Probe interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Name implements Probe. A bare ProbeFunc is reported as "custom"; use
+// NamedProbe to give it a name.
+func (f ProbeFunc) Name() string { return "custom" }
+
+// Check implements Probe by calling f.
+func (f ProbeFunc) Check(ctx context.Context) error { return f(ctx) }
+
+// DNSProbe returns ProbeDNS(host) as a Probe named "dns:" + host.
+func DNSProbe(host string) Probe { return NamedProbe("dns:"+host, ProbeDNS(host)) }
+
+// TCPProbe returns ProbeTCP(addr) as a Probe named "tcp:" + addr.
+func TCPProbe(addr string) Probe { return NamedProbe("tcp:"+addr, ProbeTCP(addr)) }
+
+// HTTP204Probe returns ProbeHTTPWithProxy(url) as a Probe named "http:"
+// followed by url without its scheme. It expects a 204 No Content
+// response, like the generate_204 endpoints of DefaultChecker.
+func HTTP204Probe(url string) Probe {
+	name := strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://")
+	return NamedProbe("http:"+name, ProbeHTTPWithProxy(url))
+}
+
+// NTPProbe returns ProbeNTP(server) as a Probe named "ntp:" + server.
+func NTPProbe(server string) Probe { return NamedProbe("ntp:"+server, ProbeNTP(server)) }
+
+// STUNCheckProbe returns a Probe named "stun:" + server that succeeds when
+// server answers a binding request.
+func STUNCheckProbe(server string) Probe {
+	return NamedProbe("stun:"+server, func(ctx context.Context) error {
+		_, err := stunPublicIP(ctx, server)
+		return err
+	})
+}
+
+// DOHProbe returns ProbeDoH(resolverURL) as a Probe named "doh:" followed
+// by resolverURL without its scheme.
+func DOHProbe(resolverURL string) Probe {
+	return NamedProbe("doh:"+strings.TrimPrefix(resolverURL, "https://"), ProbeDoH(resolverURL))
+}
+
+// NamedProbe returns a Probe called name that runs fn.
+func NamedProbe(name string, fn ProbeFunc) Probe {
+	return namedProbeFunc{name, fn}
+}
+
+type namedProbeFunc struct {
+	name string
+	fn   ProbeFunc
+}
+
+func (p namedProbeFunc) Name() string                    { return p.name }
+func (p namedProbeFunc) Check(ctx context.Context) error { return p.fn(ctx) }
+
+// ConnectivityOptions configures ConnectivityCheck.
+type ConnectivityOptions struct {
+	// Timeout bounds the whole check; 5 seconds if zero.
+	Timeout time.Duration
+	// Require is the number of probes that must succeed; 1 if zero.
+	Require int
+	// Probes are the probes to run. If nil, the DefaultChecker probes run;
+	// an empty, non-nil slice runs none and always fails.
+	Probes []Probe
+}
+
+// ConnectivityResult is the outcome of ConnectivityCheck.
+type ConnectivityResult struct {
+	OK           bool
+	PassedProbes []string // names, in completion order
+	FailedProbes []string // names, in completion order
+	// Latency is how long the check took, up to the quorum or the timeout.
+	Latency time.Duration
+	Reason  string
+}
+
+// ConnectivityCheck runs the probes of opts concurrently and reports whether
+// opts.Require of them succeeded within opts.Timeout. Probes still running
+// when the check ends are cancelled and appear in neither list. It is a
+// one-shot form of ConnectivityChecker.Check.
+func ConnectivityCheck(ctx context.Context, opts ConnectivityOptions) ConnectivityResult {
+	begin := time.Now()
+	if opts.Probes != nil && len(opts.Probes) == 0 {
+		return ConnectivityResult{Reason: "no probes"}
+	}
+	c := NewChecker(opts.Timeout, opts.Require)
+	if opts.Probes == nil {
+		c.probes = defaultProbes()
+	}
+	for _, p := range opts.Probes {
+		c.Register(p.Name(), p.Check)
+	}
+	res := c.Check(ctx)
+	out := ConnectivityResult{OK: res.OK, Latency: time.Since(begin), Reason: res.Reason}
+	for _, p := range res.PassedProbes {
+		out.PassedProbes = append(out.PassedProbes, p.Name)
+	}
+	for _, p := range res.FailedProbes {
+		out.FailedProbes = append(out.FailedProbes, p.Name)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package netonline
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOffline is the cancellation cause of contexts returned by
+// DeriveFromContext when the watcher reports offline.
+var ErrOffline = errors.New("netonline: network went offline")
+
+// DeriveFromContext returns a context derived from ctx that is cancelled,
+// with cause ErrOffline, as soon as w reports offline; if w is offline
+// already it is cancelled right away. A cancelled context cannot come back,
+// so after an outage callers wait for w.WaitOnline and derive a fresh one,
+// or use DeriveOnlineContexts, which does that for them. The context is also
+// cancelled when w stops. Cancel ctx to release it.
+func DeriveFromContext(ctx context.Context, w *Watcher) context.Context {
+	dctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		for {
+			online, known, changed := w.state()
+			if known && !online {
+				cancel(ErrOffline)
+				return
+			}
+			select {
+			case <-changed:
+			case <-dctx.Done():
+				return
+			case <-w.ctx.Done():
+				cancel(context.Cause(w.ctx))
+				return
+			}
+		}
+	}()
+	return dctx
+}
+
+// DeriveOnlineContexts returns a channel that yields a context derived from
+// ctx, as DeriveFromContext does, every time w goes online: one for each
+// online period, cancelled with cause ErrOffline when it ends. A context
+// that ends before it is received is skipped. The channel is closed when
+// ctx is done or w stops.
+//
+//	for octx := range netonline.DeriveOnlineContexts(ctx, w) {
+//		sync(octx) // returns once octx is cancelled
+//	}
+func DeriveOnlineContexts(ctx context.Context, w *Watcher) <-chan context.Context {
+	out := make(chan context.Context)
+	go func() {
+		defer close(out)
+		for w.WaitOnline(ctx) == nil {
+			dctx := DeriveFromContext(ctx, w)
+			select {
+			case out <- dctx:
+			case <-dctx.Done():
+				continue
+			}
+			<-dctx.Done()
+		}
+	}()
+	return out
+}
+
+// WaitOnline blocks until w reports online, ctx is done or w stops.
+func (w *Watcher) WaitOnline(ctx context.Context) error {
+	for {
+		online, known, changed := w.state()
+		if known && online {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.ctx.Done():
+			return context.Cause(w.ctx)
+		}
+	}
+}
+
+// state returns the last reported state and a channel that is closed on the
+// next change.
+func (w *Watcher) state() (online, known bool, changed <-chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.life.online, w.life.known, w.changed
+}
@@ -0,0 +1,108 @@
+package netonline_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+	"example.com/netonline/netonline/netonlinetesting"
+)
+
+// startContextWatcher starts a watcher on m that has reported its initial
+// offline state.
+func startContextWatcher(t *testing.T, m *netonlinetesting.MockPlatform, ctx context.Context) *netonline.Watcher {
+	t.Helper()
+	w := netonline.NewWatcher(ctx, m.Options()...)
+	t.Cleanup(w.Stop)
+	nextEvent(t, w.Events())
+	return w
+}
+
+// waitDone fails the test unless ctx is done within 2 seconds.
+func waitDone(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("context not cancelled within 2s")
+	}
+}
+
+// TestDeriveFromContextOneShot checks that a derived context is cancelled
+// with ErrOffline by an outage and stays cancelled after the network is
+// back.
+func TestDeriveFromContextOneShot(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := startContextWatcher(t, m, ctx)
+	change(m, true)
+	nextEvent(t, w.Events())
+
+	dctx := netonline.DeriveFromContext(ctx, w)
+	change(m, false)
+	nextEvent(t, w.Events())
+	waitDone(t, dctx)
+	change(m, true)
+	nextEvent(t, w.Events())
+	if err := context.Cause(dctx); !errors.Is(err, netonline.ErrOffline) {
+		t.Errorf("Cause = %v, want ErrOffline", err)
+	}
+}
+
+// TestDeriveOnlineContexts checks that every online period gets a fresh
+// context, cancelled with ErrOffline when the period ends, and that the
+// channel is closed when ctx is.
+func TestDeriveOnlineContexts(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := startContextWatcher(t, m, ctx)
+	ctxs := netonline.DeriveOnlineContexts(ctx, w)
+
+	next := func() context.Context {
+		t.Helper()
+		select {
+		case octx, ok := <-ctxs:
+			if !ok {
+				t.Fatal("context channel closed")
+			}
+			return octx
+		case <-time.After(2 * time.Second):
+			t.Fatal("no context within 2s")
+		}
+		return nil
+	}
+
+	select {
+	case octx := <-ctxs:
+		t.Fatalf("context %v yielded while offline", octx)
+	case <-time.After(50 * time.Millisecond):
+	}
+	for range 3 {
+		change(m, true)
+		nextEvent(t, w.Events())
+		octx := next()
+		if octx.Err() != nil {
+			t.Fatalf("context cancelled while online: %v", context.Cause(octx))
+		}
+		change(m, false)
+		nextEvent(t, w.Events())
+		waitDone(t, octx)
+		if err := context.Cause(octx); !errors.Is(err, netonline.ErrOffline) {
+			t.Errorf("Cause = %v, want ErrOffline", err)
+		}
+	}
+
+	cancel()
+	select {
+	case octx, ok := <-ctxs:
+		if ok {
+			t.Fatalf("context %v yielded after cancel", octx)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("context channel not closed after cancel")
+	}
+}
@@ -0,0 +1,115 @@
+package netonline
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CSVLogger writes events as CSV rows, one per event, for consumption by
+// shell tools such as awk, grep or cut. Rows are written and flushed on a
+// dedicated goroutine so a slow writer never blocks the Watcher; events
+// arriving while the queue is full are dropped and counted.
+type CSVLogger struct {
+	w        *csv.Writer
+	noHeader bool
+
+	queue   chan Event
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// CSVOption configures a CSVLogger.
+type CSVOption func(*CSVLogger)
+
+// WithNoHeader suppresses the header row, for appending to an existing log.
+func WithNoHeader() CSVOption {
+	return func(l *CSVLogger) { l.noHeader = true }
+}
+
+// NewCSVLogger returns a logger writing to w. Unless WithNoHeader is given,
+// the first row written is the header
+// timestamp,online,cause,interface,interface_type,state_duration_ms.
+func NewCSVLogger(w io.Writer, opts ...CSVOption) *CSVLogger {
+	l := &CSVLogger{
+		w:     csv.NewWriter(w),
+		queue: make(chan Event, 64),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	go l.write()
+	return l
+}
+
+// Subscribe logs all events emitted by watcher from now on. The returned
+// function stops logging them.
+func (l *CSVLogger) Subscribe(watcher *Watcher) (cancel func()) {
+	return watcher.Notify(func(ev Event) {
+		select {
+		case l.queue <- ev:
+		case <-l.quit:
+		default:
+			l.dropped.Add(1)
+		}
+	})
+}
+
+// Dropped reports how many events were not logged because the queue was full.
+func (l *CSVLogger) Dropped() uint64 { return l.dropped.Load() }
+
+// Close writes out queued events and stops the logger. It does not close
+// the underlying writer.
+func (l *CSVLogger) Close() error {
+	l.once.Do(func() { close(l.quit) })
+	<-l.done
+	return l.w.Error()
+}
+
+func (l *CSVLogger) write() {
+	defer close(l.done)
+	header := !l.noHeader
+	var last time.Time
+	row := func(ev Event) {
+		if header {
+			header = false
+			_ = l.w.Write([]string{"timestamp", "online", "cause", "interface", "interface_type", "state_duration_ms"})
+		}
+		var durationMS int64
+		if !last.IsZero() {
+			durationMS = ev.ChangedAt.Sub(last).Milliseconds()
+		}
+		last = ev.ChangedAt
+		_ = l.w.Write([]string{
+			ev.ChangedAt.Format(time.RFC3339Nano),
+			strconv.FormatBool(ev.Online),
+			ev.CauseDetail,
+			ev.Interface,
+			"",
+			strconv.FormatInt(durationMS, 10),
+		})
+		l.w.Flush()
+	}
+	for {
+		select {
+		case ev := <-l.queue:
+			row(ev)
+		case <-l.quit:
+			for {
+				select {
+				case ev := <-l.queue:
+					row(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
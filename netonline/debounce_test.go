@@ -0,0 +1,98 @@
+package netonline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+	"example.com/netonline/netonline/netonlinetesting"
+)
+
+// debounce is the watcher's default OS event debounce.
+const debounce = 750 * time.Millisecond
+
+// startMock starts a watcher on m with opts and returns its events after
+// the initial one, which must match online.
+func startMock(t *testing.T, m *netonlinetesting.MockPlatform, online bool, opts ...netonline.Option) <-chan netonline.Event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := netonline.NewWatcher(ctx, append(m.Options(), opts...)...)
+	t.Cleanup(func() {
+		cancel()
+		w.Stop()
+	})
+	if ev := nextEvent(t, w.Events()); ev.Online != online {
+		t.Fatalf("initial event %v, want online %v", ev, online)
+	}
+	return w.Events()
+}
+
+// change sets the mock's state and lets the watcher see it.
+func change(m *netonlinetesting.MockPlatform, online bool) {
+	m.SetOnline(online)
+	m.InjectOSEvent("route change")
+	m.Advance(debounce)
+}
+
+func TestOnlineDebounce(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	events := startMock(t, m, false, netonline.WithOnlineDebounce(5*time.Second))
+
+	change(m, true)
+	noPendingEvent(t, events)
+	m.Advance(4 * time.Second)
+	noPendingEvent(t, events)
+	m.Advance(time.Second)
+	if ev := nextEvent(t, events); !ev.Online {
+		t.Fatalf("after the online debounce: %v, want online", ev)
+	}
+
+	// Going offline is not delayed.
+	change(m, false)
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("after going offline: %v, want offline", ev)
+	}
+}
+
+func TestOnlineDebounceFlap(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	events := startMock(t, m, false, netonline.WithOnlineDebounce(5*time.Second))
+
+	change(m, true)
+	change(m, false)
+	m.Advance(5 * time.Second)
+	noPendingEvent(t, events)
+}
+
+func TestOfflineDebounce(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	m.SetOnline(true)
+	events := startMock(t, m, true, netonline.WithOfflineDebounce(5*time.Second))
+
+	change(m, false)
+	noPendingEvent(t, events)
+	m.Advance(4 * time.Second)
+	noPendingEvent(t, events)
+	m.Advance(time.Second)
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("after the offline debounce: %v, want offline", ev)
+	}
+
+	// Coming back online is not delayed.
+	change(m, true)
+	if ev := nextEvent(t, events); !ev.Online {
+		t.Fatalf("after coming back: %v, want online", ev)
+	}
+}
+
+func TestOfflineDebounceFlap(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	m.SetOnline(true)
+	events := startMock(t, m, true, netonline.WithOfflineDebounce(5*time.Second))
+
+	change(m, false)
+	change(m, true)
+	m.Advance(5 * time.Second)
+	noPendingEvent(t, events)
+}
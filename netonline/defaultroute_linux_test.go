@@ -0,0 +1,78 @@
+package netonline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// routeFixture points linuxProcRoot at a directory whose proc/net holds
+// the given route and ipv6_route files; empty contents leave a file out.
+func routeFixture(t *testing.T, route, ipv6Route string) {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "proc/net")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"route": route, "ipv6_route": ipv6Route} {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := linuxProcRoot
+	linuxProcRoot = root
+	t.Cleanup(func() { linuxProcRoot = old })
+}
+
+// TestDefaultRouteProcRoot checks that under a linuxProcRoot fixture the
+// default route comes from the fixture's route files, not from the host's
+// routing table over netlink.
+func TestDefaultRouteProcRoot(t *testing.T) {
+	const routeHeader = "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\tMTU\tWindow\tIRTT\n"
+	tests := []struct {
+		name      string
+		route     string
+		ipv6Route string
+		ok        bool
+		idx       int
+		gw        string
+	}{
+		{
+			name: "ipv4 default via lo",
+			route: routeHeader +
+				"lo\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+				"lo\t00000000\t0101A8C0\t0003\t0\t0\t0\t00000000\t0\t0\t0\n",
+			ok: true, idx: 1, gw: "192.168.1.1",
+		},
+		{
+			// The unreachable default route the kernel keeps on lo is
+			// skipped.
+			name:  "ipv6 default only",
+			route: routeHeader,
+			ipv6Route: "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n" +
+				"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003       lo\n",
+			ok: true, idx: 1,
+		},
+		{
+			name:  "no default route",
+			route: routeHeader + "lo\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n",
+		},
+		{name: "no route files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeFixture(t, tt.route, tt.ipv6Route)
+			ok, idx, gw, err := linuxDefaultRoute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok || idx != tt.idx || gw != tt.gw {
+				t.Errorf("linuxDefaultRoute() = %v, %d, %q; want %v, %d, %q", ok, idx, gw, tt.ok, tt.idx, tt.gw)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package netonline
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework SystemConfiguration
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <SystemConfiguration/SystemConfiguration.h>
+
+// netonlineSCDNSServers returns the number of entries in the ServerAddresses
+// array of State:/Network/Global/DNS, or -1 if the key cannot be read.
+static int netonlineSCDNSServers(void) {
+	CFDictionaryRef dns = SCDynamicStoreCopyValue(NULL, CFSTR("State:/Network/Global/DNS"));
+	if (dns == NULL) {
+		return -1;
+	}
+	int n = -1;
+	if (CFGetTypeID(dns) == CFDictionaryGetTypeID()) {
+		CFArrayRef servers = CFDictionaryGetValue(dns, CFSTR("ServerAddresses"));
+		n = 0;
+		if (servers != NULL && CFGetTypeID(servers) == CFArrayGetTypeID()) {
+			CFIndex count = CFArrayGetCount(servers);
+			for (CFIndex i = 0; i < count; i++) {
+				CFStringRef s = CFArrayGetValueAtIndex(servers, i);
+				if (CFGetTypeID(s) == CFStringGetTypeID() && CFStringGetLength(s) > 0) {
+					n++;
+				}
+			}
+		}
+	}
+	CFRelease(dns);
+	return n;
+}
+*/
+import "C"
+
+// hasDNSResolver asks SystemConfiguration for the global DNS servers, which
+// unlike /etc/resolv.conf also reflect servers pushed by VPN clients. It
+// falls back to /etc/resolv.conf when the dynamic store cannot be read.
+func hasDNSResolver() bool {
+	if n := C.netonlineSCDNSServers(); n >= 0 { return n > 0 }
+	return resolvConfHasDNS()
+}
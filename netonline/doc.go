@@ -0,0 +1,8 @@
+// Package netonline reports whether the machine is online, passively from
+// the OS routing and interface state and optionally confirmed by active
+// probes (ConnectivityChecker), and watches for changes (Watch, NewWatcher).
+//
+// Watchers are configured with Options created by the With* functions. The
+// Option interface is sealed: its method is unexported, so only this package
+// can implement it and every Option passed in is one of its own.
+package netonline
@@ -0,0 +1,92 @@
+package netonline
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Sentinels for the OS facilities behind a Watcher. Errors from them wrap
+// both the sentinel and the root cause, so errors.Is matches either.
+var (
+	ErrNetlinkSocket = errors.New("netlink socket unavailable")
+	ErrRouteSocket   = errors.New("route socket unavailable")
+	ErrWin32API      = errors.New("Win32 API failure")
+	ErrAndroidAPI    = errors.New("Android API failure")
+)
+
+// ErrorKind classifies the errors a Watcher reports.
+type ErrorKind int
+
+const (
+	ErrKindInternal   ErrorKind = iota // evaluation or message parsing failures
+	ErrKindOSSocket                    // the OS change subscription failed
+	ErrKindPermission                  // the OS refused access, e.g. EPERM
+	ErrKindTimeout                     // an operation timed out
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrKindOSSocket:
+		return "os socket"
+	case ErrKindPermission:
+		return "permission"
+	case ErrKindTimeout:
+		return "timeout"
+	}
+	return "internal"
+}
+
+// NetlinkOverflowError reports that the Linux netlink socket overflowed
+// (ENOBUFS) and change notifications were lost. The watcher has already
+// recovered by resubscribing on a new socket and re-evaluating the state;
+// it arrives on the error channel as a recoverable WatchError, so callers
+// can count overflows with errors.As. WithNetlinkRcvBuf makes them rarer.
+type NetlinkOverflowError struct {
+	Err error // the ENOBUFS from recv
+}
+
+func (e *NetlinkOverflowError) Error() string {
+	return "netlink socket overflow, notifications lost: " + e.Err.Error()
+}
+
+func (e *NetlinkOverflowError) Unwrap() error { return e.Err }
+
+// WatchError is the type of every error sent on a Watcher's error channel.
+// Recoverable errors, such as an interrupted read or a netlink buffer
+// overrun (ENOBUFS), are worked around by the watcher itself; callers can
+// log them and continue. Errors that are not recoverable, such as EPERM or
+// ErrEventStreamLost, will recur until the environment changes, so callers
+// may want to restart the watcher or give up (see SupervisedWatch).
+type WatchError struct {
+	Err         error
+	Kind        ErrorKind
+	Recoverable bool
+}
+
+func (e *WatchError) Error() string { return e.Err.Error() }
+
+func (e *WatchError) Unwrap() error { return e.Err }
+
+// newWatchError wraps err as a WatchError of kind, refining the kind and
+// recoverability from the underlying errno where there is one.
+func newWatchError(kind ErrorKind, err error) *WatchError {
+	var we *WatchError
+	if errors.As(err, &we) {
+		return we
+	}
+	we = &WatchError{Err: err, Kind: kind, Recoverable: true}
+	var errno syscall.Errno
+	var timeout interface{ Timeout() bool } // unlike os.IsTimeout, also found when wrapped
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		we.Kind, we.Recoverable = ErrKindPermission, false
+	case errors.As(err, &timeout) && timeout.Timeout():
+		we.Kind = ErrKindTimeout
+	case errors.Is(err, ErrEventStreamLost):
+		we.Recoverable = false
+	case errors.As(err, &errno):
+		we.Recoverable = errno == syscall.EINTR || errno == syscall.ENOBUFS || errno == syscall.EAGAIN || errno.Temporary()
+	}
+	return we
+}
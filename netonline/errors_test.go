@@ -0,0 +1,93 @@
+package netonline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// TestWatchErrorWrapping checks that the sentinels, the root causes and
+// the WatchError itself can all be found with errors.Is and errors.As
+// through the wrapping the platform code does, and the kind and
+// recoverability newWatchError derives.
+func TestWatchErrorWrapping(t *testing.T) {
+	win32 := errors.New("Access is denied.")
+	tests := []struct {
+		name        string
+		err         *WatchError
+		is          []error
+		kind        ErrorKind
+		recoverable bool
+	}{
+		{
+			name:        "netlink permission",
+			err:         newWatchError(ErrKindOSSocket, fmt.Errorf("%w: socket: %w", ErrNetlinkSocket, syscall.EPERM)),
+			is:          []error{ErrNetlinkSocket, syscall.EPERM, os.ErrPermission},
+			kind:        ErrKindPermission,
+			recoverable: false,
+		},
+		{
+			name:        "route socket overrun",
+			err:         newWatchError(ErrKindOSSocket, fmt.Errorf("%w: read: %w", ErrRouteSocket, syscall.ENOBUFS)),
+			is:          []error{ErrRouteSocket, syscall.ENOBUFS},
+			kind:        ErrKindOSSocket,
+			recoverable: true,
+		},
+		{
+			name:        "Win32 API",
+			err:         newWatchError(ErrKindOSSocket, fmt.Errorf("%w: NotifyIpInterfaceChange: %w", ErrWin32API, win32)),
+			is:          []error{ErrWin32API, win32},
+			kind:        ErrKindOSSocket,
+			recoverable: true,
+		},
+		{
+			name:        "netlink overflow",
+			err:         newWatchError(ErrKindOSSocket, &NetlinkOverflowError{Err: syscall.ENOBUFS}),
+			is:          []error{syscall.ENOBUFS},
+			kind:        ErrKindOSSocket,
+			recoverable: true,
+		},
+		{
+			name:        "event stream lost",
+			err:         newWatchError(ErrKindInternal, fmt.Errorf("%w after 3 reconnect attempts, polling only", ErrEventStreamLost)),
+			is:          []error{ErrEventStreamLost},
+			kind:        ErrKindInternal,
+			recoverable: false,
+		},
+		{
+			name:        "timeout",
+			err:         newWatchError(ErrKindInternal, fmt.Errorf("evaluate: %w", os.ErrDeadlineExceeded)),
+			is:          []error{os.ErrDeadlineExceeded},
+			kind:        ErrKindTimeout,
+			recoverable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Callers may wrap the error again before inspecting it.
+			err := fmt.Errorf("watch: %w", tt.err)
+			for _, target := range tt.is {
+				if !errors.Is(err, target) {
+					t.Errorf("errors.Is(%v, %v) = false", err, target)
+				}
+			}
+			var we *WatchError
+			if !errors.As(err, &we) || we != tt.err {
+				t.Fatalf("errors.As(%v, *WatchError) did not find it", err)
+			}
+			if we.Kind != tt.kind || we.Recoverable != tt.recoverable {
+				t.Errorf("Kind, Recoverable = %v, %v; want %v, %v", we.Kind, we.Recoverable, tt.kind, tt.recoverable)
+			}
+			if newWatchError(ErrKindInternal, err) != tt.err {
+				t.Error("newWatchError wrapped a WatchError again")
+			}
+		})
+	}
+
+	var overflow *NetlinkOverflowError
+	if err := fmt.Errorf("watch: %w", tests[3].err); !errors.As(err, &overflow) || !errors.Is(overflow, syscall.ENOBUFS) {
+		t.Errorf("errors.As(%v, *NetlinkOverflowError) = %v", err, overflow)
+	}
+}
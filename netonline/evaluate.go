@@ -1,8 +1,105 @@
-
 package netonline
 
+import (
+	"context"
+	"time"
+)
+
+// netState is the outcome of a single passive evaluation.
+type netState struct {
+	online  bool
+	why     string
+	iface   string
+	index   int // of iface, 0 if unknown; unlike the name it survives a rename
+	addr    string
+	portal  string // captive portal URL, see WithCaptivePortalDetection
+	kind    InterfaceKind
+	metered *bool
+}
+
 // Evaluate recomputes the passive "online" state immediately using the
 // same heuristic as the event engine (routes + iface + usable IP + DNS, etc.).
-func Evaluate() (bool, string, error) {
-	return recomputeOnline()
+func Evaluate(opts ...Option) (bool, string, error) {
+	st, err := passiveState(context.Background(), newConfig(opts), nil)
+	return st.online, st.why, err
+}
+
+// passiveState runs the custom evaluator if one is configured and
+// recomputeOnline otherwise, taking its result from cache while that is
+// fresh. The WireGuard handshake check and the captive portal detection
+// follow and, when online over a known interface, its classification and
+// the metered lookup, which only describe the host's own network
+// namespace.
+func passiveState(ctx context.Context, cfg *config, cache *evalCache) (netState, error) {
+	var st netState
+	var err error
+	if cfg.evaluator == nil {
+		st, err = cache.recompute(cfg)
+	} else {
+		var online bool
+		var why string
+		online, why, err = cfg.evaluator(ctx)
+		st = netState{online: online, why: why}
+	}
+	if err == nil && st.online && cfg.wireGuard && st.iface != "" {
+		if last, ok := wgLastHandshake(st.iface); ok && time.Since(last) > wgHandshakeMaxAge {
+			st.online, st.why = false, "wireguard handshake stale on "+st.iface
+		}
+	}
+	if err == nil && st.online && cfg.captive {
+		if url, ok := detectCaptivePortal(ctx); ok {
+			st.online, st.why, st.portal = false, "captive portal", url
+		}
+	}
+	if err == nil && st.online && st.iface != "" && cfg.netns == nil {
+		if st.kind == InterfaceOther { // unless recomputeOnline classified it already
+			st.kind = interfaceKind(st.iface)
+		}
+		st.metered = interfaceMetered(cfg, st.iface, st.kind)
+	} else {
+		st.kind = InterfaceOther
+	}
+	return st, err
+}
+
+// evalCache holds the last recomputeOnline result of a Watcher, so that
+// evaluations in quick succession, such as a fallback poll right after a
+// debounced one, do not query the kernel again. The watch loop invalidates
+// it with every OS event and before a directional debounce confirmation, so
+// a real change is always seen; only that goroutine uses it.
+type evalCache struct {
+	st    netState
+	err   error
+	at    time.Time
+	valid bool
+}
+
+// platformRecompute is recomputeOnline; tests replace it to count the
+// evaluations that reach the platform.
+var platformRecompute = recomputeOnline
+
+// recompute returns the cached result if it is younger than
+// cfg.evalCacheTTL and calls recomputeOnline otherwise. A nil cache always
+// calls it.
+func (c *evalCache) recompute(cfg *config) (netState, error) {
+	if c == nil || cfg.evalCacheTTL <= 0 {
+		return platformRecompute(cfg)
+	}
+	if c.valid && time.Since(c.at) < cfg.evalCacheTTL {
+		return c.st, c.err
+	}
+	st, err := platformRecompute(cfg)
+	*c = evalCache{st: st, err: err, at: time.Now(), valid: true}
+	return st, err
+}
+
+func (c *evalCache) invalidate() { c.valid = false }
+
+// portalURL returns st.portal as Event.CaptivePortalURL.
+func (st netState) portalURL() *string {
+	if st.portal == "" {
+		return nil
+	}
+	u := st.portal
+	return &u
 }
@@ -0,0 +1,77 @@
+package netonline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countRecompute replaces platformRecompute for the test with a stub that
+// reports online and sends on the returned channel for each call.
+func countRecompute(t *testing.T) <-chan struct{} {
+	t.Helper()
+	calls := make(chan struct{}, 16)
+	orig := platformRecompute
+	platformRecompute = func(*config) (netState, error) {
+		calls <- struct{}{}
+		return netState{online: true, why: "stub", iface: "eth0", index: 2}, nil
+	}
+	t.Cleanup(func() { platformRecompute = orig })
+	return calls
+}
+
+func TestEvalCacheTTL(t *testing.T) {
+	calls := countRecompute(t)
+	var c evalCache
+
+	cfg := newConfig([]Option{WithEvalCacheTTL(time.Hour)})
+	c.recompute(cfg)
+	c.recompute(cfg)
+	if n := len(calls); n != 1 {
+		t.Fatalf("two evaluations within the TTL reached the platform %d times, want 1", n)
+	}
+
+	cfg = newConfig([]Option{WithEvalCacheTTL(time.Millisecond)})
+	time.Sleep(2 * time.Millisecond)
+	c.recompute(cfg)
+	if n := len(calls); n != 2 {
+		t.Fatalf("an evaluation after the TTL expired was answered from the cache")
+	}
+
+	cfg = newConfig([]Option{WithEvalCacheTTL(0)})
+	c.recompute(cfg)
+	c.recompute(cfg)
+	if n := len(calls); n != 4 {
+		t.Fatalf("with the cache off, two evaluations reached the platform %d times, want 2", n-2)
+	}
+}
+
+func TestEvalCacheInvalidatedByOSEvent(t *testing.T) {
+	calls := countRecompute(t)
+	reasons := make(chan string)
+	src := func(ctx context.Context) (<-chan string, <-chan error) {
+		go func() {
+			<-ctx.Done()
+			close(reasons)
+		}()
+		return reasons, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := NewWatcher(ctx, WithEventSource(src), WithDebounce(0), WithEvalCacheTTL(time.Hour))
+	defer w.Stop()
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-calls:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s did not reach the platform", what)
+		}
+	}
+	wait("the initial evaluation")
+	for i := 0; i < 3; i++ {
+		reasons <- "route change"
+		wait("the evaluation after an OS event")
+	}
+}
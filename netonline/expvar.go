@@ -0,0 +1,79 @@
+package netonline
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// RegisterExpvars publishes the state of w through the expvar package, and
+// so at /debug/vars, under prefix (for example "netonline"):
+//
+//	prefix.online              bool, the last reported state
+//	prefix.cause               string, the cause of the last event
+//	prefix.events_total        number of events emitted, heartbeats excluded
+//	prefix.last_event_ts       RFC 3339 time of the last event
+//	prefix.probe_success_rate  fraction of successful probes per probe name
+//
+// Counting starts when RegisterExpvars is called. Like expvar.Publish, it
+// panics if a name is already registered.
+func RegisterExpvars(w *Watcher, prefix string) {
+	var (
+		mu     sync.Mutex
+		last   Event
+		total  uint64
+		probes = make(map[string]*probeTally)
+	)
+	w.Notify(func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !ev.IsHeartbeat() {
+			last = ev
+			total++
+		}
+		if ev.CheckResult != nil {
+			for _, r := range ev.CheckResult.Probes {
+				t := probes[r.Name]
+				if t == nil {
+					t = &probeTally{}
+					probes[r.Name] = t
+				}
+				t.total++
+				if r.Err == nil {
+					t.ok++
+				}
+			}
+		}
+	})
+	expvar.Publish(prefix+".online", expvar.Func(func() any {
+		online, _, _ := w.state()
+		return online
+	}))
+	expvar.Publish(prefix+".cause", expvar.Func(func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		return last.CauseDetail
+	}))
+	expvar.Publish(prefix+".events_total", expvar.Func(func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		return total
+	}))
+	expvar.Publish(prefix+".last_event_ts", expvar.Func(func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		if last.ChangedAt.IsZero() {
+			return ""
+		}
+		return last.ChangedAt.Format(time.RFC3339Nano)
+	}))
+	expvar.Publish(prefix+".probe_success_rate", expvar.Func(func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		rates := make(map[string]float64, len(probes))
+		for name, t := range probes {
+			rates[name] = float64(t.ok) / float64(t.total)
+		}
+		return rates
+	}))
+}
@@ -0,0 +1,109 @@
+package netonline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// geoLookupTimeout bounds the public IP and RDAP lookups of AutoSelectProbes.
+const geoLookupTimeout = 5 * time.Second
+
+// rdapIPURL is the RDAP bootstrap service queried for the country of the
+// public address.
+const rdapIPURL = "https://rdap.org/ip/"
+
+// regionalProbes holds probe sets for countries where the default endpoints
+// are commonly blocked, keyed by ISO 3166-1 alpha-2 code.
+var regionalProbes = map[string]func() []ProbeFunc{
+	"CN": func() []ProbeFunc {
+		return []ProbeFunc{
+			ProbeDNS("baidu.com"),
+			ProbeTCP("223.5.5.5:53"),    // AliDNS
+			ProbeTCP("119.29.29.29:53"), // DNSPod
+			ProbeHTTPDirect("http://connect.rom.miui.com/generate_204"),
+		}
+	},
+	"RU": func() []ProbeFunc {
+		return []ProbeFunc{
+			ProbeDNS("yandex.ru"),
+			ProbeTCP("77.88.8.8:53"), // Yandex DNS
+			ProbeTCP("77.88.8.1:53"),
+		}
+	},
+}
+
+// defaultProbes is the probe set of DefaultChecker.
+func defaultProbes() []namedProbe {
+	return []namedProbe{
+		{name: "dns:example.com", fn: ProbeDNS("example.com")},
+		{name: "dns:one.one.one.one", fn: ProbeDNS("one.one.one.one")},
+		{name: "tcp:1.1.1.1:443", fn: ProbeTCP("1.1.1.1:443")},
+		{name: "tcp:8.8.8.8:443", fn: ProbeTCP("8.8.8.8:443")},
+		{name: "http:gstatic204", fn: ProbeHTTPWithProxy("http://connectivitycheck.gstatic.com/generate_204")},
+		{name: "http:clients3.google", fn: ProbeHTTPWithProxy("http://clients3.google.com/generate_204")},
+	}
+}
+
+// AutoSelectProbes picks probe endpoints that are reachable from the user's
+// country. It learns the public address over STUN, looks up its country
+// through RDAP and returns a regional set where the default endpoints
+// (1.1.1.1, 8.8.8.8, gstatic.com) are commonly blocked, such as China and
+// Russia. Everywhere else, and whenever the country cannot be determined,
+// it returns the DefaultChecker probes. An error is only returned if ctx is
+// done.
+func AutoSelectProbes(ctx context.Context) ([]ProbeFunc, error) {
+	country, _ := lookupCountry(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if set, ok := regionalProbes[country]; ok {
+		return set(), nil
+	}
+	var fns []ProbeFunc
+	for _, p := range defaultProbes() {
+		fns = append(fns, p.fn)
+	}
+	return fns, nil
+}
+
+// lookupCountry returns the country code RDAP reports for the public address.
+func lookupCountry(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, geoLookupTimeout)
+	defer cancel()
+	ip, err := stunPublicIP(ctx, defaultSTUNServer)
+	if err != nil {
+		return "", err
+	}
+	return rdapCountry(ctx, ip)
+}
+
+func rdapCountry(ctx context.Context, ip net.IP) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rdapIPURL+ip.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("rdap: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Country string `json:"country"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Country == "" {
+		return "", fmt.Errorf("rdap: no country for %s", ip)
+	}
+	return strings.ToUpper(body.Country), nil
+}
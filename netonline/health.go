@@ -0,0 +1,121 @@
+package netonline
+
+import "sync"
+
+const (
+	// healthMinSamples is the number of outcomes needed before an endpoint
+	// can be deprioritized.
+	healthMinSamples = 5
+	// healthFailThreshold is the failure rate above which an endpoint is
+	// deprioritized.
+	healthFailThreshold = 0.8
+	// healthRetryEvery is how many checks a deprioritized endpoint sits out
+	// before it is tried again (circuit-breaker half-open).
+	healthRetryEvery = 5
+)
+
+// ProbeEndpointHealth tracks per-probe failure rates over a sliding window.
+// Attached to a ConnectivityChecker (ConnectivityChecker.Health), it lets the
+// checker skip endpoints that fail more than 80% of the time, such as an
+// endpoint blocked in the local network. Skipped endpoints are still tried
+// every few checks so a recovery is noticed; one success closes the breaker.
+// It is safe for concurrent use.
+type ProbeEndpointHealth struct {
+	mu     sync.Mutex
+	window int
+	stats  map[string]*endpointStats
+}
+
+type endpointStats struct {
+	outcomes []bool // ring buffer, true = success
+	next     int
+	filled   int
+	skipped  int
+}
+
+// NewProbeEndpointHealth returns a tracker that remembers the last window
+// outcomes of each probe. A window below healthMinSamples is raised to it.
+func NewProbeEndpointHealth(window int) *ProbeEndpointHealth {
+	if window < healthMinSamples {
+		window = healthMinSamples
+	}
+	return &ProbeEndpointHealth{window: window, stats: make(map[string]*endpointStats)}
+}
+
+// Record adds one probe outcome.
+func (h *ProbeEndpointHealth) Record(name string, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(name)
+	if ok && h.deprioritized(s) {
+		// Half-open probe succeeded: forget the failure history.
+		*s = endpointStats{outcomes: s.outcomes[:0]}
+	}
+	if len(s.outcomes) < h.window {
+		s.outcomes = append(s.outcomes, ok)
+	} else {
+		s.outcomes[s.next] = ok
+	}
+	s.next = (s.next + 1) % h.window
+	if s.filled < h.window {
+		s.filled++
+	}
+}
+
+// FailureRate reports the failure rate of name over the current window, or 0
+// if nothing has been recorded yet.
+func (h *ProbeEndpointHealth) FailureRate(name string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failureRate(h.get(name))
+}
+
+// Deprioritized reports whether name is currently being skipped.
+func (h *ProbeEndpointHealth) Deprioritized(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.deprioritized(h.get(name))
+}
+
+// shouldRun decides whether name takes part in the next check. Healthy
+// endpoints always run; deprioritized ones run every healthRetryEvery calls.
+func (h *ProbeEndpointHealth) shouldRun(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(name)
+	if !h.deprioritized(s) {
+		return true
+	}
+	s.skipped++
+	if s.skipped >= healthRetryEvery {
+		s.skipped = 0
+		return true
+	}
+	return false
+}
+
+func (h *ProbeEndpointHealth) get(name string) *endpointStats {
+	s, ok := h.stats[name]
+	if !ok {
+		s = &endpointStats{}
+		h.stats[name] = s
+	}
+	return s
+}
+
+func (h *ProbeEndpointHealth) failureRate(s *endpointStats) float64 {
+	if s.filled == 0 {
+		return 0
+	}
+	fails := 0
+	for _, ok := range s.outcomes {
+		if !ok {
+			fails++
+		}
+	}
+	return float64(fails) / float64(s.filled)
+}
+
+func (h *ProbeEndpointHealth) deprioritized(s *endpointStats) bool {
+	return s.filled >= healthMinSamples && h.failureRate(s) > healthFailThreshold
+}
@@ -0,0 +1,51 @@
+package netonline_test
+
+import (
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// nextEvent returns the next event, failing the test after 2 seconds.
+func nextEvent(t *testing.T, events <-chan netonline.Event) netonline.Event {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+	}
+	return netonline.Event{}
+}
+
+// noPendingEvent fails the test if an event is waiting. After
+// MockPlatform.InjectOSEvent or Advance returns, anything the watcher
+// emitted is already there.
+func noPendingEvent(t *testing.T, events <-chan netonline.Event) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %v", ev)
+	default:
+	}
+}
+
+// drain reads both channels until they are closed.
+func drain(events <-chan netonline.Event, errs <-chan error) {
+	for events != nil || errs != nil {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+			}
+		case _, ok := <-errs:
+			if !ok {
+				errs = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package netonline
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// HistoryWatcher is a Watcher that keeps its last events and replays them to
+// every new subscriber; see WatchWithHistory.
+type HistoryWatcher struct {
+	w    *Watcher
+	size int
+	done chan struct{}
+
+	mu     sync.Mutex
+	ring   []Event // oldest first
+	subs   map[*historySub]struct{}
+	closed bool
+}
+
+type historySub struct {
+	out  chan Event
+	wake chan struct{}
+
+	mu     sync.Mutex
+	queue  []Event
+	closed bool
+}
+
+// WatchWithHistory starts a Watcher and keeps its last size events, so that
+// a late subscriber learns the current state from the same stream as the
+// changes that follow, without racing a separate Evaluate. The watcher's
+// errors are discarded; log them with WithLogger. It fails if size is not
+// positive.
+func WatchWithHistory(ctx context.Context, size int, opts ...WatchOption) (*HistoryWatcher, error) {
+	if size <= 0 {
+		return nil, errors.New("netonline: history size must be positive")
+	}
+	h := &HistoryWatcher{
+		w:    NewWatcher(ctx, opts...),
+		size: size,
+		done: make(chan struct{}),
+		subs: make(map[*historySub]struct{}),
+	}
+	go func() {
+		for range h.w.Errors() {
+		}
+	}()
+	go h.run()
+	return h, nil
+}
+
+func (h *HistoryWatcher) run() {
+	defer close(h.done)
+	for ev := range h.w.Events() {
+		h.mu.Lock()
+		if len(h.ring) == h.size {
+			copy(h.ring, h.ring[1:])
+			h.ring = h.ring[:h.size-1]
+		}
+		h.ring = append(h.ring, ev)
+		for s := range h.subs {
+			s.push(ev)
+		}
+		h.mu.Unlock()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		s.close()
+		delete(h.subs, s)
+	}
+}
+
+// Subscribe returns a channel that first delivers the buffered events,
+// oldest first, then every new one, without dropping any. It is closed after
+// the last event once the HistoryWatcher is closed or its context is
+// cancelled. Subscribers must read until then.
+func (h *HistoryWatcher) Subscribe() <-chan Event {
+	s := &historySub{out: make(chan Event), wake: make(chan struct{}, 1)}
+	h.mu.Lock()
+	s.queue = append(s.queue, h.ring...)
+	if h.closed {
+		s.closed = true
+	} else {
+		h.subs[s] = struct{}{}
+	}
+	h.mu.Unlock()
+	go s.run()
+	return s.out
+}
+
+// Snapshot returns a copy of the buffered events, oldest first.
+func (h *HistoryWatcher) Snapshot() []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Event(nil), h.ring...)
+}
+
+// Close stops the watcher. Subscriber channels still deliver the events
+// queued for them and are closed after the last one.
+func (h *HistoryWatcher) Close() {
+	h.w.Stop()
+	<-h.done
+}
+
+func (s *historySub) push(ev Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, ev)
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *historySub) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.signal()
+}
+
+func (s *historySub) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *historySub) run() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			<-s.wake
+			continue
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		s.out <- ev
+	}
+}
@@ -0,0 +1,84 @@
+package netonline
+
+import (
+	"context"
+	"time"
+)
+
+// Clock creates the watcher's timers: the debounce of OS events and state
+// changes, the heartbeat, the stabilization delay, and the reconnect backoff
+// and fallback poll after a lost OS stream; see WithClock.
+type Clock interface {
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// ClockTimer is the part of *time.Timer the watcher uses, with the channel
+// behind a method.
+type ClockTimer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) ClockTimer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// WithClock makes the watcher take all its timers from c instead of the
+// time package, so that tests can fire them deterministically. See
+// netonlinetesting.MockPlatform.
+func WithClock(c Clock) Option {
+	return newOption(func(cfg *config) {
+		if c != nil {
+			cfg.clock = c
+		}
+	})
+}
+
+// WithEventSource replaces the OS change notifications (netlink, the routing
+// socket, IP Helper) with src. src is called when the watcher starts, and
+// again after its channel is closed while the watcher runs, like a lost OS
+// stream; it should close both channels once ctx is done. Each string
+// received is the reason for a change. It ends up in Event.CauseDetail and
+// selects Event.Cause: the reasons of the OS sources, such as "route
+// change" or "addr change", and "wake" map to their CauseCode, anything
+// else to CauseOther.
+// Combined with WithCustomEvaluator and WithClock, this runs a watcher
+// without touching the host's network state.
+func WithEventSource(src func(ctx context.Context) (<-chan string, <-chan error)) Option {
+	return newOption(func(cfg *config) { cfg.eventSource = src })
+}
+
+// WithStepHook makes the watcher call fn each time its loop has handled an
+// OS event or a timer firing, after it has armed the timers that follow,
+// and before it waits for the next one. A test driving the watcher with
+// WithEventSource and WithClock uses it to know when the watcher is idle
+// instead of sleeping. fn runs on the watcher goroutine and must not block.
+func WithStepHook(fn func()) Option {
+	return newOption(func(cfg *config) { cfg.stepHook = fn })
+}
+
+// startEventStream starts the event source configured with WithEventSource,
+// or the OS one.
+func startEventStream(ctx context.Context, cfg *config) (<-chan osEvent, <-chan error) {
+	if cfg.eventSource == nil {
+		return startOSEventStream(ctx, cfg)
+	}
+	reasons, errs := cfg.eventSource(ctx)
+	out := make(chan osEvent)
+	go func() {
+		defer close(out)
+		for reason := range reasons {
+			select {
+			case out <- osEvent{reason: reason}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
@@ -0,0 +1,52 @@
+package netonline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// icmpTimeout bounds an ICMP echo whose context has no deadline.
+const icmpTimeout = 2 * time.Second
+
+// ICMPProbe returns a Probe named "icmp:" + dst that sends one ICMP echo
+// request to dst (an IP address or host name) and waits for the reply until
+// the context's deadline, or 2 seconds without one. It is meant for
+// networks that let ICMP through but block outbound TCP and HTTP.
+//
+// Privileges: on Linux the probe first tries an unprivileged ICMP datagram
+// socket, which the kernel only allows for groups listed in the
+// net.ipv4.ping_group_range sysctl, and then a raw socket, which needs
+// root or CAP_NET_RAW. macOS allows the unprivileged socket to every user.
+// On Windows it uses IcmpSendEcho2Ex from iphlpapi, which needs no special
+// privilege but only supports IPv4. If no method is available, the probe
+// fails with an error naming what is missing.
+func ICMPProbe(dst string) Probe {
+	return NamedProbe("icmp:"+dst, func(ctx context.Context) error {
+		ip, err := resolveICMPTarget(ctx, dst)
+		if err != nil {
+			return err
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, icmpTimeout)
+			defer cancel()
+		}
+		return icmpEcho(ctx, ip)
+	})
+}
+
+func resolveICMPTarget(ctx context.Context, dst string) (net.IP, error) {
+	if ip := net.ParseIP(dst); ip != nil {
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("icmp: no address for %s", dst)
+	}
+	return addrs[0].IP, nil
+}
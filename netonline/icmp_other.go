@@ -0,0 +1,79 @@
+//go:build !windows
+
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// icmpEcho sends an echo request to ip over an unprivileged ICMP datagram
+// socket, or a raw socket if that is not permitted, and waits for the
+// reply.
+func icmpEcho(ctx context.Context, ip net.IP) error {
+	udpNet, rawNet, laddr, proto := "udp4", "ip4:icmp", "0.0.0.0", 1
+	var typ, reply icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		udpNet, rawNet, laddr, proto = "udp6", "ip6:ipv6-icmp", "::", 58
+		typ, reply = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	raw := false
+	conn, udpErr := icmp.ListenPacket(udpNet, laddr)
+	if udpErr != nil {
+		var rawErr error
+		conn, rawErr = icmp.ListenPacket(rawNet, laddr)
+		if rawErr != nil {
+			return fmt.Errorf("icmp: no unprivileged ICMP socket (%v; see net.ipv4.ping_group_range) and no raw socket (%v; needs CAP_NET_RAW)", udpErr, rawErr)
+		}
+		raw = true
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	// The kernel replaces the identifier of datagram sockets with the
+	// local port, so replies are matched by sequence number there.
+	id, seq := os.Getpid()&0xffff, int(time.Now().UnixNano()&0xffff)
+	msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("netonline")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if raw {
+		dst = &net.IPAddr{IP: ip}
+	}
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return fmt.Errorf("icmp: send: %w", err)
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if cerr := ctx.Err(); cerr != nil {
+				err = cerr
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) || ctx.Err() != nil {
+				return fmt.Errorf("icmp: no echo reply from %s: %w", ip, err)
+			}
+			return fmt.Errorf("icmp: receive: %w", err)
+		}
+		m, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || m.Type != reply {
+			continue
+		}
+		if e, ok := m.Body.(*icmp.Echo); ok && e.Seq == seq && (!raw || e.ID == id) {
+			return nil
+		}
+	}
+}
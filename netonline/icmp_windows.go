@@ -0,0 +1,82 @@
+//go:build windows
+
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procIcmpCreateFile  = iphlpapi.NewProc("IcmpCreateFile")
+	procIcmpCloseHandle = iphlpapi.NewProc("IcmpCloseHandle")
+	procIcmpSendEcho2Ex = iphlpapi.NewProc("IcmpSendEcho2Ex")
+)
+
+// icmpEchoReply mirrors ICMP_ECHO_REPLY.
+type icmpEchoReply struct {
+	Address       uint32
+	Status        uint32
+	RoundTripTime uint32
+	DataSize      uint16
+	Reserved      uint16
+	Data          uintptr
+	Options       struct { // IP_OPTION_INFORMATION
+		Ttl, Tos, Flags, OptionsSize uint8
+		OptionsData                  uintptr
+	}
+}
+
+// icmpEcho sends an echo request to ip with IcmpSendEcho2Ex, which needs no
+// administrator rights. IPv6 (Icmp6SendEcho2) is not supported.
+func icmpEcho(ctx context.Context, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return fmt.Errorf("icmp: IPv6 echo is not supported on Windows (%s)", ip)
+	}
+	if err := procIcmpSendEcho2Ex.Find(); err != nil {
+		return fmt.Errorf("%w: IcmpSendEcho2Ex unavailable: %w", ErrWin32API, err)
+	}
+	h, _, e := procIcmpCreateFile.Call()
+	if windows.Handle(h) == windows.InvalidHandle {
+		return fmt.Errorf("%w: IcmpCreateFile: %w", ErrWin32API, e)
+	}
+	defer procIcmpCloseHandle.Call(h)
+
+	timeout := icmpTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("icmp: no echo reply from %s: %w", ip, context.DeadlineExceeded)
+	}
+	data := []byte("netonline")
+	reply := make([]byte, int(unsafe.Sizeof(icmpEchoReply{}))+len(data)+8+16) // + ICMP error + IO_STATUS_BLOCK
+	dst := *(*uint32)(unsafe.Pointer(&ip4[0]))                                // network byte order, as IPAddr
+	n, _, e := procIcmpSendEcho2Ex.Call(
+		h, 0, 0, 0,
+		0, // any source address
+		uintptr(dst),
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)),
+		0,
+		uintptr(unsafe.Pointer(&reply[0])), uintptr(len(reply)),
+		uintptr(timeout.Milliseconds()),
+	)
+	if n == 0 {
+		var errno windows.Errno
+		if errors.As(e, &errno) && errno == windows.Errno(11010) { // IP_REQ_TIMED_OUT
+			return fmt.Errorf("icmp: no echo reply from %s: %w", ip, context.DeadlineExceeded)
+		}
+		return fmt.Errorf("%w: IcmpSendEcho2Ex: %w", ErrWin32API, e)
+	}
+	if r := (*icmpEchoReply)(unsafe.Pointer(&reply[0])); r.Status != 0 {
+		return fmt.Errorf("icmp: echo to %s failed with IP status %d", ip, r.Status)
+	}
+	return nil
+}
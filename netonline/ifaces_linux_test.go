@@ -0,0 +1,153 @@
+package netonline
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// benchIfaces is the number of interfaces in the benchmark namespace, about
+// what a busy Kubernetes node has in veth pairs.
+const benchIfaces = 500
+
+// benchNetns creates a network namespace with benchIfaces veth interfaces,
+// the last of which carries the default route, and a linuxProcRoot fixture
+// with their sysfs state and that route: /sys shows the namespace of
+// whoever mounted it, not the one of the reading thread. It returns the namespace handle and
+// the default interface, and skips without root.
+func benchNetns(b *testing.B) (*os.File, string) {
+	b.Helper()
+	if os.Geteuid() != 0 {
+		b.Skip("needs root for network namespaces")
+	}
+	if _, err := exec.LookPath("nsenter"); err != nil {
+		b.Skip("nsenter not installed")
+	}
+	ch := make(chan *os.File)
+	go func() {
+		runtime.LockOSThread() // never unlocked, see enterNetns
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			ch <- nil
+			return
+		}
+		f, _ := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		ch <- f
+	}()
+	ns := <-ch
+	if ns == nil {
+		b.Skip("cannot create a network namespace")
+	}
+	b.Cleanup(func() { ns.Close() })
+
+	root := b.TempDir()
+	var batch strings.Builder
+	for i := 0; i < benchIfaces/2; i++ {
+		fmt.Fprintf(&batch, "link add veth%d type veth peer name peer%d\n", i, i)
+		for _, name := range []string{fmt.Sprintf("veth%d", i), fmt.Sprintf("peer%d", i)} {
+			dir := filepath.Join(root, "sys/class/net", name)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				b.Fatal(err)
+			}
+			os.WriteFile(filepath.Join(dir, "operstate"), []byte("up\n"), 0o644)
+			os.WriteFile(filepath.Join(dir, "carrier"), []byte("1\n"), 0o644)
+		}
+	}
+	def, peer := fmt.Sprintf("veth%d", benchIfaces/2-1), fmt.Sprintf("peer%d", benchIfaces/2-1)
+	fmt.Fprintf(&batch, "link set %s up\nlink set %s up\naddr add 10.99.0.2/24 dev %s\nroute add default via 10.99.0.1 dev %s\n", peer, def, def, def)
+	cmd := exec.Command("nsenter", "--net=/proc/self/fd/3", "ip", "-batch", "-")
+	cmd.ExtraFiles = []*os.File{ns}
+	cmd.Stdin = strings.NewReader(batch.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		b.Fatalf("ip -batch: %v: %s", err, out)
+	}
+
+	// Under the fixture the default route comes from its proc/net/route,
+	// with the gateway in little-endian hex.
+	route := "Iface\tDestination\tGateway\tFlags\tRefCnt\tUse\tMetric\tMask\tMTU\tWindow\tIRTT\n" +
+		def + "\t00000000\t0100630A\t0003\t0\t0\t0\t00000000\t0\t0\t0\n"
+	if err := os.MkdirAll(filepath.Join(root, "proc/net"), 0o755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "proc/net/route"), []byte(route), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	old := linuxProcRoot
+	linuxProcRoot = root
+	b.Cleanup(func() { linuxProcRoot = old })
+	return ns, def
+}
+
+// enterNetns moves the calling goroutine into ns for good. Every benchmark
+// run happens on a new goroutine, and its thread is never unlocked, so it
+// exits with the goroutine instead of returning to the scheduler in ns.
+func enterNetns(b *testing.B, ns *os.File) {
+	runtime.LockOSThread()
+	if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// enumerateDefaultIface is the lookup linuxRecompute made before it used
+// per-interface ioctls: the net package, which dumps every link, resolves
+// the default route's index and reads the flags.
+func enumerateDefaultIface() (string, bool) {
+	_, idx, _, err := linuxDefaultRoute()
+	if err != nil {
+		return "", false
+	}
+	ifaces, _ := net.Interfaces()
+	name := ""
+	for _, it := range ifaces {
+		if it.Index == idx {
+			name = it.Name
+		}
+	}
+	ifi, err := net.InterfaceByName(name)
+	if err != nil || ifi.Flags&net.FlagUp == 0 {
+		return "", false
+	}
+	up, _ := linuxIfaceUp(name, true)
+	return name, up
+}
+
+// targetedDefaultIface is the lookup linuxRecompute makes.
+func targetedDefaultIface() (string, bool) {
+	_, idx, _, err := linuxDefaultRoute()
+	if err != nil {
+		return "", false
+	}
+	name := ifIndexToName(idx)
+	up, _ := linuxIfaceUp(name, true)
+	return name, up
+}
+
+func BenchmarkDefaultIfaceLookup(b *testing.B) {
+	ns, def := benchNetns(b)
+	for _, bm := range []struct {
+		name   string
+		lookup func() (string, bool)
+	}{
+		{"Targeted", targetedDefaultIface},
+		{"Enumerate", enumerateDefaultIface},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			enterNetns(b, ns)
+			if name, up := bm.lookup(); name != def || !up {
+				b.Fatalf("lookup = %q, up %v; want %q, up", name, up, def)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bm.lookup()
+			}
+		})
+	}
+}
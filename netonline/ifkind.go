@@ -0,0 +1,80 @@
+package netonline
+
+import "strings"
+
+// InterfaceKind is the link type of a network interface.
+type InterfaceKind int
+
+const (
+	InterfaceOther    InterfaceKind = iota // unknown or unclassified
+	InterfaceEthernet                      // wired Ethernet
+	InterfaceWiFi                          // IEEE 802.11
+	InterfaceCellular                      // mobile broadband (WWAN)
+	InterfaceTunnel                        // VPN, tun/tap and IP-in-IP tunnels
+	InterfaceLoopback                      // the host's loopback interface
+	InterfaceVirtual                       // bridges, veth pairs and hypervisor adapters
+)
+
+func (k InterfaceKind) String() string {
+	switch k {
+	case InterfaceEthernet:
+		return "ethernet"
+	case InterfaceWiFi:
+		return "wifi"
+	case InterfaceCellular:
+		return "cellular"
+	case InterfaceTunnel:
+		return "tunnel"
+	case InterfaceLoopback:
+		return "loopback"
+	case InterfaceVirtual:
+		return "virtual"
+	}
+	return "other"
+}
+
+// interfaceKindFromName classifies ifname by common Linux naming
+// conventions, for interfaces the kernel does not describe well enough.
+func interfaceKindFromName(ifname string) (InterfaceKind, bool) {
+	for _, p := range []struct {
+		prefix string
+		kind   InterfaceKind
+	}{
+		{"wlan", InterfaceWiFi}, {"wwan", InterfaceCellular},
+		{"tun", InterfaceTunnel}, {"tap", InterfaceTunnel},
+		{"docker", InterfaceVirtual}, {"veth", InterfaceVirtual}, {"br-", InterfaceVirtual}, {"virbr", InterfaceVirtual},
+	} {
+		if strings.HasPrefix(ifname, p.prefix) {
+			return p.kind, true
+		}
+	}
+	return InterfaceOther, false
+}
+
+// ifaceFiltered returns why the interface named ifname is ruled out by
+// WithExcludeInterfaces or WithInterfaceTypeFilter, or "" if it is not.
+func (c *config) ifaceFiltered(ifname string) string {
+	if len(c.ifaceKinds) == 0 {
+		return c.ifaceFilteredKind(ifname, InterfaceOther)
+	}
+	return c.ifaceFilteredKind(ifname, interfaceKind(ifname))
+}
+
+// ifaceFilteredKind is ifaceFiltered for an interface already classified
+// as kind.
+func (c *config) ifaceFilteredKind(ifname string, kind InterfaceKind) string {
+	for _, ex := range c.excluded {
+		if ex == ifname {
+			return "default iface excluded"
+		}
+	}
+	if len(c.ifaceKinds) == 0 {
+		return ""
+	}
+	for _, want := range c.ifaceKinds {
+		if kind == want {
+			return ""
+		}
+	}
+	return "default iface type " + kind.String() + " filtered"
+}
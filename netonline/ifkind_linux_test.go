@@ -0,0 +1,62 @@
+package netonline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// kindFixture points linuxProcRoot at a sysfs with a wired Ethernet
+// interface, enp3s0, and a WiFi one, wlp2s0, whose names alone say nothing
+// of their kind.
+func kindFixture(t *testing.T) {
+	t.Helper()
+	root := t.TempDir()
+	for name, files := range map[string]map[string]string{
+		"enp3s0": {"type": "1\n", "uevent": "INTERFACE=enp3s0\nIFINDEX=2\n"},
+		"wlp2s0": {"type": "1\n", "uevent": "DEVTYPE=wlan\nINTERFACE=wlp2s0\nIFINDEX=3\n"},
+	} {
+		dir := filepath.Join(root, "sys/class/net", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for f, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, f), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	old := linuxProcRoot
+	linuxProcRoot = root
+	t.Cleanup(func() { linuxProcRoot = old })
+}
+
+// TestInterfaceTypeFilter checks the kind filter linuxRecompute applies to
+// the default interface: with only one kind allowed, a default route
+// through an interface of another kind counts as offline.
+func TestInterfaceTypeFilter(t *testing.T) {
+	kindFixture(t)
+	tests := []struct {
+		name    string
+		kinds   []InterfaceKind
+		iface   string
+		wantWhy string
+	}{
+		{"wifi only, ethernet default", []InterfaceKind{InterfaceWiFi}, "enp3s0", "default iface type ethernet filtered"},
+		{"ethernet only, wifi default", []InterfaceKind{InterfaceEthernet}, "wlp2s0", "default iface type wifi filtered"},
+		{"wifi only, wifi default", []InterfaceKind{InterfaceWiFi}, "wlp2s0", ""},
+		{"ethernet or wifi", []InterfaceKind{InterfaceEthernet, InterfaceWiFi}, "enp3s0", ""},
+		{"no filter", nil, "wlp2s0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.kinds != nil {
+				opts = append(opts, WithInterfaceTypeFilter(tt.kinds...))
+			}
+			if why := newConfig(opts).ifaceFiltered(tt.iface); why != tt.wantWhy {
+				t.Errorf("ifaceFiltered(%q) = %q, want %q", tt.iface, why, tt.wantWhy)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package netonline
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+	"time"
+)
+
+// InterfaceEvent reports the state of one network interface; see
+// WatchInterfaceStates.
+type InterfaceEvent struct {
+	Name       string
+	Index      int
+	Up         bool // administratively up (net.FlagUp)
+	HasCarrier bool // operationally up, e.g. a cable or an association (net.FlagRunning)
+	Addrs      []net.IPNet
+	ChangedAt  time.Time
+}
+
+// InterfaceStateOption configures WatchInterfaceStates.
+type InterfaceStateOption func(*ifStateConfig)
+
+type ifStateConfig struct {
+	noLoopback bool
+}
+
+// WithoutLoopback leaves loopback interfaces out of WatchInterfaceStates.
+func WithoutLoopback() InterfaceStateOption {
+	return func(c *ifStateConfig) { c.noLoopback = true }
+}
+
+// WatchInterfaceStates reports each interface's state separately, for
+// applications that manage several network paths, such as bonding members
+// or failover links, rather than a single online state. It first sends an
+// event for every interface, in index order, then one whenever an
+// interface's flags, addresses or name change after an OS change
+// notification; a removed interface is reported once more as down, without
+// carrier or addresses.
+// On Linux it always listens on netlink, which reports address changes
+// that NetworkManager's signals do not. Both channels are closed once ctx
+// is cancelled or the OS event stream ends.
+func WatchInterfaceStates(ctx context.Context, opts ...InterfaceStateOption) (<-chan InterfaceEvent, <-chan error) {
+	ic := &ifStateConfig{}
+	for _, o := range opts {
+		o(ic)
+	}
+	out := make(chan InterfaceEvent, 16)
+	errc := make(chan error, 1)
+	events, errs := startOSEventStream(ctx, newConfig([]Option{WithNetworkManagerIntegration(false)}))
+	go func() {
+		defer close(out)
+		defer close(errc)
+		send := func(ev InterfaceEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		sendErr := func(err error) bool {
+			select {
+			case errc <- err:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		prev, err := interfaceStates(ic)
+		if err != nil && !sendErr(newWatchError(ErrKindInternal, err)) {
+			return
+		}
+		for _, idx := range slices.Sorted(maps.Keys(prev)) {
+			if !send(prev[idx]) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				cur, err := interfaceStates(ic)
+				if err != nil {
+					if !sendErr(newWatchError(ErrKindInternal, err)) {
+						return
+					}
+					continue
+				}
+				for _, idx := range slices.Sorted(maps.Keys(cur)) {
+					ev := cur[idx]
+					if old, ok := prev[idx]; ok && sameInterfaceState(old, ev) {
+						continue
+					}
+					if !send(ev) {
+						return
+					}
+				}
+				for idx, ev := range prev {
+					if _, ok := cur[idx]; ok {
+						continue
+					}
+					if !send(InterfaceEvent{Name: ev.Name, Index: idx, ChangedAt: time.Now()}) {
+						return
+					}
+				}
+				prev = cur
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				if !sendErr(err) {
+					return
+				}
+			}
+		}
+	}()
+	return out, errc
+}
+
+// interfaceStates lists the interfaces by index. An interface whose
+// addresses cannot be read is reported without them.
+func interfaceStates(ic *ifStateConfig) (map[int]InterfaceEvent, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	m := make(map[int]InterfaceEvent, len(ifs))
+	for _, ifi := range ifs {
+		if ic.noLoopback && ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ev := InterfaceEvent{
+			Name:       ifi.Name,
+			Index:      ifi.Index,
+			Up:         ifi.Flags&net.FlagUp != 0,
+			HasCarrier: ifi.Flags&net.FlagRunning != 0,
+			ChangedAt:  now,
+		}
+		addrs, _ := ifi.Addrs()
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				ev.Addrs = append(ev.Addrs, *ipn)
+			}
+		}
+		m[ifi.Index] = ev
+	}
+	return m, nil
+}
+
+func sameInterfaceState(a, b InterfaceEvent) bool {
+	return a.Name == b.Name && a.Up == b.Up && a.HasCarrier == b.HasCarrier &&
+		slices.EqualFunc(a.Addrs, b.Addrs, func(x, y net.IPNet) bool {
+			return x.IP.Equal(y.IP) && slices.Equal(x.Mask, y.Mask)
+		})
+}
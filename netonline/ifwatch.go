@@ -0,0 +1,96 @@
+package netonline
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// InterfaceListEvent reports interfaces that appeared or disappeared, for
+// example when a USB Ethernet adapter is plugged in.
+type InterfaceListEvent struct {
+	ChangedAt time.Time
+	Added     []net.Interface
+	Removed   []net.Interface
+}
+
+// WatchInterfaces reports changes to the list of network interfaces,
+// independent of the online state. After every OS change notification it
+// lists the interfaces again and compares them, by index, with the previous
+// list. Both channels are closed once ctx is cancelled or the OS event
+// stream ends.
+func WatchInterfaces(ctx context.Context) (<-chan InterfaceListEvent, <-chan error) {
+	out := make(chan InterfaceListEvent, 1)
+	errc := make(chan error, 1)
+	prev, err := interfaceSnapshot()
+	if err != nil {
+		errc <- newWatchError(ErrKindInternal, err)
+	}
+	events, errs := startOSEventStream(ctx, newConfig(nil))
+	go func() {
+		defer close(out)
+		defer close(errc)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				cur, err := interfaceSnapshot()
+				if err != nil {
+					select {
+					case errc <- newWatchError(ErrKindInternal, err):
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				ev := InterfaceListEvent{ChangedAt: time.Now()}
+				for idx, ifi := range cur {
+					if _, ok := prev[idx]; !ok {
+						ev.Added = append(ev.Added, ifi)
+					}
+				}
+				for idx, ifi := range prev {
+					if _, ok := cur[idx]; !ok {
+						ev.Removed = append(ev.Removed, ifi)
+					}
+				}
+				prev = cur
+				if len(ev.Added) == 0 && len(ev.Removed) == 0 {
+					continue
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				select {
+				case errc <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, errc
+}
+
+func interfaceSnapshot() (map[int]net.Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[int]net.Interface, len(ifs))
+	for _, ifi := range ifs {
+		m[ifi.Index] = ifi
+	}
+	return m, nil
+}
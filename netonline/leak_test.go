@@ -0,0 +1,63 @@
+package netonline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"example.com/netonline/netonline"
+	"example.com/netonline/netonline/netonlinetesting"
+)
+
+// TestNoGoroutineLeaks checks that every public API that starts goroutines
+// stops them once it is cancelled or stopped. The watchers run on a
+// MockPlatform, so the OS event sources are not involved.
+func TestNoGoroutineLeaks(t *testing.T) {
+	t.Run("Watch", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		m := netonlinetesting.NewMockPlatform()
+		ctx, cancel := context.WithCancel(context.Background())
+		events, errs := netonline.Watch(ctx, m.Options()...)
+		<-events
+		m.InjectOSEvent("route change")
+		cancel()
+		drain(events, errs)
+	})
+
+	t.Run("StartWakeGapWatcher", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		wake := netonline.StartWakeGapWatcher(ctx, time.Millisecond, time.Hour)
+		cancel()
+		for range wake {
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if res := netonline.DefaultChecker().Check(ctx); res.OK {
+			t.Errorf("Check with a cancelled context passed: %+v", res)
+		}
+	})
+
+	t.Run("NewWatcher", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		m := netonlinetesting.NewMockPlatform()
+		w := netonline.NewWatcher(context.Background(), m.Options()...)
+		<-w.Events()
+		w.Stop()
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+		m := netonlinetesting.NewMockPlatform()
+		for i := 0; i < 100; i++ {
+			w := netonline.NewWatcher(context.Background(), append(m.Options(), netonline.WithHeartbeatInterval(time.Second))...)
+			w.Stop()
+		}
+	})
+}
@@ -14,51 +14,197 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
-func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
+// linuxProcRoot is prepended to every /proc and /sys path the Linux code
+// reads. It is empty in production; tests point it at a directory of
+// fixture files to exercise the parsers without real interfaces.
+var linuxProcRoot = ""
+
+// procPath returns path (an absolute /proc or /sys path) under linuxProcRoot.
+func procPath(path string) string { return linuxProcRoot + path }
+
+// mergeOSEventStreams forwards the events and errors of two streams, either
+// of which may be nil. It ends as soon as one of them does, cancelling the
+// other through cancel, so that the watcher restarts them together.
+func mergeOSEventStreams(ctx context.Context, cancel context.CancelFunc, a <-chan osEvent, aerr <-chan error, b <-chan osEvent, berr <-chan error) (<-chan osEvent, <-chan error) {
+	out := make(chan osEvent, 8)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out); defer close(errc); defer cancel()
+		sendErr := func(err error) bool {
+			select { case errc <- err: return true; case <-ctx.Done(): return false }
+		}
+		// A stream closes its error channel before its event channel, so
+		// what it reported last is still read once its events have ended.
+		ended := func(errs <-chan error) {
+			if errs == nil { return }
+			for err := range errs { if !sendErr(err) { return } }
+		}
+		for {
+			var ev osEvent; var ok bool
+			select {
+			case <-ctx.Done(): return
+			case ev, ok = <-a: if !ok { ended(aerr); return }
+			case ev, ok = <-b: if !ok { ended(berr); return }
+			case err, ok := <-aerr: if !ok { aerr = nil; continue }; if !sendErr(err) { return }; continue
+			case err, ok := <-berr: if !ok { berr = nil; continue }; if !sendErr(err) { return }; continue
+			}
+			select { case out <- ev: case <-ctx.Done(): return }
+		}
+	}()
+	return out, errc
+}
+
+// Replaced in tests to force the ENOBUFS recovery paths.
+var (
+	netlinkRecvfrom     = unix.Recvfrom
+	reopenNetlinkSocket = openNetlinkSocket
+)
+
+// startNetlinkEventStream reads change notifications from fd, a socket
+// from openNetlinkSocket, which it closes when done.
+func startNetlinkEventStream(ctx context.Context, cfg *config, fd int) (<-chan osEvent, <-chan error) {
 	out := make(chan osEvent, 8)
 	errc := make(chan error, 1)
 	go func() {
 		defer close(out); defer close(errc)
-		fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, unix.NETLINK_ROUTE)
-		if err != nil { errc <- fmt.Errorf("netlink socket: %w", err); return }
-		defer unix.Close(fd)
-		sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR | unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV6_ROUTE}
-		if err := unix.Bind(fd, sa); err != nil { errc <- fmt.Errorf("netlink bind: %w", err); return }
-		buf := make([]byte, 1<<16)
+		defer func() { unix.Close(fd) }()
+		// Every send gives up once ctx is done, so that a consumer that has
+		// stopped reading cannot keep the goroutine, and the socket, alive.
+		send := func(reason string) bool {
+			select { case out <- osEvent{reason: reason}: return true; case <-ctx.Done(): return false }
+		}
+		report := func(err error) bool {
+			select { case errc <- err: return true; case <-ctx.Done(): return false }
+		}
+		// Recvfrom does not return when ctx is done, so the socket is waited
+		// on together with an eventfd that is signalled on cancellation.
+		efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC); if err != nil { report(newWatchError(ErrKindOSSocket, fmt.Errorf("%w: eventfd: %w", ErrNetlinkSocket, err))); return }
+		fired := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() { unix.Write(efd, []byte{1, 0, 0, 0, 0, 0, 0, 0}); close(fired) })
+		defer func() { if !stop() { <-fired }; unix.Close(efd) }()
+		bp := getNetlinkBuf(netlinkBufSize(cfg)); defer putNetlinkBuf(bp)
+		buf := *bp
+		var msgs []nlmsg
 		for {
 			select { case <-ctx.Done(): return; default: }
-			n, _, err := unix.Recvfrom(fd, buf, 0)
+			fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}, {Fd: int32(efd), Events: unix.POLLIN}}
+			if _, err := unix.Poll(fds, -1); err != nil && !errors.Is(err, unix.EINTR) { report(newWatchError(ErrKindOSSocket, fmt.Errorf("%w: poll: %w", ErrNetlinkSocket, err))); return }
+			if fds[1].Revents != 0 { return }
+			if fds[0].Revents == 0 { continue }
+			n, _, err := netlinkRecvfrom(fd, buf, 0)
 			if err != nil {
 				if errors.Is(err, unix.EINTR) { continue }
-				errc <- fmt.Errorf("netlink recv: %w", err); return
+				if errors.Is(err, unix.ENOBUFS) {
+					// The kernel dropped notifications because the receive
+					// buffer filled up. Start over on a fresh socket, with
+					// nothing stale queued, and re-evaluate, since any of the
+					// lost messages may have changed the state.
+					if !report(newWatchError(ErrKindOSSocket, &NetlinkOverflowError{Err: err})) { return }
+					unix.Close(fd)
+					if fd, err = reopenNetlinkSocket(cfg); err != nil {
+						fd = -1
+						report(newWatchError(ErrKindOSSocket, fmt.Errorf("%w: reopen: %w", ErrNetlinkSocket, err)))
+						return
+					}
+					if !send("netlink-overflow-recover") { return }
+					continue
+				}
+				report(newWatchError(ErrKindOSSocket, fmt.Errorf("%w: recv: %w", ErrNetlinkSocket, err))); return
 			}
-			msgs, err := parseNlMsgs(buf[:n]); if err != nil { errc <- err; continue }
+			msgs, err = parseNlMsgs(buf[:n], msgs[:0]); if err != nil { if !report(newWatchError(ErrKindInternal, err)) { return }; continue }
 			for _, m := range msgs {
+				reason := ""
 				switch m.Header.Type {
-				case unix.RTM_NEWROUTE, unix.RTM_DELROUTE: out <- osEvent{reason: "route change"}
-				case unix.RTM_NEWADDR, unix.RTM_DELADDR:   out <- osEvent{reason: "addr change"}
-				case unix.RTM_NEWLINK, unix.RTM_DELLINK:   out <- osEvent{reason: "link change"}
+				case unix.RTM_NEWROUTE, unix.RTM_DELROUTE: reason = "route change"
+				case unix.RTM_NEWADDR, unix.RTM_DELADDR:   reason = "addr change"
+				case unix.RTM_NEWLINK, unix.RTM_DELLINK:   reason = "link change"
+				default: continue
 				}
+				if !send(reason) { return }
 			}
 		}
 	}()
 	return out, errc
 }
 
+// openNetlinkSocket opens an rtnetlink socket subscribed to the groups for
+// cfg.family, with the receive buffer set by WithNetlinkRcvBuf, in the
+// network namespace of cfg.
+func openNetlinkSocket(cfg *config) (int, error) {
+	var fd int
+	var err error
+	if nsErr := inNetns(cfg, func() { fd, err = unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE) }); nsErr != nil {
+		return -1, newWatchError(ErrKindOSSocket, fmt.Errorf("%w: %w", ErrNetlinkSocket, nsErr))
+	}
+	if err != nil { return -1, newWatchError(ErrKindOSSocket, fmt.Errorf("%w: socket: %w", ErrNetlinkSocket, err)) }
+	if cfg.netlinkRcvBuf > 0 {
+		// SO_RCVBUFFORCE may exceed net.core.rmem_max but needs
+		// CAP_NET_ADMIN; SO_RCVBUF is capped at rmem_max.
+		if unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUFFORCE, cfg.netlinkRcvBuf) != nil {
+			if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF, cfg.netlinkRcvBuf); err != nil && cfg.logger != nil {
+				cfg.logger.Warn("netlink receive buffer not set", "bytes", cfg.netlinkRcvBuf, "err", err)
+			}
+		}
+	}
+	major, minor := linuxKernelVersion()
+	groups := netlinkGroups(cfg.family, major, minor)
+	if cfg.logger != nil { cfg.logger.Debug("os event stream subscribed", "netlink_groups", fmt.Sprintf("%#x", groups), "kernel", fmt.Sprintf("%d.%d", major, minor)) }
+	sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}
+	if err := unix.Bind(fd, sa); err != nil { unix.Close(fd); return -1, newWatchError(ErrKindOSSocket, fmt.Errorf("%w: bind: %w", ErrNetlinkSocket, err)) }
+	return fd, nil
+}
+
+// netlinkGroups returns the rtnetlink multicast groups for family. Link
+// changes affect both families and are always included. Groups the running
+// kernel (major.minor) predates are left out, since bind does not reject
+// them but they never deliver: RTMGRP_IPV6_ROUTE before 3.9 and
+// RTMGRP_IPV6_IFADDR before 2.6.14. The latter is older than any kernel the
+// Go runtime supports, so it is only checked at major.minor granularity.
+func netlinkGroups(family AddressFamily, major, minor int) uint32 {
+	const v4 = unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV4_ROUTE
+	var v6 uint32 = unix.RTMGRP_IPV6_IFADDR | unix.RTMGRP_IPV6_ROUTE
+	older := func(ma, mi int) bool { return major != 0 && (major < ma || major == ma && minor < mi) }
+	if older(3, 9) { v6 &^= unix.RTMGRP_IPV6_ROUTE }
+	if older(2, 6) { v6 &^= unix.RTMGRP_IPV6_IFADDR }
+	switch family {
+	case FamilyIPv4: return unix.RTMGRP_LINK | v4
+	case FamilyIPv6: return unix.RTMGRP_LINK | v6
+	default: return unix.RTMGRP_LINK | v4 | v6
+	}
+}
+
+// linuxKernelVersion returns the major and minor version of the running
+// kernel from /proc/version ("Linux version 6.1.0-18-amd64 ..."), or 0, 0 if
+// it cannot be read.
+func linuxKernelVersion() (major, minor int) {
+	b, err := os.ReadFile(procPath("/proc/version")); if err != nil { return 0, 0 }
+	f := strings.Fields(string(b)); if len(f) < 3 { return 0, 0 }
+	p := strings.SplitN(f[2], ".", 3); if len(p) < 2 { return 0, 0 }
+	digits := func(s string) string { i := 0; for i < len(s) && s[i] >= '0' && s[i] <= '9' { i++ }; return s[:i] }
+	major, err1 := strconv.Atoi(digits(p[0])); minor, err2 := strconv.Atoi(digits(p[1]))
+	if err1 != nil || err2 != nil { return 0, 0 }
+	return major, minor
+}
+
 type nlmsghdr struct { Len uint32; Type uint16; Flags uint16; Seq uint32; Pid uint32 }
 type nlmsg struct { Header nlmsghdr; Body []byte }
 
-func parseNlMsgs(b []byte) ([]nlmsg, error) {
-	var out []nlmsg
+// parseNlMsgs appends the messages in b to out and returns the result. The
+// bodies point into b. Passing the previous result's out[:0] makes the
+// steady state allocation free.
+func parseNlMsgs(b []byte, out []nlmsg) ([]nlmsg, error) {
 	const hdrLen = int(unsafe.Sizeof(nlmsghdr{}))
 	for len(b) >= hdrLen {
 		h := *(*nlmsghdr)(unsafe.Pointer(&b[0]))
-		if h.Len < uint32(hdrLen) || int(h.Len) > len(b) { return out, fmt.Errorf("invalid nlmsg len") }
+		if h.Len < uint32(hdrLen) || int(h.Len) > len(b) { return out, fmt.Errorf("%w: invalid nlmsg len", ErrNetlinkSocket) }
 		body := b[hdrLen:h.Len]
 		out = append(out, nlmsg{Header: h, Body: body})
 		adv := int((h.Len + 3) &^ 3)
@@ -68,21 +214,76 @@ func parseNlMsgs(b []byte) ([]nlmsg, error) {
 	return out, nil
 }
 
-func recomputeOnline() (bool, string, error) {
-	hasDef, ifname, gw, err := linuxDefaultRoute()
-	if err != nil { return false, "default route check failed", err }
-	if !hasDef { return false, "no default route", nil }
-	if ifname == "" { return false, "default route no iface", nil }
-	up, err := linuxIfaceUp(ifname); if err != nil { return false, "iface state check failed", err }
-	if !up { return false, "default iface down", nil }
-	if !ifaceHasUsableAddr(ifname) { return false, "default iface has no usable IP", nil }
-	if gw != "" && !arpIsReady(gw, ifname) { return false, "gateway neighbor not ready", nil }
-	if !hasDNSResolver() { return false, "no DNS resolver", nil }
-	return true, "default via " + ifname, nil
+// Netlink receive buffers are pooled, so that restarted streams and the
+// WireGuard queries do not allocate a fresh 64 KiB each time.
+const (
+	minNetlinkBuf = 1 << 16
+	maxNetlinkBuf = 1 << 20 // no single datagram comes close
+)
+
+var netlinkBufPool sync.Pool // of *[]byte
+
+// netlinkBufSize is the receive buffer for the event stream: the socket
+// buffer set with WithNetlinkRcvBuf, within minNetlinkBuf and maxNetlinkBuf.
+func netlinkBufSize(cfg *config) int { return min(max(cfg.netlinkRcvBuf, minNetlinkBuf), maxNetlinkBuf) }
+
+// getNetlinkBuf returns a pooled buffer of at least size bytes; smaller
+// pooled ones are left to the garbage collector.
+func getNetlinkBuf(size int) *[]byte {
+	if bp, ok := netlinkBufPool.Get().(*[]byte); ok && len(*bp) >= size { return bp }
+	b := make([]byte, size)
+	return &b
 }
 
-func linuxDefaultRoute() (bool, string, string, error) {
-	if f, err := os.Open("/proc/net/route"); err == nil {
+func putNetlinkBuf(bp *[]byte) { netlinkBufPool.Put(bp) }
+
+// platformMetered asks NetworkManager whether ifname is metered, unless
+// WithNetworkManagerIntegration turned it off or ifname is in another
+// network namespace.
+func platformMetered(cfg *config, ifname string) *bool {
+	if cfg.noNM || cfg.netns != nil { return nil }
+	return nmMetered(ifname)
+}
+
+func linuxRecompute(cfg *config, sysfs bool) (netState, error) {
+	hasDef, ifidx, gw, err := linuxDefaultRoute()
+	if err != nil { return netState{why: "default route check failed"}, err }
+	if !hasDef { return netState{why: "no default route"}, nil }
+	// Resolve the name from the index only now: the index survives a rename
+	// between the OS event and this evaluation, the old name does not.
+	ifname := ifIndexToName(ifidx)
+	if ifname == "" && ifidx != 0 { return netState{why: "default route iface disappeared"}, nil } // deleted since the route was read
+	if ifname == "" { return netState{why: "default route no iface"}, nil }
+	if why := cfg.ifaceFiltered(ifname); why != "" { return netState{why: why, iface: ifname, index: ifidx}, nil }
+	up, err := linuxIfaceUp(ifname, sysfs); if err != nil { return netState{why: "iface state check failed", iface: ifname, index: ifidx}, err }
+	if !up { return netState{why: "default iface down", iface: ifname, index: ifidx}, nil }
+	if sysfs {
+		if members, err := linuxBondActiveMembers(ifname); err == nil && len(members) == 0 { return netState{why: "bond has no active members", iface: ifname, index: ifidx}, nil }
+	}
+	addr := ifaceUsableAddr(ifname)
+	if addr == "" { return netState{why: "default iface has no usable IP", iface: ifname, index: ifidx}, nil }
+	stale := false
+	if gw != "" {
+		var ready bool
+		ready, stale = arpIsReady(gw, ifname)
+		if !ready { return netState{why: "gateway neighbor not ready", iface: ifname, index: ifidx, addr: addr}, nil }
+	}
+	if !hasDNSResolver() { return netState{why: "no DNS resolver", iface: ifname, index: ifidx, addr: addr}, nil }
+	why := "default via " + ifname
+	if stale { why += " (gateway neighbor stale)" }
+	return netState{online: true, why: why, iface: ifname, index: ifidx, addr: addr}, nil
+}
+
+// linuxDefaultRoute returns the interface index and IPv4 gateway (empty for
+// IPv6) of the default route. The main table is read over netlink, which
+// reports the output interface by index; /proc/net/route only has names and
+// is the fallback. Under a linuxProcRoot fixture only its route files count:
+// netlink would report the host's routes instead.
+func linuxDefaultRoute() (bool, int, string, error) {
+	if linuxProcRoot == "" {
+		if ok, idx, gw, err := netlinkDefaultRoute(); err == nil && ok { return true, idx, gw, nil }
+	}
+	if f, err := os.Open(procNetPath("route")); err == nil {
 		defer f.Close()
 		sc := bufio.NewScanner(f); if sc.Scan() {}
 		for sc.Scan() {
@@ -93,26 +294,52 @@ func linuxDefaultRoute() (bool, string, string, error) {
 				flags, _ := strconv.ParseInt(flagsStr, 16, 64)
 				if flags&0x1 != 0 {
 					gw := hexToIPv4(gwHex)
-					return true, iface, gw, nil
+					idx, err := ifNameToIndex(iface); if err != nil { continue }
+					return true, idx, gw, nil
 				}
 			}
 		}
 	}
-	if data, err := os.ReadFile("/proc/net/ipv6_route"); err == nil {
+	if data, err := os.ReadFile(procNetPath("ipv6_route")); err == nil {
 		lines := strings.Split(string(data), "\n")
 		for _, ln := range lines {
 			ln = strings.TrimSpace(ln); if ln == "" { continue }
 			fields := strings.Fields(ln); if len(fields) < 10 { continue }
-			pfxLenHex := fields[1]
-			if pfxLenHex == "000" {
-				ifIdxHex := fields[9]
-				ifidx, _ := strconv.ParseInt(ifIdxHex, 16, 32)
-				ifname := ifIndexToName(int(ifidx))
-				return true, ifname, "", nil
+			// dest, prefix length, src, src length, next hop, metric, refcnt, use, flags, device
+			if fields[0] != strings.Repeat("0", 32) || fields[1] != "00" { continue }
+			flags, _ := strconv.ParseUint(fields[8], 16, 32)
+			if flags&unix.RTF_UP == 0 || flags&unix.RTF_REJECT != 0 { continue }
+			idx, err := ifNameToIndex(fields[9]); if err != nil { continue }
+			return true, idx, "", nil
+		}
+	}
+	return false, 0, "", nil
+}
+
+// netlinkDefaultRoute returns the IPv4 default route of the main table with
+// the lowest metric.
+func netlinkDefaultRoute() (bool, int, string, error) {
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, unix.AF_INET); if err != nil { return false, 0, "", err }
+	msgs, err := syscall.ParseNetlinkMessage(rib); if err != nil { return false, 0, "", err }
+	found, bestIdx, bestGw, bestPrio := false, 0, "", uint32(0)
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Header.Type != unix.RTM_NEWROUTE || len(m.Data) < unix.SizeofRtMsg { continue }
+		rt := (*unix.RtMsg)(unsafe.Pointer(&m.Data[0]))
+		if rt.Dst_len != 0 || rt.Table != unix.RT_TABLE_MAIN || rt.Type != unix.RTN_UNICAST { continue }
+		attrs, err := syscall.ParseNetlinkRouteAttr(m); if err != nil { continue }
+		idx, gw, prio := 0, "", uint32(0)
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case unix.RTA_OIF: if len(a.Value) >= 4 { idx = int(*(*uint32)(unsafe.Pointer(&a.Value[0]))) }
+			case unix.RTA_GATEWAY: if len(a.Value) == 4 { gw = net.IP(a.Value).String() }
+			case unix.RTA_PRIORITY: if len(a.Value) >= 4 { prio = *(*uint32)(unsafe.Pointer(&a.Value[0])) }
 			}
 		}
+		if idx == 0 { continue }
+		if !found || prio < bestPrio { found, bestIdx, bestGw, bestPrio = true, idx, gw, prio }
 	}
-	return false, "", "", nil
+	return found, bestIdx, bestGw, nil
 }
 
 func hexToIPv4(hexs string) string {
@@ -127,10 +354,66 @@ func hexToIPv4(hexs string) string {
 	return fmt.Sprintf("%d.%d.%d.%d", octets[0], octets[1], octets[2], octets[3])
 }
 
-func arpIsReady(gw string, ifname string) bool {
+// neighStaleAfter is the age after which a reachable neighbor entry that has
+// not been updated is treated as stale.
+const neighStaleAfter = 60 * time.Second
+
+// userHZ is the clock tick rate nda_cacheinfo ages are reported in.
+const userHZ = 100
+
+// arpIsReady reports whether the IPv4 gateway has a usable neighbor entry on
+// ifname. A stale entry (NUD_STALE, NUD_DELAY, NUD_PROBE, or reachable but
+// not updated for neighStaleAfter) still counts as ready, with stale set, as
+// the kernel revalidates it on the next packet; NUD_INCOMPLETE and
+// NUD_FAILED mean the gateway does not answer. The kernel neighbor table is
+// read over netlink, since /proc/net/arp shows neither NUD states nor ages;
+// /proc/net/arp is the fallback.
+func arpIsReady(gw string, ifname string) (ready, stale bool) {
 	ip := net.ParseIP(gw)
-	if ip == nil || ip.To4() == nil { return true }
-	b, err := os.ReadFile("/proc/net/arp"); if err != nil { return true }
+	if ip == nil || ip.To4() == nil { return true, false }
+	state, age, found, err := neighState(ip.To4(), ifname)
+	if err != nil { return procArpIsReady(gw, ifname), false }
+	if !found { return false, false }
+	switch {
+	case state&(unix.NUD_PERMANENT|unix.NUD_NOARP) != 0: return true, false
+	case state&unix.NUD_REACHABLE != 0: return true, age > neighStaleAfter
+	case state&(unix.NUD_STALE|unix.NUD_DELAY|unix.NUD_PROBE) != 0: return true, true
+	default: return false, false // NUD_INCOMPLETE, NUD_FAILED, NUD_NONE
+	}
+}
+
+// neighState looks up the IPv4 neighbor entry for ip on ifname and returns
+// its NUD state and the time since it was last updated.
+func neighState(ip net.IP, ifname string) (state uint16, age time.Duration, found bool, err error) {
+	idx, err := ifNameToIndex(ifname); if err != nil { return 0, 0, false, err }
+	rib, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, unix.AF_INET); if err != nil { return 0, 0, false, err }
+	msgs, err := parseNlMsgs(rib, nil); if err != nil { return 0, 0, false, err }
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWNEIGH || len(m.Body) < unix.SizeofNdMsg { continue }
+		nd := (*unix.NdMsg)(unsafe.Pointer(&m.Body[0]))
+		if int(nd.Ifindex) != idx { continue }
+		var dst net.IP; var updated uint32; haveInfo := false
+		for b := m.Body[unix.SizeofNdMsg:]; len(b) >= unix.SizeofRtAttr; {
+			a := (*unix.RtAttr)(unsafe.Pointer(&b[0]))
+			if int(a.Len) < unix.SizeofRtAttr || int(a.Len) > len(b) { break }
+			v := b[unix.SizeofRtAttr:a.Len]
+			switch a.Type {
+			case unix.NDA_DST: dst = net.IP(v)
+			case unix.NDA_CACHEINFO: if len(v) >= 12 { updated = *(*uint32)(unsafe.Pointer(&v[8])); haveInfo = true } // ndm_confirmed, ndm_used, ndm_updated, ndm_refcnt
+			}
+			adv := (int(a.Len) + unix.RTA_ALIGNTO - 1) &^ (unix.RTA_ALIGNTO - 1)
+			if adv > len(b) { break }
+			b = b[adv:]
+		}
+		if !dst.Equal(ip) { continue }
+		if haveInfo { age = time.Duration(updated) * time.Second / userHZ }
+		return nd.State, age, true, nil
+	}
+	return 0, 0, false, nil
+}
+
+func procArpIsReady(gw string, ifname string) bool {
+	b, err := os.ReadFile(procNetPath("arp")); if err != nil { return true }
 	lines := strings.Split(string(b), "\n")
 	for i, ln := range lines {
 		if i == 0 { continue }
@@ -138,46 +421,126 @@ func arpIsReady(gw string, ifname string) bool {
 		ipf, flags, mac, dev := f[0], f[2], f[3], f[5]
 		if dev != ifname || ipf != gw { continue }
 		val, _ := strconv.ParseInt(flags, 0, 64)
-		if (val & 0x2) == 0 { return false }
+		if (val & 0x2) == 0 { return false } // ATF_COM
 		if mac == "00:00:00:00:00:00" { return false }
 		return true
 	}
 	return false
 }
 
+// interfaceKind classifies ifname from the DEVTYPE in its sysfs uevent and
+// its ARPHRD link type, and by its name where those are not specific.
+func interfaceKind(ifname string) InterfaceKind {
+	dir := filepath.Join(procPath("/sys/class/net"), ifname)
+	if b, err := os.ReadFile(filepath.Join(dir, "uevent")); err == nil {
+		for _, ln := range strings.Split(string(b), "\n") {
+			switch ln {
+			case "DEVTYPE=wlan": return InterfaceWiFi
+			case "DEVTYPE=wwan": return InterfaceCellular
+			case "DEVTYPE=wireguard", "DEVTYPE=vxlan": return InterfaceTunnel
+			case "DEVTYPE=bridge": return InterfaceVirtual
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wireless")); err == nil { return InterfaceWiFi }
+	byName, named := interfaceKindFromName(ifname)
+	b, err := os.ReadFile(filepath.Join(dir, "type")); if err != nil { return byName }
+	t, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	switch t {
+	case unix.ARPHRD_LOOPBACK: return InterfaceLoopback
+	case unix.ARPHRD_IEEE80211, unix.ARPHRD_IEEE80211_RADIOTAP: return InterfaceWiFi
+	case unix.ARPHRD_ETHER:
+		if _, err := os.Stat(filepath.Join(dir, "tun_flags")); err == nil { return InterfaceTunnel } // tap
+		if named { return byName } // docker0, veth*, wwan* with an Ethernet header
+		return InterfaceEthernet
+	case unix.ARPHRD_NONE, unix.ARPHRD_TUNNEL, unix.ARPHRD_TUNNEL6, unix.ARPHRD_SIT, unix.ARPHRD_IPGRE, unix.ARPHRD_IP6GRE, unix.ARPHRD_PPP: return InterfaceTunnel
+	case unix.ARPHRD_RAWIP: return InterfaceCellular
+	}
+	return byName
+}
+
+// The helpers below look up a single interface with an ioctl. The net
+// package functions (InterfaceByName, InterfaceByIndex) dump every link over
+// netlink, which on a Kubernetes node with hundreds of veth interfaces costs
+// tens of milliseconds per evaluation.
+
+// ifreq runs the interface ioctl req on ifr.
+func ifreq(req uint, ifr *unix.Ifreq) error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0); if err != nil { return err }
+	defer unix.Close(fd)
+	return unix.IoctlIfreq(fd, req, ifr)
+}
+
 func ifIndexToName(idx int) string {
-	ifaces, _ := net.Interfaces()
-	for _, it := range ifaces { if it.Index == idx { return it.Name } }
-	return ""
+	if idx <= 0 { return "" }
+	ifr, err := unix.NewIfreq(""); if err != nil { return "" }
+	ifr.SetUint32(uint32(idx))
+	if err := ifreq(unix.SIOCGIFNAME, ifr); err != nil { return "" }
+	return ifr.Name()
+}
+
+func ifNameToIndex(name string) (int, error) {
+	ifr, err := unix.NewIfreq(name); if err != nil { return 0, err }
+	if err := ifreq(unix.SIOCGIFINDEX, ifr); err != nil { return 0, err }
+	return int(ifr.Uint32()), nil
 }
 
-func linuxIfaceUp(name string) (bool, error) {
+func ifFlags(name string) (uint16, error) {
+	ifr, err := unix.NewIfreq(name); if err != nil { return 0, err }
+	if err := ifreq(unix.SIOCGIFFLAGS, ifr); err != nil { return 0, err }
+	return ifr.Uint16(), nil
+}
+
+// linuxIfaceUp reports whether name is up with a carrier, reading the
+// operstate and carrier from sysfs if sysfs is set and from IFF_RUNNING
+// otherwise.
+func linuxIfaceUp(name string, sysfs bool) (bool, error) {
 	if name == "" { return false, nil }
-	ifi, err := net.InterfaceByName(name)
+	flags, err := ifFlags(name)
 	if err == nil {
-		if (ifi.Flags&net.FlagUp) == 0 || (ifi.Flags&net.FlagLoopback) != 0 { return false, nil }
+		if flags&unix.IFF_UP == 0 || flags&unix.IFF_LOOPBACK != 0 { return false, nil }
 	}
-	oper := filepath.Join("/sys/class/net", name, "operstate")
+	if !sysfs { return err == nil && flags&unix.IFF_RUNNING != 0, nil }
+	oper := filepath.Join(procPath("/sys/class/net"), name, "operstate")
 	if b, err := os.ReadFile(oper); err == nil {
 		s := strings.TrimSpace(string(b)); if s != "up" && s != "unknown" { return false, nil }
 	}
-	carrier := filepath.Join("/sys/class/net", name, "carrier")
+	carrier := filepath.Join(procPath("/sys/class/net"), name, "carrier")
 	if b, err := os.ReadFile(carrier); err == nil {
 		if strings.TrimSpace(string(b)) != "1" { return false, nil }
 	}
 	return true, nil
 }
 
-func ifaceHasUsableAddr(ifname string) bool {
-	ifi, err := net.InterfaceByName(ifname); if err != nil { return false }
-	addrs, err := ifi.Addrs(); if err != nil { return false }
+// linuxBondActiveMembers returns the active members of the bonding
+// interface bondName: the active_slave in active-backup mode, otherwise the
+// slaves that are up. It fails if bondName is not a bond. A bond can keep
+// its carrier and default route for a while after all members went down.
+func linuxBondActiveMembers(bondName string) ([]string, error) {
+	dir := filepath.Join(procPath("/sys/class/net"), bondName, "bonding")
+	if b, err := os.ReadFile(filepath.Join(dir, "mode")); err == nil && strings.HasPrefix(string(b), "active-backup") {
+		b, err := os.ReadFile(filepath.Join(dir, "active_slave")); if err != nil { return nil, err }
+		if s := strings.TrimSpace(string(b)); s != "" { return []string{s}, nil }
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "slaves")); if err != nil { return nil, err }
+	var active []string
+	for _, m := range strings.Fields(string(b)) {
+		if up, _ := linuxIfaceUp(m, true); up { active = append(active, m) }
+	}
+	return active, nil
+}
+
+func ifaceUsableAddr(ifname string) string {
+	idx, err := ifNameToIndex(ifname); if err != nil { return "" }
+	addrs, err := (&net.Interface{Index: idx, Name: ifname}).Addrs(); if err != nil { return "" }
 	for _, a := range addrs {
 		var ip net.IP
 		switch v := a.(type) { case *net.IPNet: ip = v.IP; case *net.IPAddr: ip = v.IP }
 		if ip == nil || ip.IsLoopback() { continue }
-		if v4 := ip.To4(); v4 != nil { if !v4.IsUnspecified() { return true }; continue }
+		if v4 := ip.To4(); v4 != nil { if !v4.IsUnspecified() { return v4.String() }; continue }
 		if ip.IsLinkLocalUnicast() || ip.IsUnspecified() { continue }
-		return true
+		return ip.String()
 	}
-	return false
+	return ""
 }
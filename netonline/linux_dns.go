@@ -30,5 +30,34 @@ func hasDNSResolver() bool {
 		f.Close()
 		if found { return true }
 	}
+	return networkdHasDNS()
+}
+
+// networkdHasDNS checks the DNS= lines systemd-networkd writes for DHCP and
+// static servers when systemd-resolved is not in use: the global state file
+// and one file per link under /run/systemd/netif/links.
+func networkdHasDNS() bool {
+	const dir = "/run/systemd/netif"
+	if _, err := os.Stat(dir); err != nil { return false }
+	paths := []string{dir + "/state"}
+	if ents, err := os.ReadDir(dir + "/links"); err == nil {
+		for _, e := range ents { if !e.IsDir() { paths = append(paths, dir+"/links/"+e.Name()) } }
+	}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil { continue }
+		for _, line := range strings.Split(string(b), "\n") {
+			v, ok := strings.CutPrefix(strings.TrimSpace(line), "DNS=")
+			if !ok { continue }
+			for _, s := range strings.Fields(v) {
+				// Entries may carry a port, interface or server name:
+				// 1.1.1.1:53, [fe80::1]:53, fe80::1%eth0, 1.1.1.1#dns.example.
+				s, _, _ = strings.Cut(s, "#")
+				s, _, _ = strings.Cut(s, "%")
+				if h, _, err := net.SplitHostPort(s); err == nil { s = h }
+				if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil && !ip.IsLoopback() { return true }
+			}
+		}
+	}
 	return false
 }
@@ -0,0 +1,52 @@
+//go:build linux && !(android && cgo)
+
+package netonline
+
+import "context"
+
+// The event source and evaluation of Linux hosts. Android apps built with
+// cgo use ConnectivityManager instead, see android.go; without cgo they get
+// these, as far as the app sandbox lets them read netlink and /proc.
+
+// startOSEventStream prefers NetworkManager's D-Bus signals when
+// NetworkManager is running: they work without netlink access in user
+// sessions and name NetworkManager's states in Event.CauseDetail. The
+// rtnetlink socket runs alongside them whenever it can be opened, since
+// NetworkManager does not signal changes made outside it (ip route,
+// wg-quick, container runtimes). Without NetworkManager, with
+// WithNetworkManagerIntegration(false) or in another network namespace,
+// which NetworkManager does not manage, netlink is the only source.
+func startOSEventStream(ctx context.Context, cfg *config) (<-chan osEvent, <-chan error) {
+	if !cfg.noNM && cfg.netns == nil {
+		sctx, cancel := context.WithCancel(ctx)
+		if nmOut, nmErrc, ok := startNMEventStream(sctx); ok {
+			var nlOut <-chan osEvent; var nlErrc <-chan error
+			if fd, err := openNetlinkSocket(cfg); err == nil {
+				nlOut, nlErrc = startNetlinkEventStream(sctx, cfg, fd)
+			} else if cfg.logger != nil {
+				cfg.logger.Warn("netlink socket unavailable, using NetworkManager signals only", "err", err)
+			}
+			return mergeOSEventStreams(ctx, cancel, nmOut, nmErrc, nlOut, nlErrc)
+		}
+		cancel()
+	}
+	fd, err := openNetlinkSocket(cfg)
+	if err != nil {
+		out, errc := make(chan osEvent), make(chan error, 1)
+		errc <- err; close(out); close(errc)
+		return out, errc
+	}
+	return startNetlinkEventStream(ctx, cfg, fd)
+}
+
+// recomputeOnline evaluates the host's network namespace, or the one set
+// with WithNetworkNamespace. sysfs keeps describing the namespace it was
+// mounted in, so the carrier comes from the interface flags there and bonds
+// are not inspected.
+func recomputeOnline(cfg *config) (st netState, err error) {
+	if cfg.netns == nil { return linuxRecompute(cfg, true) }
+	if nsErr := inNetns(cfg, func() { st, err = linuxRecompute(cfg, false) }); nsErr != nil {
+		return netState{why: "network namespace unavailable"}, newWatchError(ErrKindOSSocket, nsErr)
+	}
+	return st, err
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package netonline
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// inNetns runs fn in the network namespace set with WithNetworkNamespace or
+// WithNetworkNamespacePath, or directly when there is none. fn runs on a
+// goroutine locked to an OS thread that joins the namespace with setns(2);
+// sockets it opens stay in the namespace after it returns. The thread is
+// only unlocked, and so reused by the runtime, once it is back in its
+// original namespace; otherwise it exits with the goroutine.
+func inNetns(cfg *config, fn func()) error {
+	if cfg.netns == nil {
+		fn()
+		return nil
+	}
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		orig, err := unix.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			errc <- fmt.Errorf("netns: %w", os.NewSyscallError("open", err))
+			return
+		}
+		defer unix.Close(orig)
+		target := cfg.netns.fd
+		if cfg.netns.path != "" {
+			if target, err = unix.Open(cfg.netns.path, unix.O_RDONLY|unix.O_CLOEXEC, 0); err != nil {
+				errc <- fmt.Errorf("netns %s: %w", cfg.netns.path, os.NewSyscallError("open", err))
+				return
+			}
+			defer unix.Close(target)
+		}
+		if err := unix.Setns(target, unix.CLONE_NEWNET); err != nil {
+			errc <- fmt.Errorf("netns: %w", os.NewSyscallError("setns", err))
+			return
+		}
+		fn()
+		if unix.Setns(orig, unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		errc <- nil
+	}()
+	return <-errc
+}
+
+// procNetPath returns the path of a /proc/net file for the calling thread's
+// network namespace. /proc/net follows the main thread, so inside inNetns
+// the per-thread /proc/thread-self/net (Linux 3.17 and later) is used.
+func procNetPath(name string) string {
+	if p := procPath("/proc/thread-self/net/" + name); fileExists(p) {
+		return p
+	}
+	return procPath("/proc/net/" + name)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,158 @@
+//go:build linux
+// +build linux
+
+package netonline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	nmBusName         = "org.freedesktop.NetworkManager"
+	nmDeviceInterface = "org.freedesktop.NetworkManager.Device"
+)
+
+// nmStateNames names the NMState values of the global StateChanged signal.
+var nmStateNames = map[uint32]string{
+	0:  "unknown",
+	10: "asleep",
+	20: "disconnected",
+	30: "disconnecting",
+	40: "connecting",
+	50: "connected local",
+	60: "connected site",
+	70: "connected global",
+}
+
+// nmDeviceStateNames names the NMDeviceState values of the per-device
+// StateChanged signal.
+var nmDeviceStateNames = map[uint32]string{
+	0:   "unknown",
+	10:  "unmanaged",
+	20:  "unavailable",
+	30:  "disconnected",
+	40:  "prepare",
+	50:  "config",
+	60:  "need auth",
+	70:  "ip config",
+	80:  "ip check",
+	90:  "secondaries",
+	100: "activated",
+	110: "deactivating",
+	120: "failed",
+}
+
+func nmStateName(names map[uint32]string, v uint32) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("state %d", v)
+}
+
+// nmReason describes sig for osEvent.reason: "nm state change: connected
+// global", or "nm device state change: disconnected -> prepare (reason 0)"
+// with NetworkManager's NMDeviceStateReason code.
+func nmReason(sig *dbus.Signal) string {
+	if sig.Name == nmDeviceInterface+".StateChanged" {
+		reason := "nm device state change"
+		if len(sig.Body) == 3 {
+			newState, ok1 := sig.Body[0].(uint32)
+			oldState, ok2 := sig.Body[1].(uint32)
+			code, ok3 := sig.Body[2].(uint32)
+			if ok1 && ok2 && ok3 {
+				reason += fmt.Sprintf(": %s -> %s (reason %d)", nmStateName(nmDeviceStateNames, oldState), nmStateName(nmDeviceStateNames, newState), code)
+			}
+		}
+		return reason
+	}
+	reason := "nm state change"
+	if len(sig.Body) == 1 {
+		if state, ok := sig.Body[0].(uint32); ok {
+			reason += ": " + nmStateName(nmStateNames, state)
+		}
+	}
+	return reason
+}
+
+// startNMEventStream subscribes to NetworkManager's StateChanged signals on
+// the system bus: the global one (state) and the per-device one (state,
+// old state, reason). ok is false when D-Bus is unreachable or NetworkManager
+// is not running; the caller then falls back to netlink.
+func startNMEventStream(ctx context.Context) (<-chan osEvent, <-chan error, bool) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, nil, false
+	}
+	var running bool
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.NameHasOwner", 0, nmBusName).Store(&running); err != nil || !running {
+		conn.Close()
+		return nil, nil, false
+	}
+	for _, iface := range []string{nmBusName, nmDeviceInterface} {
+		if err := conn.AddMatchSignalContext(ctx, dbus.WithMatchSender(nmBusName), dbus.WithMatchInterface(iface), dbus.WithMatchMember("StateChanged")); err != nil {
+			conn.Close()
+			return nil, nil, false
+		}
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	out := make(chan osEvent, 8)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errc)
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("networkmanager: d-bus connection closed"))
+					return
+				}
+				select {
+				case out <- osEvent{reason: nmReason(sig)}:
+				default:
+				}
+			}
+		}
+	}()
+	return out, errc, true
+}
+
+// nmMetered reports NetworkManager's Metered property (NMMetered) of the
+// device for ifname, counting its guesses, or nil if NetworkManager does not
+// know or cannot be asked. It uses the shared system bus connection.
+func nmMetered(ifname string) *bool {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var dev dbus.ObjectPath
+	if err := conn.Object(nmBusName, "/org/freedesktop/NetworkManager").CallWithContext(ctx, nmBusName+".GetDeviceByIpIface", 0, ifname).Store(&dev); err != nil {
+		return nil
+	}
+	var v dbus.Variant
+	if err := conn.Object(nmBusName, dev).CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, nmDeviceInterface, "Metered").Store(&v); err != nil {
+		return nil
+	}
+	m, _ := v.Value().(uint32)
+	var metered bool
+	switch m {
+	case 1, 3: // NM_METERED_YES, NM_METERED_GUESS_YES
+		metered = true
+	case 2, 4: // NM_METERED_NO, NM_METERED_GUESS_NO
+		metered = false
+	default:
+		return nil
+	}
+	return &metered
+}
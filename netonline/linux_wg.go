@@ -0,0 +1,183 @@
+//go:build linux
+// +build linux
+
+package netonline
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// wgKernelInterface reports whether ifname is a kernel WireGuard device.
+func wgKernelInterface(ifname string) bool {
+	b, err := os.ReadFile(filepath.Join(procPath("/sys/class/net"), ifname, "uevent"))
+	if err != nil {
+		return false
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		if ln == "DEVTYPE=wireguard" {
+			return true
+		}
+	}
+	return false
+}
+
+// wgKernelLastHandshake returns the most recent peer handshake of the
+// kernel WireGuard device ifname, asking the wireguard generic netlink
+// family (WG_CMD_GET_DEVICE). That needs CAP_NET_ADMIN; ok is false when
+// the device cannot be queried.
+func wgKernelLastHandshake(ifname string) (last time.Time, ok bool) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_GENERIC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer unix.Close(fd)
+	tv := unix.NsecToTimeval(time.Second.Nanoseconds())
+	_ = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return time.Time{}, false
+	}
+	family, err := genlFamilyID(fd, unix.WG_GENL_NAME)
+	if err != nil {
+		return time.Time{}, false
+	}
+	msgs, err := genlRequest(fd, family, unix.WG_CMD_GET_DEVICE, unix.NLM_F_DUMP, nlAttrString(unix.WGDEVICE_A_IFNAME, ifname))
+	if err != nil {
+		return time.Time{}, false
+	}
+	// Devices with many peers are dumped over several messages, each with
+	// a part of WGDEVICE_A_PEERS.
+	for _, m := range msgs {
+		for _, a := range parseNlAttrs(m) {
+			if a.typ != unix.WGDEVICE_A_PEERS {
+				continue
+			}
+			for _, peer := range parseNlAttrs(a.val) {
+				for _, pa := range parseNlAttrs(peer.val) {
+					if pa.typ != unix.WGPEER_A_LAST_HANDSHAKE_TIME || len(pa.val) < 16 {
+						continue
+					}
+					sec := int64(binary.NativeEndian.Uint64(pa.val[0:8]))
+					nsec := int64(binary.NativeEndian.Uint64(pa.val[8:16]))
+					if sec == 0 && nsec == 0 { // never
+						continue
+					}
+					if t := time.Unix(sec, nsec); t.After(last) {
+						last = t
+					}
+				}
+			}
+		}
+	}
+	return last, true
+}
+
+// genlHdrLen is the size of struct genlmsghdr.
+const genlHdrLen = int(unsafe.Sizeof(unix.Genlmsghdr{}))
+
+type nlAttr struct {
+	typ uint16
+	val []byte
+}
+
+// parseNlAttrs splits b into netlink attributes, dropping the nested and
+// byte order flags from their types.
+func parseNlAttrs(b []byte) []nlAttr {
+	var out []nlAttr
+	for len(b) >= unix.SizeofNlAttr {
+		l := int(binary.NativeEndian.Uint16(b[0:2]))
+		if l < unix.SizeofNlAttr || l > len(b) {
+			break
+		}
+		typ := binary.NativeEndian.Uint16(b[2:4]) &^ (unix.NLA_F_NESTED | unix.NLA_F_NET_BYTEORDER)
+		out = append(out, nlAttr{typ: typ, val: b[unix.SizeofNlAttr:l]})
+		adv := (l + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if adv > len(b) {
+			break
+		}
+		b = b[adv:]
+	}
+	return out
+}
+
+// nlAttrString encodes a NUL-terminated string attribute.
+func nlAttrString(typ uint16, s string) []byte {
+	l := unix.SizeofNlAttr + len(s) + 1
+	b := make([]byte, (l+unix.NLA_ALIGNTO-1)&^(unix.NLA_ALIGNTO-1))
+	binary.NativeEndian.PutUint16(b[0:2], uint16(l))
+	binary.NativeEndian.PutUint16(b[2:4], typ)
+	copy(b[unix.SizeofNlAttr:], s)
+	return b
+}
+
+// genlFamilyID resolves a generic netlink family name to its message type.
+func genlFamilyID(fd int, name string) (uint16, error) {
+	msgs, err := genlRequest(fd, unix.GENL_ID_CTRL, unix.CTRL_CMD_GETFAMILY, 0, nlAttrString(unix.CTRL_ATTR_FAMILY_NAME, name))
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range msgs {
+		for _, a := range parseNlAttrs(m) {
+			if a.typ == unix.CTRL_ATTR_FAMILY_ID && len(a.val) >= 2 {
+				return binary.NativeEndian.Uint16(a.val), nil
+			}
+		}
+	}
+	return 0, errors.New("genetlink: family id missing")
+}
+
+// genlRequest sends one generic netlink request and returns the attribute
+// payloads of the replies, after their genlmsghdr, until the last part of a
+// dump or a single reply.
+func genlRequest(fd int, family uint16, cmd uint8, flags uint16, attrs []byte) ([][]byte, error) {
+	const hdrLen = int(unsafe.Sizeof(nlmsghdr{}))
+	req := make([]byte, hdrLen+genlHdrLen+len(attrs))
+	*(*nlmsghdr)(unsafe.Pointer(&req[0])) = nlmsghdr{Len: uint32(len(req)), Type: family, Flags: unix.NLM_F_REQUEST | flags, Seq: 1}
+	*(*unix.Genlmsghdr)(unsafe.Pointer(&req[hdrLen])) = unix.Genlmsghdr{Cmd: cmd, Version: 1}
+	copy(req[hdrLen+genlHdrLen:], attrs)
+	if err := unix.Sendto(fd, req, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+	var out [][]byte
+	bp := getNetlinkBuf(minNetlinkBuf)
+	defer putNetlinkBuf(bp)
+	buf := *bp
+	var msgs []nlmsg
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err = parseNlMsgs(buf[:n], msgs[:0])
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return out, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Body) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(m.Body[0:4])); errno != 0 {
+						return nil, syscall.Errno(-errno)
+					}
+				}
+				return out, nil
+			}
+			if len(m.Body) >= genlHdrLen {
+				out = append(out, append([]byte(nil), m.Body[genlHdrLen:]...))
+			}
+			if m.Header.Flags&unix.NLM_F_MULTI == 0 {
+				return out, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package netonline
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogValue groups the event's fields for structured logging, so that
+// slog.Any("event", e) logs them as event.online, event.cause and so on.
+func (e Event) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Bool("online", e.Online),
+		slog.Time("changed_at", e.ChangedAt),
+		slog.String("cause", e.Cause.String()),
+		slog.String("cause_detail", e.CauseDetail),
+	}
+	if e.Interface != "" {
+		attrs = append(attrs, slog.String("interface", e.Interface))
+	}
+	if e.InterfaceKind != InterfaceOther {
+		attrs = append(attrs, slog.String("interface_kind", e.InterfaceKind.String()))
+	}
+	if e.Addr != "" {
+		attrs = append(attrs, slog.String("addr", e.Addr))
+	}
+	if e.CheckResult != nil {
+		attrs = append(attrs, slog.Bool("check_ok", e.CheckResult.OK))
+	}
+	if e.CaptivePortalURL != nil {
+		attrs = append(attrs, slog.String("captive_portal", *e.CaptivePortalURL))
+	}
+	if e.Metered != nil {
+		attrs = append(attrs, slog.Bool("metered", *e.Metered))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogEvent logs e to l the way a Watcher configured with WithLogger does:
+// state changes at Info, heartbeats at Debug, with the event under the key
+// "event".
+func LogEvent(ctx context.Context, l *slog.Logger, e Event) {
+	level, msg := slog.LevelInfo, "network state changed"
+	if e.IsHeartbeat() {
+		level, msg = slog.LevelDebug, "network state heartbeat"
+	}
+	l.LogAttrs(ctx, level, msg, slog.Any("event", e))
+}
+
+func (w *Watcher) logEvent(ev Event) {
+	if w.cfg.logger != nil {
+		LogEvent(w.ctx, w.cfg.logger, ev)
+	}
+}
+
+func (w *Watcher) logError(err error) {
+	if w.cfg.logger != nil {
+		w.cfg.logger.LogAttrs(w.ctx, slog.LevelWarn, "network watch error", slog.Any("err", err))
+	}
+}
+
+// logCheck logs the summary of a failed active check.
+func (w *Watcher) logCheck(res *CheckResult) {
+	if w.cfg.logger != nil && !res.OK {
+		w.cfg.logger.LogAttrs(w.ctx, slog.LevelWarn, "connectivity check failed", slog.String("summary", res.Summary()))
+	}
+}
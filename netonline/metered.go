@@ -0,0 +1,18 @@
+package netonline
+
+import "strings"
+
+// interfaceMetered reports whether traffic over ifname is metered (billed
+// by volume): the platform's answer where it has one, otherwise true for
+// cellular interfaces (kind, or a wwan* name) and nil (unknown) for the
+// rest.
+func interfaceMetered(cfg *config, ifname string, kind InterfaceKind) *bool {
+	if m := platformMetered(cfg, ifname); m != nil {
+		return m
+	}
+	if kind == InterfaceCellular || strings.HasPrefix(ifname, "wwan") {
+		metered := true
+		return &metered
+	}
+	return nil
+}
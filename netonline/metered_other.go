@@ -0,0 +1,6 @@
+//go:build !linux && !windows
+
+package netonline
+
+// platformMetered has no platform source outside Linux and Windows.
+func platformMetered(cfg *config, ifname string) *bool { return nil }
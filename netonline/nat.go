@@ -0,0 +1,170 @@
+package netonline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// NATClass is the NAT behaviour found by STUNProbe, in the terms of the
+// classic RFC 3489 classification.
+type NATClass int
+
+const (
+	NATUnknown           NATClass = iota // the server cannot run the tests (no OTHER-ADDRESS), or they were cut short
+	NATOpen                              // no NAT: the mapped address is the local one
+	NATFullCone                          // any host can reach the mapped address
+	NATAddressRestricted                 // only hosts this one sent to can reach the mapped address
+	NATPortRestricted                    // only host and port pairs this one sent to can reach it; also a firewall without NAT that filters the same way
+	NATSymmetric                         // each destination gets a different mapping, which defeats hole punching
+)
+
+func (c NATClass) String() string {
+	switch c {
+	case NATOpen:
+		return "open"
+	case NATFullCone:
+		return "full cone"
+	case NATAddressRestricted:
+		return "address restricted"
+	case NATPortRestricted:
+		return "port restricted"
+	case NATSymmetric:
+		return "symmetric"
+	}
+	return "unknown"
+}
+
+// STUNResult is the outcome of STUNProbe.
+type STUNResult struct {
+	ExternalAddr string // host:port as seen by the server
+	NATType      NATClass
+	Latency      time.Duration // round trip of the first binding request
+}
+
+// stunTestTimeout is how long STUNProbe waits for each answer; the request
+// is sent twice within it.
+const stunTestTimeout = time.Second
+
+var errSTUNNoResponse = errors.New("stun: no response")
+
+// STUNProbe finds this host's public address through the STUN server at
+// server (host:port) and classifies the NAT in front of it with the three
+// tests of RFC 3489: a plain binding request, one answered from the
+// server's alternate address and port, one sent to the alternate address,
+// and one answered from the alternate port only. The tests after the first
+// need a server that reports OTHER-ADDRESS and honours CHANGE-REQUEST
+// (RFC 5780); with others NATType is NATUnknown. It fails if the server
+// does not answer the first request. Each test waits up to a second, within
+// ctx.
+func STUNProbe(ctx context.Context, server string) (STUNResult, error) {
+	var res STUNResult
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return res, err
+	}
+	srv, local := c.RemoteAddr().(*net.UDPAddr), c.LocalAddr().(*net.UDPAddr)
+	c.Close()
+	// The tests need answers from addresses other than srv, so the socket
+	// is not connected; binding to the address the route picked lets the
+	// open-internet test compare it with the mapped one.
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: local.IP, Zone: local.Zone})
+	if err != nil {
+		return res, err
+	}
+	defer pc.Close()
+	stop := context.AfterFunc(ctx, func() { pc.Close() })
+	defer stop()
+	self := pc.LocalAddr().(*net.UDPAddr)
+
+	begin := time.Now()
+	id, attrs, err := stunTransact(ctx, pc, srv, 0)
+	if err != nil {
+		return res, err
+	}
+	res.Latency = time.Since(begin)
+	mapped := stunMappedAddr(id, attrs)
+	if mapped == nil {
+		return res, errors.New("stun: response without mapped address")
+	}
+	res.ExternalAddr = mapped.String()
+	other := stunOtherAddr(attrs)
+	if other == nil {
+		return res, nil
+	}
+
+	// Test II: answer from the alternate IP and port.
+	_, _, errII := stunTransact(ctx, pc, srv, stunChangeIP|stunChangePort)
+	if ctx.Err() != nil {
+		return res, nil
+	}
+	if mapped.IP.Equal(self.IP) && mapped.Port == self.Port {
+		res.NATType = NATOpen
+		if errII != nil {
+			res.NATType = NATPortRestricted // a filtering firewall
+		}
+		return res, nil
+	}
+	if errII == nil {
+		res.NATType = NATFullCone
+		return res, nil
+	}
+
+	// Test I to the alternate address: a new mapping means symmetric NAT.
+	id, attrs, err = stunTransact(ctx, pc, other, 0)
+	if err != nil {
+		return res, nil
+	}
+	if m := stunMappedAddr(id, attrs); m == nil || !m.IP.Equal(mapped.IP) || m.Port != mapped.Port {
+		res.NATType = NATSymmetric
+		return res, nil
+	}
+
+	// Test III: answer from the alternate port only.
+	if _, _, err := stunTransact(ctx, pc, srv, stunChangePort); err == nil {
+		res.NATType = NATAddressRestricted
+	} else if ctx.Err() == nil {
+		res.NATType = NATPortRestricted
+	}
+	return res, nil
+}
+
+// stunTransact sends a binding request with the change flags to to, twice
+// if needed, and returns the attributes of the matching answer from any
+// source. It returns errSTUNNoResponse after stunTestTimeout.
+func stunTransact(ctx context.Context, pc *net.UDPConn, to *net.UDPAddr, change uint32) (stunTxID, []byte, error) {
+	id := newSTUNTxID()
+	req := stunRequest(id)
+	if change != 0 {
+		req = stunChangeRequest(id, change)
+	}
+	buf := make([]byte, 1500)
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := pc.WriteToUDP(req, to); err != nil {
+			if ctx.Err() != nil {
+				return id, nil, ctx.Err()
+			}
+			return id, nil, err
+		}
+		_ = pc.SetReadDeadline(time.Now().Add(stunTestTimeout / 2))
+		for {
+			n, _, err := pc.ReadFromUDP(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return id, nil, ctx.Err()
+				}
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					break
+				}
+				return id, nil, err
+			}
+			if rid, attrs, ok := parseSTUNResponse(buf[:n]); ok && rid == id {
+				return id, append([]byte(nil), attrs...), nil
+			}
+		}
+	}
+	return id, nil, errSTUNNoResponse
+}
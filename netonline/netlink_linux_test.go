@@ -0,0 +1,175 @@
+package netonline
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// nlBatch builds a datagram like the ones a burst of container network
+// setup produces: for each of n veth interfaces, an RTM_NEWLINK with its
+// name, an RTM_NEWADDR with its address and an RTM_NEWROUTE for its subnet.
+func nlBatch(n int) []byte {
+	var b []byte
+	msg := func(typ uint16, fixed int, attrs ...[]byte) {
+		body := make([]byte, fixed)
+		for _, a := range attrs {
+			body = append(body, a...)
+		}
+		h := make([]byte, unix.SizeofNlMsghdr)
+		*(*nlmsghdr)(unsafe.Pointer(&h[0])) = nlmsghdr{Len: uint32(len(h) + len(body)), Type: typ}
+		m := append(h, body...)
+		for len(m)%unix.NLMSG_ALIGNTO != 0 {
+			m = append(m, 0)
+		}
+		b = append(b, m...)
+	}
+	attr := func(typ uint16, val []byte) []byte {
+		a := make([]byte, unix.SizeofRtAttr, unix.SizeofRtAttr+len(val)+3)
+		binary.NativeEndian.PutUint16(a[0:2], uint16(unix.SizeofRtAttr+len(val)))
+		binary.NativeEndian.PutUint16(a[2:4], typ)
+		a = append(a, val...)
+		for len(a)%unix.RTA_ALIGNTO != 0 {
+			a = append(a, 0)
+		}
+		return a
+	}
+	for i := 0; i < n; i++ {
+		ip := []byte{10, 88, byte(i), 1}
+		msg(unix.RTM_NEWLINK, unix.SizeofIfInfomsg, attr(unix.IFLA_IFNAME, []byte("veth0a1b2c3\x00")), attr(unix.IFLA_MTU, []byte{0xdc, 0x05, 0, 0}))
+		msg(unix.RTM_NEWADDR, unix.SizeofIfAddrmsg, attr(unix.IFA_ADDRESS, ip), attr(unix.IFA_LOCAL, ip))
+		msg(unix.RTM_NEWROUTE, unix.SizeofRtMsg, attr(unix.RTA_DST, []byte{10, 88, byte(i), 0}), attr(unix.RTA_OIF, []byte{byte(i), 0, 0, 0}))
+	}
+	return b
+}
+
+func TestParseNlMsgsReuse(t *testing.T) {
+	b := nlBatch(10)
+	msgs, err := parseNlMsgs(b, nil)
+	if err != nil || len(msgs) != 30 {
+		t.Fatalf("parseNlMsgs: %d messages, %v; want 30", len(msgs), err)
+	}
+	if got := msgs[2].Header.Type; got != unix.RTM_NEWROUTE {
+		t.Fatalf("third message has type %d, want RTM_NEWROUTE", got)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		msgs, _ = parseNlMsgs(b, msgs[:0])
+	})
+	if allocs != 0 {
+		t.Fatalf("parseNlMsgs into a reused slice allocates %v times per batch, want 0", allocs)
+	}
+}
+
+func BenchmarkParseNlMsgs(b *testing.B) {
+	batch := nlBatch(20)
+	var msgs []nlmsg
+	b.ReportAllocs()
+	b.SetBytes(int64(len(batch)))
+	for i := 0; i < b.N; i++ {
+		var err error
+		if msgs, err = parseNlMsgs(batch, msgs[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMergeOSEventStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sctx, scancel := context.WithCancel(ctx)
+	nm, nmErrc := make(chan osEvent), make(chan error, 1)
+	nl, nlErrc := make(chan osEvent), make(chan error, 1)
+	out, errc := mergeOSEventStreams(ctx, scancel, nm, nmErrc, nl, nlErrc)
+
+	for _, tc := range []struct {
+		in     chan osEvent
+		reason string
+	}{{nm, "nm state change: connected global"}, {nl, "route change"}} {
+		tc.in <- osEvent{reason: tc.reason}
+		if ev := <-out; ev.reason != tc.reason {
+			t.Fatalf("merged event %q, want %q", ev.reason, tc.reason)
+		}
+	}
+
+	// NetworkManager going away ends the merged stream, with its error,
+	// and stops the netlink reader so both restart together.
+	lost := errors.New("d-bus connection closed")
+	nmErrc <- lost
+	close(nmErrc)
+	close(nm)
+	if err := <-errc; !errors.Is(err, lost) {
+		t.Fatalf("merged error %v, want %v", err, lost)
+	}
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("merged stream still open after NetworkManager's ended")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("merged stream still open after NetworkManager's ended")
+	}
+	if sctx.Err() == nil {
+		t.Fatal("the other stream was not cancelled")
+	}
+}
+
+// forceOverflow makes the netlink reader see ENOBUFS on its first read and
+// fail to reopen its socket. It returns a readable descriptor to start the
+// reader on, which the reader closes.
+func forceOverflow(t *testing.T) int {
+	t.Helper()
+	recv, reopen := netlinkRecvfrom, reopenNetlinkSocket
+	t.Cleanup(func() { netlinkRecvfrom, reopenNetlinkSocket = recv, reopen })
+	netlinkRecvfrom = func(int, []byte, int) (int, unix.Sockaddr, error) { return 0, nil, unix.ENOBUFS }
+	reopenNetlinkSocket = func(*config) (int, error) { return -1, unix.EMFILE }
+	var p [2]int
+	if err := unix.Pipe2(p[:], unix.O_CLOEXEC); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unix.Close(p[1]) })
+	if _, err := unix.Write(p[1], []byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	return p[0]
+}
+
+func TestNetlinkOverflowReopenFails(t *testing.T) {
+	fd := forceOverflow(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := newConfig(nil)
+	out, errc := startNetlinkEventStream(ctx, cfg, fd)
+
+	// Both errors are reported, although the first one is only read once
+	// the second one is pending.
+	time.Sleep(50 * time.Millisecond)
+	var overflow *NetlinkOverflowError
+	if err := <-errc; !errors.As(err, &overflow) {
+		t.Fatalf("first error %v, want a NetlinkOverflowError", err)
+	}
+	var we *WatchError
+	if err := <-errc; !errors.Is(err, ErrNetlinkSocket) || !errors.Is(err, unix.EMFILE) || !errors.As(err, &we) || we.Kind != ErrKindOSSocket {
+		t.Fatalf("second error %v, want an OS socket WatchError wrapping ErrNetlinkSocket and EMFILE", err)
+	}
+	if _, ok := <-out; ok {
+		t.Fatal("event after the failed reopen")
+	}
+}
+
+func TestNetlinkOverflowUnreadErrorsCancel(t *testing.T) {
+	fd := forceOverflow(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	out, _ := startNetlinkEventStream(ctx, newConfig(nil), fd)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-out:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reader blocked on an unread error after cancellation")
+	}
+}
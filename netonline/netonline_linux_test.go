@@ -0,0 +1,168 @@
+//go:build linux
+
+package netonline_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+	"golang.org/x/sys/unix"
+
+	"example.com/netonline/netonline"
+)
+
+// newTestNetns creates an empty network namespace and returns a handle to
+// it. It skips the test without root or where namespaces are unavailable.
+func newTestNetns(t *testing.T) *os.File {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("needs root for network namespaces")
+	}
+	if _, err := exec.LookPath("nsenter"); err != nil {
+		t.Skip("nsenter not installed")
+	}
+	type result struct {
+		f   *os.File
+		err error
+	}
+	ch := make(chan result)
+	go func() {
+		// The thread is never unlocked, so it exits with the goroutine
+		// instead of going back to the scheduler in the new namespace.
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			ch <- result{err: err}
+			return
+		}
+		f, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		ch <- result{f, err}
+	}()
+	r := <-ch
+	if r.err != nil {
+		t.Skipf("cannot create a network namespace: %v", r.err)
+	}
+	t.Cleanup(func() { r.f.Close() })
+	return r.f
+}
+
+// nsIP runs ip(8) with args inside ns.
+func nsIP(t *testing.T, ns *os.File, args ...string) {
+	t.Helper()
+	cmd := exec.Command("nsenter", append([]string{"--net=/proc/self/fd/3", "ip"}, args...)...)
+	cmd.ExtraFiles = []*os.File{ns}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ip %v: %v: %s", args, err, out)
+	}
+}
+
+// addDefaultRoute gives ns a veth pair and a default route over veth0
+// through a gateway with a permanent neighbour entry, which the passive
+// check accepts as online.
+func addDefaultRoute(t *testing.T, ns *os.File) {
+	t.Helper()
+	nsIP(t, ns, "link", "add", "veth0", "type", "veth", "peer", "name", "veth1")
+	nsIP(t, ns, "link", "set", "veth1", "up")
+	nsIP(t, ns, "link", "set", "veth0", "up")
+	nsIP(t, ns, "addr", "add", "10.99.0.2/24", "dev", "veth0")
+	nsIP(t, ns, "neigh", "add", "10.99.0.1", "lladdr", "02:00:00:00:00:01", "dev", "veth0", "nud", "permanent")
+	nsIP(t, ns, "route", "add", "default", "via", "10.99.0.1", "dev", "veth0")
+}
+
+// noEvent fails the test if an event arrives within half a second.
+func noEvent(t *testing.T, events <-chan netonline.Event) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %v", ev)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestWatchNetns(t *testing.T) {
+	ns := newTestNetns(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _ := netonline.Watch(ctx, netonline.WithNetworkNamespace(int(ns.Fd())), netonline.WithDebounce(100*time.Millisecond))
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("initial event in an empty namespace is online: %v", ev)
+	}
+
+	addDefaultRoute(t, ns)
+	ev := nextEvent(t, events)
+	if !ev.Online || ev.Interface != "veth0" {
+		t.Fatalf("after adding the default route: %v, want online over veth0", ev)
+	}
+
+	nsIP(t, ns, "route", "del", "default")
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("after removing the default route: %v, want offline", ev)
+	}
+}
+
+// TestInterfaceTypeFilterNetns evaluates a namespace whose only default
+// route goes through a veth interface: a WiFi-only filter reports it
+// offline, a filter that allows virtual interfaces online.
+func TestInterfaceTypeFilterNetns(t *testing.T) {
+	ns := newTestNetns(t)
+	addDefaultRoute(t, ns)
+	netns := netonline.WithNetworkNamespace(int(ns.Fd()))
+	online, why, err := netonline.Evaluate(netns, netonline.WithInterfaceTypeFilter(netonline.InterfaceWiFi))
+	if err != nil || online || why != "default iface type virtual filtered" {
+		t.Errorf("WiFi only: Evaluate = %v, %q, %v; want offline, filtered", online, why, err)
+	}
+	online, why, err = netonline.Evaluate(netns, netonline.WithInterfaceTypeFilter(netonline.InterfaceWiFi, netonline.InterfaceVirtual))
+	if err != nil || !online {
+		t.Errorf("WiFi or virtual: Evaluate = %v, %q, %v; want online", online, why, err)
+	}
+}
+
+// TestWatchNetnsIsolation watches two namespaces and changes only one:
+// its events, including a rename of the default interface, which is only
+// seen by index inside the namespace, must not reach the other watcher.
+func TestWatchNetnsIsolation(t *testing.T) {
+	nsA, nsB := newTestNetns(t), newTestNetns(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch := func(ns *os.File) <-chan netonline.Event {
+		events, _ := netonline.Watch(ctx, netonline.WithNetworkNamespace(int(ns.Fd())), netonline.WithDebounce(100*time.Millisecond))
+		if ev := nextEvent(t, events); ev.Online {
+			t.Fatalf("initial event in an empty namespace is online: %v", ev)
+		}
+		return events
+	}
+	eventsA, eventsB := watch(nsA), watch(nsB)
+
+	addDefaultRoute(t, nsA)
+	if ev := nextEvent(t, eventsA); !ev.Online || ev.Interface != "veth0" {
+		t.Fatalf("namespace A after adding the default route: %v, want online over veth0", ev)
+	}
+	noEvent(t, eventsB)
+
+	nsIP(t, nsA, "link", "set", "veth0", "name", "wan0")
+	ev := nextEvent(t, eventsA)
+	if ev.Cause != netonline.CauseInterfaceRenamed || ev.OldInterface != "veth0" || ev.NewInterface != "wan0" {
+		t.Fatalf("namespace A after the rename: %v, want veth0 renamed to wan0", ev)
+	}
+	noEvent(t, eventsB)
+}
+
+// TestNetlinkStreamNoLeak checks that the rtnetlink reader, which blocks in
+// the kernel rather than on a channel, stops once the watcher is cancelled.
+// An event from the namespace shows the reader has reached its loop.
+func TestNetlinkStreamNoLeak(t *testing.T) {
+	ns := newTestNetns(t)
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := netonline.Watch(ctx, netonline.WithNetworkNamespace(int(ns.Fd())), netonline.WithDebounce(100*time.Millisecond))
+	nextEvent(t, events)
+	addDefaultRoute(t, ns)
+	nextEvent(t, events)
+	cancel()
+	drain(events, errs)
+}
@@ -0,0 +1,72 @@
+package netonline_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"example.com/netonline/netonline"
+)
+
+// netsh runs netsh with args.
+func netsh(t *testing.T, args ...string) {
+	t.Helper()
+	if out, err := exec.Command("netsh", args...).CombinedOutput(); err != nil {
+		t.Fatalf("netsh %v: %v: %s", args, err, out)
+	}
+}
+
+// TestWatchLoopbackAdapter drives the Windows event pipeline with routes
+// through a loopback adapter. netsh cannot install adapters, so the test
+// needs one already installed (for example the Microsoft KM-TEST Loopback
+// Adapter, added with pnputil or devcon) and named in
+// NETONLINE_TEST_ADAPTER. Every other interface is excluded, so that the
+// host's own default route does not count. It changes the adapter's
+// address and the routing table, and skips when not elevated.
+func TestWatchLoopbackAdapter(t *testing.T) {
+	adapter := os.Getenv("NETONLINE_TEST_ADAPTER")
+	if adapter == "" {
+		t.Skip("NETONLINE_TEST_ADAPTER not set")
+	}
+	if !windows.GetCurrentProcessToken().IsElevated() {
+		t.Skip("needs an elevated process")
+	}
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var others []string
+	for _, ifi := range ifs {
+		if ifi.Name != adapter {
+			others = append(others, ifi.Name)
+		}
+	}
+	netsh(t, "interface", "ipv4", "set", "address", "name="+adapter, "static", "10.99.0.2", "255.255.255.0")
+	netsh(t, "interface", "ipv4", "set", "interface", adapter, "metric=1")
+	addRoute := []string{"interface", "ipv4", "add", "route", "0.0.0.0/0", adapter, "10.99.0.1", "metric=1", "store=active"}
+	delRoute := []string{"interface", "ipv4", "delete", "route", "0.0.0.0/0", adapter, "10.99.0.1", "store=active"}
+	t.Cleanup(func() { exec.Command("netsh", delRoute...).Run() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _ := netonline.Watch(ctx, netonline.WithExcludeInterfaces(others...), netonline.WithDebounce(100*time.Millisecond))
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("initial event with only excluded interfaces is online: %v", ev)
+	}
+
+	netsh(t, addRoute...)
+	ev := nextEvent(t, events)
+	if !ev.Online || ev.Interface != adapter {
+		t.Fatalf("after adding the default route: %v, want online over %s", ev, adapter)
+	}
+
+	netsh(t, delRoute...)
+	if ev := nextEvent(t, events); ev.Online {
+		t.Fatalf("after removing the default route: %v, want offline", ev)
+	}
+}
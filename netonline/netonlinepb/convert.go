@@ -0,0 +1,50 @@
+// Package netonlinepb holds the protobuf form of netonline events for
+// high-volume pipelines where JSON is too large. event.pb.go is generated
+// from event.proto with protoc-gen-go.
+package netonlinepb
+
+import (
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// EventToProto converts e to its protobuf form.
+func EventToProto(e netonline.Event) *Event {
+	return &Event{
+		Online:            e.Online,
+		ChangedAtUnixNano: e.ChangedAt.UnixNano(),
+		Cause:             e.CauseDetail,
+		Interface:         e.Interface,
+		Addr:              e.Addr,
+	}
+}
+
+// ProtoToEvent converts p back to a netonline.Event. Fields without a
+// protobuf counterpart, such as CheckResult and Cause (the message only
+// carries CauseDetail), are left zero.
+func ProtoToEvent(p *Event) netonline.Event {
+	return netonline.Event{
+		Online:      p.GetOnline(),
+		ChangedAt:   time.Unix(0, p.GetChangedAtUnixNano()),
+		CauseDetail: p.GetCause(),
+		Interface:   p.GetInterface(),
+		Addr:        p.GetAddr(),
+	}
+}
+
+// WakeEventToProto converts w to its protobuf form.
+func WakeEventToProto(w netonline.WakeEvent) *WakeEvent {
+	return &WakeEvent{
+		WokeAtUnixNano:     w.WokeAt.UnixNano(),
+		SleepDurationNanos: int64(w.SleepDuration),
+	}
+}
+
+// ProtoToWakeEvent converts p back to a netonline.WakeEvent.
+func ProtoToWakeEvent(p *WakeEvent) netonline.WakeEvent {
+	return netonline.WakeEvent{
+		SleepDuration: time.Duration(p.GetSleepDurationNanos()),
+		WokeAt:        time.Unix(0, p.GetWokeAtUnixNano()),
+	}
+}
@@ -0,0 +1,240 @@
+package netonlinetesting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// MockPlatform stands in for the operating system under a Watcher: it is
+// the OS event source, the passive check and the clock of all the
+// watcher's timers. Pass Options to netonline.NewWatcher or
+// netonline.Watch. Its methods may be called from any goroutine.
+//
+// InjectOSEvent and Advance return once the attached watchers have handled
+// what they caused, so that a test can check the outcome right away. Events
+// emitted meanwhile that do not fit in the event channel's buffer (see
+// netonline.WithEventBufferSize) are dropped unless another goroutine
+// receives them.
+type MockPlatform struct {
+	mu      sync.Mutex
+	idle    sync.Cond // signalled when busy drops to zero
+	busy    int       // events and timer firings not yet handled by a watcher
+	online  bool
+	cause   string
+	now     time.Time
+	timers  []*mockTimer
+	sources []*mockSource
+}
+
+type mockSource struct {
+	ctx context.Context
+	in  chan string
+}
+
+// NewMockPlatform returns a platform that reports offline until SetOnline.
+func NewMockPlatform() *MockPlatform {
+	m := &MockPlatform{cause: "mock: offline", now: time.Unix(0, 0)}
+	m.idle.L = &m.mu
+	return m
+}
+
+// Options returns the options that attach a Watcher to m.
+func (m *MockPlatform) Options() []netonline.Option {
+	return []netonline.Option{
+		netonline.WithEventSource(m.source),
+		netonline.WithCustomEvaluator(m.evaluate),
+		netonline.WithClock(m),
+		netonline.WithStepHook(m.step),
+	}
+}
+
+// SetOnline sets what the passive check reports from now on. Like a real
+// network change, it takes effect on the next evaluation, typically after
+// InjectOSEvent and an Advance past the debounce duration.
+func (m *MockPlatform) SetOnline(online bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.online = online
+	if online {
+		m.cause = "mock: online"
+	} else {
+		m.cause = "mock: offline"
+	}
+}
+
+// InjectOSEvent delivers an OS change notification with the given reason
+// to every running watcher attached to m, and returns once they have
+// handled it, that is armed their debounce timers.
+func (m *MockPlatform) InjectOSEvent(reason string) {
+	m.mu.Lock()
+	sources := append([]*mockSource(nil), m.sources...)
+	m.mu.Unlock()
+	for _, s := range sources {
+		m.mu.Lock()
+		m.busy++
+		m.mu.Unlock()
+		select {
+		case s.in <- reason:
+		case <-s.ctx.Done():
+			m.step()
+		}
+	}
+	m.waitIdle()
+}
+
+// Advance moves the fake clock forward by d, fires the timers that are
+// due, such as the debounce timer started by InjectOSEvent, and returns
+// once the watchers have handled them. Timers started with a duration of
+// zero, as with netonline.WithDebounce(0), fire without Advance.
+func (m *MockPlatform) Advance(d time.Duration) {
+	m.mu.Lock()
+	m.now = m.now.Add(d)
+	for _, t := range m.timers {
+		if t.active && !t.when.After(m.now) {
+			t.fire()
+		}
+	}
+	m.mu.Unlock()
+	m.waitIdle()
+}
+
+// step is the watchers' netonline.WithStepHook: one event or timer firing
+// has been handled.
+func (m *MockPlatform) step() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy > 0 {
+		m.busy--
+	}
+	if m.busy == 0 {
+		m.idle.Broadcast()
+	}
+}
+
+// waitIdle waits until the watchers have handled everything delivered to
+// them, or have all stopped.
+func (m *MockPlatform) waitIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.busy > 0 && len(m.sources) > 0 {
+		m.idle.Wait()
+	}
+}
+
+// NewTimer implements netonline.Clock.
+func (m *MockPlatform) NewTimer(d time.Duration) netonline.ClockTimer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &mockTimer{m: m, c: make(chan time.Time, 1)}
+	t.arm(d)
+	m.timers = append(m.timers, t)
+	return t
+}
+
+func (m *MockPlatform) evaluate(context.Context) (bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.online, m.cause, nil
+}
+
+func (m *MockPlatform) source(ctx context.Context) (<-chan string, <-chan error) {
+	s := &mockSource{ctx: ctx, in: make(chan string)}
+	m.mu.Lock()
+	m.sources = append(m.sources, s)
+	m.mu.Unlock()
+	out := make(chan string)
+	errc := make(chan error)
+	go func() {
+		defer close(out)
+		defer close(errc)
+		defer m.removeSource(s)
+		for {
+			select {
+			case reason := <-s.in:
+				select {
+				case out <- reason:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errc
+}
+
+func (m *MockPlatform) removeSource(s *mockSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, x := range m.sources {
+		if x == s {
+			m.sources = append(m.sources[:i], m.sources[i+1:]...)
+			break
+		}
+	}
+	if len(m.sources) == 0 {
+		// Whatever the stopped watchers left unhandled never will be.
+		m.busy = 0
+		m.idle.Broadcast()
+	}
+}
+
+// mockTimer is a netonline.ClockTimer driven by MockPlatform.Advance.
+type mockTimer struct {
+	m      *MockPlatform
+	c      chan time.Time
+	when   time.Time
+	active bool
+}
+
+func (t *mockTimer) C() <-chan time.Time { return t.c }
+
+func (t *mockTimer) Reset(d time.Duration) bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	was := t.active
+	t.drain()
+	t.arm(d)
+	return was
+}
+
+// arm starts t for d, firing it right away if d is not positive. t.m.mu
+// must be held.
+func (t *mockTimer) arm(d time.Duration) {
+	t.when, t.active = t.m.now.Add(d), true
+	if d <= 0 {
+		t.fire()
+	}
+}
+
+// fire delivers the current time on t's channel. t.m.mu must be held.
+func (t *mockTimer) fire() {
+	t.active = false
+	t.m.busy++
+	t.c <- t.m.now
+}
+
+// drain discards a firing that was not received, like Stop and Reset of a
+// *time.Timer do. t.m.mu must be held.
+func (t *mockTimer) drain() {
+	select {
+	case <-t.c:
+		if t.m.busy > 0 {
+			t.m.busy--
+		}
+	default:
+	}
+}
+
+func (t *mockTimer) Stop() bool {
+	t.m.mu.Lock()
+	defer t.m.mu.Unlock()
+	was := t.active
+	t.active = false
+	t.drain()
+	return was
+}
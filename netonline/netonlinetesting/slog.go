@@ -0,0 +1,193 @@
+// Package netonlinetesting provides helpers for testing code built on
+// netonline.
+package netonlinetesting
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// eventTimeout bounds how long ValidateSlogOutput waits for the state change
+// caused by trigger.
+const eventTimeout = 30 * time.Second
+
+// ValidateSlogOutput checks that handler copes with the records a Watcher
+// logs through WithLogger. It calls trigger, which must cause w to report a
+// state change, and passes the record logged for that event through handler.
+// It then runs the testing/slogtest cases against handler. Records are
+// checked as handler receives them, after its WithAttrs and WithGroup calls;
+// any error returned by handler.Handle fails t.
+func ValidateSlogOutput(t testing.TB, handler slog.Handler, w *netonline.Watcher, trigger func()) {
+	t.Helper()
+	changed := make(chan netonline.Event, 1)
+	cancel := w.Notify(func(ev netonline.Event) {
+		if ev.IsHeartbeat() {
+			return
+		}
+		select {
+		case changed <- ev:
+		default:
+		}
+	})
+	defer cancel()
+	trigger()
+	var ev netonline.Event
+	select {
+	case ev = <-changed:
+	case <-time.After(eventTimeout):
+		t.Fatalf("no state change within %v of trigger", eventTimeout)
+	}
+
+	rec := newRecorder(handler)
+	netonline.LogEvent(context.Background(), slog.New(rec), ev)
+	res := rec.results()
+	if len(res) != 1 {
+		t.Fatalf("event produced %d records, want 1", len(res))
+	}
+	group, ok := res[0]["event"].(map[string]any)
+	if !ok {
+		t.Errorf("record %v has no event group", res[0])
+	} else if online, ok := group["online"].(bool); !ok || online != ev.Online {
+		t.Errorf("record event.online = %v, want %v", group["online"], ev.Online)
+	}
+	if err := rec.err(); err != nil {
+		t.Errorf("handler: %v", err)
+	}
+
+	rec = newRecorder(handler)
+	if err := slogtest.TestHandler(rec, rec.results); err != nil {
+		t.Error(err)
+	}
+	if err := rec.err(); err != nil {
+		t.Errorf("handler: %v", err)
+	}
+}
+
+// recorder forwards records to next and keeps each one as the map
+// slogtest expects.
+type recorder struct {
+	next  slog.Handler
+	goas  []groupOrAttrs
+	state *recorderState
+}
+
+type recorderState struct {
+	mu      sync.Mutex
+	records []map[string]any
+	err     error
+}
+
+// groupOrAttrs is one WithGroup or WithAttrs call.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func newRecorder(next slog.Handler) *recorder {
+	return &recorder{next: next, state: &recorderState{}}
+}
+
+func (r *recorder) Enabled(ctx context.Context, l slog.Level) bool { return true }
+
+func (r *recorder) Handle(ctx context.Context, rec slog.Record) error {
+	m := map[string]any{
+		slog.LevelKey:   rec.Level,
+		slog.MessageKey: rec.Message,
+	}
+	if !rec.Time.IsZero() {
+		m[slog.TimeKey] = rec.Time
+	}
+	goas := r.goas
+	if rec.NumAttrs() == 0 {
+		// Groups without attributes are dropped.
+		for len(goas) > 0 && goas[len(goas)-1].group != "" {
+			goas = goas[:len(goas)-1]
+		}
+	}
+	cur := m
+	for _, g := range goas {
+		if g.group != "" {
+			sub := map[string]any{}
+			cur[g.group] = sub
+			cur = sub
+			continue
+		}
+		for _, a := range g.attrs {
+			addAttr(cur, a)
+		}
+	}
+	rec.Attrs(func(a slog.Attr) bool {
+		addAttr(cur, a)
+		return true
+	})
+
+	err := r.next.Handle(ctx, rec)
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	r.state.records = append(r.state.records, m)
+	if err != nil && r.state.err == nil {
+		r.state.err = err
+	}
+	return err
+}
+
+func (r *recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return r
+	}
+	return r.with(groupOrAttrs{attrs: attrs}, r.next.WithAttrs(attrs))
+}
+
+func (r *recorder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	return r.with(groupOrAttrs{group: name}, r.next.WithGroup(name))
+}
+
+func (r *recorder) with(g groupOrAttrs, next slog.Handler) *recorder {
+	goas := make([]groupOrAttrs, len(r.goas), len(r.goas)+1)
+	copy(goas, r.goas)
+	return &recorder{next: next, goas: append(goas, g), state: r.state}
+}
+
+func (r *recorder) results() []map[string]any {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return r.state.records
+}
+
+func (r *recorder) err() error {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	return r.state.err
+}
+
+func addAttr(m map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = a.Value.Any()
+		return
+	}
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	dst := m
+	if a.Key != "" {
+		dst = map[string]any{}
+		m[a.Key] = dst
+	}
+	for _, ga := range attrs {
+		addAttr(dst, ga)
+	}
+}
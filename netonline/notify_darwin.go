@@ -0,0 +1,114 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package netonline
+
+/*
+#cgo CFLAGS: -x objective-c -fobjc-arc -Wno-deprecated-declarations
+#cgo LDFLAGS: -framework Foundation -framework UserNotifications
+
+#include <stdlib.h>
+#import <Foundation/Foundation.h>
+#import <UserNotifications/UserNotifications.h>
+
+// UNUserNotificationCenter exists from macOS 10.14 on and needs the process to
+// run from an app bundle; older systems only have NSUserNotificationCenter.
+static void netonlineNotify(const char *title, const char *body) {
+	@autoreleasepool {
+		NSString *t = [NSString stringWithUTF8String:title];
+		NSString *b = [NSString stringWithUTF8String:body];
+		if (@available(macOS 10.14, *)) {
+			UNUserNotificationCenter *center = [UNUserNotificationCenter currentNotificationCenter];
+			[center requestAuthorizationWithOptions:UNAuthorizationOptionAlert
+				completionHandler:^(BOOL granted, NSError *error) {
+					if (!granted) {
+						return;
+					}
+					UNMutableNotificationContent *content = [[UNMutableNotificationContent alloc] init];
+					content.title = t;
+					content.body = b;
+					UNNotificationRequest *req = [UNNotificationRequest
+						requestWithIdentifier:[[NSUUID UUID] UUIDString] content:content trigger:nil];
+					[center addNotificationRequest:req withCompletionHandler:nil];
+				}];
+		} else {
+			NSUserNotification *n = [[NSUserNotification alloc] init];
+			n.title = t;
+			n.informativeText = b;
+			[[NSUserNotificationCenter defaultUserNotificationCenter] deliverNotification:n];
+		}
+	}
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// MacOSNotifier shows a macOS notification banner whenever a subscribed
+// Watcher reports a state change. On macOS 10.14 and later it uses
+// UNUserNotificationCenter, which only works from an app bundle and asks the
+// user for permission on first use; older releases use
+// NSUserNotificationCenter.
+type MacOSNotifier struct {
+	queue chan Event
+	quit  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewMacOSNotifier starts a notifier.
+func NewMacOSNotifier() *MacOSNotifier {
+	n := &MacOSNotifier{
+		queue: make(chan Event, 8),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Subscribe shows a banner for every state change w reports from now on;
+// heartbeats are ignored. The returned function stops the notifications.
+func (n *MacOSNotifier) Subscribe(w *Watcher) (cancel func()) {
+	return w.Notify(func(ev Event) {
+		if ev.IsHeartbeat() {
+			return
+		}
+		select {
+		case n.queue <- ev:
+		default:
+		}
+	})
+}
+
+// Close stops the notifier.
+func (n *MacOSNotifier) Close() {
+	n.once.Do(func() { close(n.quit) })
+	<-n.done
+}
+
+func (n *MacOSNotifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case <-n.quit:
+			return
+		case ev := <-n.queue:
+			title := "Network offline"
+			if ev.Online {
+				title = "Network online"
+			}
+			body := ev.CauseDetail
+			if ev.Interface != "" {
+				body += " (" + ev.Interface + ")"
+			}
+			ct, cb := C.CString(title), C.CString(body)
+			C.netonlineNotify(ct, cb)
+			C.free(unsafe.Pointer(ct))
+			C.free(unsafe.Pointer(cb))
+		}
+	}
+}
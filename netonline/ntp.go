@@ -0,0 +1,96 @@
+package netonline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Minimal RFC 5905 client mode, enough to see that a server answers and is
+// synchronised.
+const (
+	ntpPacketLen  = 48
+	ntpModeClient = 3
+	ntpModeServer = 4
+	ntpVersion    = 4
+	ntpLeapAlarm  = 3 // leap indicator: clock unsynchronised
+	ntpMaxStratum = 15
+
+	// ntpProbeTimeout bounds a single NTP probe when ctx has no earlier
+	// deadline.
+	ntpProbeTimeout = 2 * time.Second
+	// ntpMaxRoundTrip is the longest round trip ProbeNTP accepts; a slower
+	// answer is useless for setting the clock.
+	ntpMaxRoundTrip = time.Second
+)
+
+// ProbeNTP sends one NTPv4 client request to server (host or host:port,
+// port 123 by default) and succeeds if a synchronised server (stratum 1 to
+// 15) answers within a second.
+func ProbeNTP(server string) ProbeFunc {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, ntpProbeTimeout)
+		defer cancel()
+		var d net.Dialer
+		c, err := d.DialContext(ctx, "udp", server)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		stop := context.AfterFunc(ctx, func() { c.Close() })
+		defer stop()
+
+		// The transmit timestamp is a nonce the server echoes as the
+		// origin timestamp; RFC 5905 lets clients send any value.
+		req := make([]byte, ntpPacketLen)
+		req[0] = ntpVersion<<3 | ntpModeClient
+		_, _ = rand.Read(req[40:48])
+		sent := time.Now()
+		if _, err := c.Write(req); err != nil {
+			return err
+		}
+		buf := make([]byte, 1500)
+		for {
+			n, err := c.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return err
+			}
+			rtt := time.Since(sent)
+			b := buf[:n]
+			if n < ntpPacketLen || b[0]&0x7 != ntpModeServer || string(b[24:32]) != string(req[40:48]) {
+				continue // not an answer to this request
+			}
+			return checkNTPResponse(b, rtt)
+		}
+	}
+}
+
+// checkNTPResponse validates the server reply b that arrived after rtt.
+func checkNTPResponse(b []byte, rtt time.Duration) error {
+	stratum := b[1]
+	if stratum == 0 {
+		// Kiss-o'-Death: the reference ID holds an ASCII code such as
+		// RATE or DENY.
+		return fmt.Errorf("ntp: stratum 0 (kiss code %q)", b[12:16])
+	}
+	if stratum > ntpMaxStratum || b[0]>>6 == ntpLeapAlarm {
+		return fmt.Errorf("ntp: server unsynchronised (stratum %d)", stratum)
+	}
+	if binary.BigEndian.Uint64(b[40:48]) == 0 {
+		return errors.New("ntp: response without transmit timestamp")
+	}
+	if rtt > ntpMaxRoundTrip {
+		return fmt.Errorf("ntp: round trip %v too long", rtt.Round(time.Millisecond))
+	}
+	return nil
+}
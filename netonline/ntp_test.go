@@ -0,0 +1,77 @@
+package netonline_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"example.com/netonline/netonline"
+)
+
+// ntpServer answers every NTP request on a local UDP port with a reply of
+// the given stratum, and returns its address.
+func ntpServer(t *testing.T, stratum byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0] = 4<<3 | 4 // version 4, server mode, no leap warning
+			resp[1] = stratum
+			if stratum == 0 {
+				copy(resp[12:16], "RATE")
+			} else {
+				copy(resp[12:16], "GPS\x00")
+			}
+			copy(resp[24:32], buf[40:48]) // origin timestamp
+			binary.BigEndian.PutUint64(resp[40:48], 0xe8000000_00000000)
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestProbeNTP(t *testing.T) {
+	probe := netonline.ProbeNTP(ntpServer(t, 1))
+	if err := probe(context.Background()); err != nil {
+		t.Fatalf("stratum 1 server: %v", err)
+	}
+}
+
+func TestProbeNTPStratum0(t *testing.T) {
+	probe := netonline.ProbeNTP(ntpServer(t, 0))
+	err := probe(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "RATE") {
+		t.Fatalf("stratum 0 server: got %v, want a kiss code error", err)
+	}
+}
+
+func TestProbeNTPConcurrent(t *testing.T) {
+	probe := netonline.ProbeNTP(ntpServer(t, 2))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := probe(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,370 @@
+package netonline
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WatchOption is an alias of Option; every Option is accepted by Watch.
+type WatchOption = Option
+
+// Option configures Watch, NewWatcher and Evaluate. It is sealed: only the
+// With* functions of this package create Options.
+type Option interface {
+	applyOption(*config)
+}
+
+// option is the only implementation of Option.
+type option struct{ fn func(*config) }
+
+func (o *option) applyOption(c *config) { o.fn(c) }
+
+var _ Option = (*option)(nil)
+
+func newOption(fn func(*config)) Option { return &option{fn: fn} }
+
+type config struct {
+	preferStable  bool
+	checker       *ConnectivityChecker
+	holdOnline    bool
+	family        AddressFamily
+	heartbeat     time.Duration
+	stabilize     time.Duration
+	lazy          bool
+	maxReconnect  int
+	logger        *slog.Logger
+	ifaceKinds    []InterfaceKind
+	semantics     ChannelSemantics
+	debounce      time.Duration
+	excluded      []string
+	asyncInitial  bool
+	suppressInit  bool
+	transitions   bool
+	evaluator     func(ctx context.Context) (online bool, cause string, err error)
+	eventSource   func(ctx context.Context) (<-chan string, <-chan error)
+	clock         Clock
+	stepHook      func()
+	buffer        int
+	onlineDelay   time.Duration
+	offlineDelay  time.Duration
+	captive       bool
+	noNM          bool
+	wireGuard     bool
+	netlinkRcvBuf int
+	netns         *netnsTarget
+	evalCacheTTL  time.Duration
+}
+
+// netnsTarget is the network namespace set with WithNetworkNamespace or
+// WithNetworkNamespacePath.
+type netnsTarget struct {
+	fd   int
+	path string
+}
+
+// AddressFamily selects the IP families the OS event source subscribes to.
+type AddressFamily int
+
+const (
+	FamilyUnspec AddressFamily = iota // both IPv4 and IPv6 (default)
+	FamilyIPv4
+	FamilyIPv6
+)
+
+// defaultDebounce is how long Watch waits for OS notifications to settle
+// before evaluating the state.
+const defaultDebounce = 750 * time.Millisecond
+
+// defaultEvalCacheTTL is how long a Watcher reuses a passive evaluation; see
+// WithEvalCacheTTL.
+const defaultEvalCacheTTL = 500 * time.Millisecond
+
+func newConfig(opts []Option) *config {
+	cfg := &config{debounce: defaultDebounce, evalCacheTTL: defaultEvalCacheTTL, clock: realClock{}, buffer: 1}
+	for _, o := range opts {
+		if o != nil {
+			o.applyOption(cfg)
+		}
+	}
+	return cfg
+}
+
+// WithPreferStableAddresses makes the passive check ignore IPv6 temporary
+// (privacy) addresses. An interface whose only global addresses are
+// temporary is then treated as having no usable IP. Only Windows currently
+// distinguishes temporary addresses; other platforms ignore this option.
+func WithPreferStableAddresses() Option {
+	return newOption(func(c *config) { c.preferStable = true })
+}
+
+// WithConnectivityChecker attaches an active checker to Watch. After every
+// passive evaluation that reports online, the checker runs and its result is
+// attached to the emitted Event; Event.Online is then true only if both the
+// passive and the active checks passed.
+func WithConnectivityChecker(c *ConnectivityChecker) Option {
+	return newOption(func(cfg *config) { cfg.checker = c })
+}
+
+// WithActiveValidation gates online events on the active checker: a passive
+// online result is held back until checker.Check passes (or gives up at the
+// checker's timeout). While offline, a failed validation emits nothing and
+// the next OS event triggers another attempt; while online, it is reported
+// as offline with CauseValidationFailed. Passive offline results are
+// emitted immediately without running the checker.
+func WithActiveValidation(checker *ConnectivityChecker) Option {
+	return newOption(func(cfg *config) {
+		cfg.checker = checker
+		cfg.holdOnline = true
+	})
+}
+
+// WithAddressFamily restricts the OS change notifications Watch subscribes
+// to. On hosts with flapping IPv6, FamilyIPv4 avoids debouncing on changes
+// that cannot affect IPv4 connectivity. On Windows it is passed to
+// NotifyIpInterfaceChange and NotifyRouteChange2; on Linux it selects the
+// RTMGRP_IPV4_* or RTMGRP_IPV6_* netlink groups (link changes are always
+// subscribed); NetworkManager's signals, when used, are not filtered.
+func WithAddressFamily(f AddressFamily) Option {
+	return newOption(func(cfg *config) { cfg.family = f })
+}
+
+// WithNetworkManagerIntegration selects whether Watch may use NetworkManager
+// on Linux; it does by default when NetworkManager is running. Its D-Bus
+// signals are then the preferred event source, with the rtnetlink socket
+// alongside to catch changes made outside NetworkManager, and it answers
+// Event.Metered. Passing false always uses the rtnetlink socket alone and
+// never connects to D-Bus, for example on embedded systems without it where
+// connecting would only waste time. It has no effect on other platforms.
+func WithNetworkManagerIntegration(enabled bool) Option {
+	return newOption(func(cfg *config) { cfg.noNM = !enabled })
+}
+
+// WithNetlinkRcvBuf sets the receive buffer of the Linux netlink socket to
+// bytes. Bursts of changes, for example from container orchestration, can
+// overflow the kernel default; the watcher then recovers (see
+// NetlinkOverflowError), but a larger buffer avoids the lost notifications.
+// Beyond net.core.rmem_max it needs CAP_NET_ADMIN. It has no effect on other
+// platforms.
+func WithNetlinkRcvBuf(bytes int) Option {
+	return newOption(func(cfg *config) { cfg.netlinkRcvBuf = bytes })
+}
+
+// WithNetworkNamespace makes Watch and Evaluate observe the Linux network
+// namespace that fd refers to, such as the host's from inside a container or
+// a pod's: the watcher opens its netlink socket there and evaluates the
+// state on a thread that joins it with setns(2), which needs CAP_SYS_ADMIN.
+// fd must stay open while the watcher runs. sysfs keeps describing the
+// namespace it was mounted in, so the carrier comes from the interface flags
+// instead, bonds are not inspected, Event.InterfaceKind and Event.Metered
+// stay unset and NetworkManager is not used. It has no effect on other
+// platforms.
+func WithNetworkNamespace(fd int) Option {
+	return newOption(func(cfg *config) { cfg.netns = &netnsTarget{fd: fd} })
+}
+
+// WithNetworkNamespacePath is WithNetworkNamespace for a namespace file,
+// such as /var/run/netns/blue or /proc/1/ns/net, which is opened on every
+// use.
+func WithNetworkNamespacePath(path string) Option {
+	return newOption(func(cfg *config) { cfg.netns = &netnsTarget{path: path} })
+}
+
+// WithHeartbeatInterval makes Watch re-emit the current state every d even
+// when nothing changed, so downstream watchdogs can tell the watcher is alive.
+// Heartbeats carry Cause "heartbeat" and report true from Event.IsHeartbeat.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return newOption(func(cfg *config) { cfg.heartbeat = d })
+}
+
+// WithOnlineStabilizationDelay holds back an initial online event until no OS
+// change notification has arrived for d. Every notification during the wait
+// restarts it; when it expires the state is evaluated again and that result
+// becomes the initial event. Useful for NICs that flap while booting. An
+// initial offline state is still reported immediately.
+func WithOnlineStabilizationDelay(d time.Duration) Option {
+	return newOption(func(cfg *config) { cfg.stabilize = d })
+}
+
+// WithLazyStart defers all work of a Watcher until the first call to
+// Events: no OS subscription is opened and no initial evaluation is done
+// before then. Useful for watchers constructed at package initialization.
+func WithLazyStart() Option {
+	return newOption(func(cfg *config) { cfg.lazy = true })
+}
+
+// WithMaxReconnectAttempts caps how often a Watcher restarts a failed OS
+// event stream (for example a netlink socket refused with EPERM). Restarts
+// back off exponentially from 1s to 60s and the state is polled while the
+// stream is down. After n failed attempts the watcher reports an error and
+// keeps polling. The default, 0, retries forever.
+func WithMaxReconnectAttempts(n int) Option {
+	return newOption(func(cfg *config) { cfg.maxReconnect = n })
+}
+
+// WithLogger makes a Watcher log every event it emits and every error it
+// reports to l. Events are logged as described for LogEvent; errors at Warn
+// under the key "err".
+func WithLogger(l *slog.Logger) Option {
+	return newOption(func(cfg *config) { cfg.logger = l })
+}
+
+// WithInterfaceTypeFilter makes the passive check consider only interfaces
+// of the given kinds: when the default route goes through an interface of
+// another kind, the state is offline. For example,
+// WithInterfaceTypeFilter(InterfaceEthernet) reports offline on a machine
+// that is only connected over WiFi.
+func WithInterfaceTypeFilter(kinds ...InterfaceKind) Option {
+	return newOption(func(cfg *config) { cfg.ifaceKinds = kinds })
+}
+
+// ChannelSemantics selects which event a Watcher drops when its event
+// channel is full because the consumer is slow. Either way the watcher
+// itself never waits for the consumer.
+type ChannelSemantics int
+
+const (
+	// OrderedFIFO keeps the buffered events: when the channel is full, the
+	// new event is discarded (see Watcher.DroppedEvents). The consumer sees
+	// the events it gets in order, but after falling behind it holds stale
+	// ones and misses the current state until the next change. This is the
+	// default.
+	OrderedFIFO ChannelSemantics = iota
+	// LatestWins discards the oldest buffered event instead (see
+	// Watcher.DroppedEvents) to make room for the new one. The consumer
+	// always catches up to the current state but may miss intermediate
+	// transitions.
+	LatestWins
+)
+
+// WithChannelSemantics sets how a Watcher's event channel behaves when the
+// consumer falls behind. Listeners registered with Notify see every event
+// either way.
+func WithChannelSemantics(s ChannelSemantics) Option {
+	return newOption(func(cfg *config) { cfg.semantics = s })
+}
+
+// WithDebounce sets how long a Watcher waits after an OS change
+// notification, restarting the wait with every further one, before it
+// evaluates the state. The default is 750ms. A d of zero turns debouncing
+// off: every OS change notification is evaluated right away, which is
+// mostly useful in tests. A negative d keeps the default.
+func WithDebounce(d time.Duration) Option {
+	return newOption(func(cfg *config) {
+		if d >= 0 {
+			cfg.debounce = d
+		}
+	})
+}
+
+// WithDebounceDuration is the same as WithDebounce.
+//
+// Deprecated: Use WithDebounce.
+func WithDebounceDuration(d time.Duration) Option {
+	return WithDebounce(d)
+}
+
+// WithEvalCacheTTL sets how long a Watcher reuses the result of its
+// passive check (routes, interfaces and addresses) instead of asking the
+// kernel again, 500ms by default. Every OS change notification discards the
+// cached result, so it only spares evaluations that follow each other
+// without one, such as a fallback poll. A d of zero or less turns the cache
+// off. Custom evaluators (WithCustomEvaluator) are never cached, and neither
+// are Evaluate and Poll.
+func WithEvalCacheTTL(d time.Duration) Option {
+	return newOption(func(cfg *config) { cfg.evalCacheTTL = d })
+}
+
+// WithOnlineDebounce delays online events: a change to online is only
+// emitted if an evaluation d after the one that first saw it still reports
+// online. A flap back to offline in between emits nothing. The wait comes on
+// top of the OS event debounce (see WithDebounce).
+func WithOnlineDebounce(d time.Duration) Option {
+	return newOption(func(cfg *config) { cfg.onlineDelay = d })
+}
+
+// WithOfflineDebounce is WithOnlineDebounce for changes to offline. A
+// longer offline than online debounce hides short outages such as a WiFi
+// handoff, while reconnections are still reported quickly.
+func WithOfflineDebounce(d time.Duration) Option {
+	return newOption(func(cfg *config) { cfg.offlineDelay = d })
+}
+
+// directionDebounce returns the extra debounce for a change to online if
+// online is true and for a change to offline otherwise.
+func (c *config) directionDebounce(online bool) time.Duration {
+	if online {
+		return c.onlineDelay
+	}
+	return c.offlineDelay
+}
+
+// WithEventBufferSize sets the capacity of the event channel, 1 by default.
+// A larger buffer lets a slow consumer fall behind by up to n events before
+// events are dropped, as ChannelSemantics describes. Values below 1 keep
+// the default.
+func WithEventBufferSize(n int) Option {
+	return newOption(func(cfg *config) {
+		if n > 0 {
+			cfg.buffer = n
+		}
+	})
+}
+
+// WithSkipInitialEvent is the same as WithSuppressInitial.
+//
+// Deprecated: Use WithSuppressInitial.
+func WithSkipInitialEvent() Option {
+	return WithSuppressInitial()
+}
+
+// WithExcludeInterfaces makes the passive check treat a default route
+// through one of the named interfaces as no default route, for example to
+// ignore a management NIC or a docker bridge.
+func WithExcludeInterfaces(names ...string) Option {
+	return newOption(func(cfg *config) { cfg.excluded = append(cfg.excluded, names...) })
+}
+
+// WithAsyncInitialEvent moves the initial evaluation and event from
+// NewWatcher (or, with WithLazyStart, the first Events call) to the watch
+// goroutine, so that constructing a watcher returns immediately even when
+// the passive check or an attached ConnectivityChecker is slow.
+func WithAsyncInitialEvent() Option {
+	return newOption(func(cfg *config) { cfg.asyncInitial = true })
+}
+
+// WithSuppressInitial skips the initial event that reports the state found
+// at start. The state is still recorded (see Watcher.State), and the first
+// event is the first change from it.
+func WithSuppressInitial() Option {
+	return newOption(func(cfg *config) { cfg.suppressInit = true })
+}
+
+// WithEmitOnlyTransitions emits only events whose Online differs from the
+// previous one, including the suppressed initial state (it implies
+// WithSuppressInitial). Interface renames and heartbeats, which repeat the
+// current state, are not emitted either.
+func WithEmitOnlyTransitions() Option {
+	return newOption(func(cfg *config) {
+		cfg.suppressInit = true
+		cfg.transitions = true
+	})
+}
+
+// WithCustomEvaluator replaces the built-in passive check (default route,
+// interface, usable address, DNS) with fn, for topologies it cannot see
+// such as SDN overlays or VRF tables. Everything else stays the same: OS
+// events still trigger evaluations, which are debounced, validated by an
+// attached ConnectivityChecker, recorded in history and stats and emitted as
+// events carrying cause in Event.CauseDetail.
+//
+// fn runs on the watch goroutine with the watcher's context
+// (context.Background for Evaluate) and must return promptly once it is
+// cancelled; no events are delivered while it runs. A non-nil err is
+// reported on Errors, as for the built-in check. Options that only affect
+// the built-in check, such as WithAddressFamily, WithExcludeInterfaces or
+// WithInterfaceTypeFilter, are ignored, and Event.Interface and Event.Addr
+// are left empty.
+func WithCustomEvaluator(fn func(ctx context.Context) (online bool, cause string, err error)) Option {
+	return newOption(func(cfg *config) { cfg.evaluator = fn })
+}
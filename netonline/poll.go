@@ -0,0 +1,81 @@
+package netonline
+
+import (
+	"context"
+	"time"
+)
+
+// PollOption configures Poll.
+type PollOption func(*pollConfig)
+
+type pollConfig struct {
+	validate bool
+	require  int
+	probes   []Probe
+	timeout  time.Duration
+}
+
+// WithValidation makes Poll confirm a passive online result with active
+// probes, like WithConnectivityChecker does for a Watcher.
+func WithValidation() PollOption {
+	return func(c *pollConfig) { c.validate = true }
+}
+
+// WithPollQuorum sets how many probes must succeed under WithValidation;
+// 1 by default.
+func WithPollQuorum(n int) PollOption {
+	return func(c *pollConfig) { c.require = n }
+}
+
+// WithPollProbes sets the probes run under WithValidation instead of the
+// DefaultChecker ones.
+func WithPollProbes(probes ...Probe) PollOption {
+	return func(c *pollConfig) { c.probes = probes }
+}
+
+// WithPollTimeout bounds the whole Poll, in addition to ctx.
+func WithPollTimeout(d time.Duration) PollOption {
+	return func(c *pollConfig) { c.timeout = d }
+}
+
+// Poll answers "am I online right now?" in one synchronous call: it runs the
+// passive check and, with WithValidation and a passive online result, the
+// active probes, and returns the outcome as an Event with CheckResult set
+// whenever the probes ran. ctx's deadline (or WithPollTimeout) bounds the
+// whole call; without either the probes give up after 5 seconds. The error
+// is that of the passive check; the Event describes what was found anyway.
+func Poll(ctx context.Context, opts ...PollOption) (Event, error) {
+	pc := &pollConfig{}
+	for _, o := range opts {
+		o(pc)
+	}
+	if pc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pc.timeout)
+		defer cancel()
+	}
+	var wopts []Option
+	if pc.validate {
+		c := NewChecker(0, pc.require)
+		if deadline, ok := ctx.Deadline(); ok {
+			c.Timeout = time.Until(deadline)
+		}
+		if pc.probes == nil {
+			c.probes = defaultProbes()
+		}
+		for _, p := range pc.probes {
+			c.Register(p.Name(), p.Check)
+		}
+		wopts = append(wopts, WithConnectivityChecker(c))
+	}
+	st, res, err := evaluateWith(ctx, newConfig(wopts), nil, nil)
+	ev := Event{Online: st.online, ChangedAt: time.Now(), Cause: CauseOther, CauseDetail: "poll: " + st.why,
+		Interface: st.iface, Addr: st.addr, CheckResult: res, CaptivePortalURL: st.portalURL(), Metered: st.metered, InterfaceKind: st.kind}
+	switch {
+	case st.portal != "":
+		ev.Cause = CauseCaptivePortal
+	case res != nil && !res.OK:
+		ev.Cause = CauseValidationFailed
+	}
+	return ev, err
+}
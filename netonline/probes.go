@@ -0,0 +1,405 @@
+package netonline
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/proxy"
+)
+
+// ProbeFunc is a single active connectivity probe. It returns nil on success.
+type ProbeFunc func(ctx context.Context) error
+
+// tcpProbeTimeout bounds a single TCP probe, including any proxy handshake.
+const tcpProbeTimeout = 1200 * time.Millisecond
+
+// ProbeDNS resolves host with the system resolver.
+func ProbeDNS(host string) ProbeFunc {
+	return func(ctx context.Context) error {
+		var r net.Resolver
+		_, err := r.LookupHost(ctx, host)
+		return err
+	}
+}
+
+// dohProbeName is the domain ProbeDoH resolves.
+const dohProbeName = "example.com."
+
+// dohRootCAs verifies DoH resolvers; nil means the system roots. Replaced
+// in tests, whose resolver has a self-signed certificate.
+var dohRootCAs *x509.CertPool
+
+// ProbeDoH resolves example.com's A record through the DNS-over-HTTPS
+// resolver at resolverURL (for example https://cloudflare-dns.com/dns-query)
+// with an RFC 8484 GET request, and succeeds if the answer holds at least
+// one address. The resolver's certificate is verified, so a success also
+// shows that DNS can be kept private on this network. Proxies are taken
+// from the environment. Errors are prefixed with "doh:" and wrap their
+// cause, such as the context's error.
+func ProbeDoH(resolverURL string) ProbeFunc {
+	return func(ctx context.Context) error {
+		q := dnsmessage.Message{
+			Header:    dnsmessage.Header{RecursionDesired: true}, // ID 0, as RFC 8484 recommends for caching
+			Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(dohProbeName), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+		}
+		wire, err := q.Pack()
+		if err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		u, err := url.Parse(resolverURL)
+		if err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		v := u.Query()
+		v.Set("dns", base64.RawURLEncoding.EncodeToString(wire))
+		u.RawQuery = v.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		req.Header.Set("Accept", "application/dns-message")
+		tr := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: &tls.Config{RootCAs: dohRootCAs}}
+		defer tr.CloseIdleConnections()
+		cl := &http.Client{Transport: tr, Timeout: 3 * time.Second}
+		resp, err := cl.Do(req)
+		if err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("doh: unexpected status %s", resp.Status)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		var m dnsmessage.Message
+		if err := m.Unpack(body); err != nil {
+			return fmt.Errorf("doh: %w", err)
+		}
+		if !m.Response || m.RCode != dnsmessage.RCodeSuccess {
+			return fmt.Errorf("doh: rcode %s", m.RCode)
+		}
+		for _, a := range m.Answers {
+			if _, ok := a.Body.(*dnsmessage.AResource); ok {
+				return nil
+			}
+		}
+		return errors.New("doh: no address in answer")
+	}
+}
+
+type probeDialerKey struct{}
+
+// dialProbeTCP connects to addr within tcpProbeTimeout, through the dialer
+// set with ConnectivityChecker.WithDialer if there is one.
+func dialProbeTCP(ctx context.Context, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, tcpProbeTimeout)
+	defer cancel()
+	switch d := ctx.Value(probeDialerKey{}).(type) {
+	case proxy.ContextDialer:
+		return d.DialContext(ctx, "tcp", addr)
+	case proxy.Dialer:
+		return d.Dial("tcp", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// ProbeTCP opens (and immediately closes) a TCP connection to addr.
+func ProbeTCP(addr string) ProbeFunc {
+	return func(ctx context.Context) error {
+		c, err := dialProbeTCP(ctx, addr)
+		if err != nil {
+			return err
+		}
+		_ = c.Close()
+		return nil
+	}
+}
+
+// ProbeTCPViaSocks5 connects to targetAddr through the SOCKS5 proxy at
+// proxyAddr. The probe fails if the proxy connection, the SOCKS5 handshake
+// and the CONNECT together take longer than the per-probe TCP timeout.
+func ProbeTCPViaSocks5(proxyAddr, targetAddr string) ProbeFunc {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, tcpProbeTimeout)
+		defer cancel()
+		d, err := proxy.SOCKS5("tcp", proxyAddr, nil, &net.Dialer{Timeout: tcpProbeTimeout})
+		if err != nil {
+			return fmt.Errorf("socks5 %s: %w", proxyAddr, err)
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("socks5 %s: dialer does not support contexts", proxyAddr)
+		}
+		c, err := cd.DialContext(ctx, "tcp", targetAddr)
+		if err != nil {
+			return err
+		}
+		_ = c.Close()
+		return nil
+	}
+}
+
+// ProbeJitter measures samples sequential TCP handshakes with target and
+// fails when the standard deviation of the handshake times exceeds maxJitter
+// (50ms if zero). Congested WiFi often shows low average latency but high
+// jitter. The jitter and mean handshake time are reported as "jitter_ms" and
+// "latency_ms" (see SetProbeExtra).
+func ProbeJitter(target string, samples int, maxJitter time.Duration) ProbeFunc {
+	if samples < 2 {
+		samples = 5
+	}
+	if maxJitter <= 0 {
+		maxJitter = 50 * time.Millisecond
+	}
+	return func(ctx context.Context) error {
+		ms := make([]float64, 0, samples)
+		for i := 0; i < samples; i++ {
+			start := time.Now()
+			c, err := dialProbeTCP(ctx, target)
+			if err != nil {
+				return err
+			}
+			ms = append(ms, float64(time.Since(start))/float64(time.Millisecond))
+			_ = c.Close()
+		}
+		var mean, variance float64
+		for _, v := range ms {
+			mean += v
+		}
+		mean /= float64(len(ms))
+		for _, v := range ms {
+			variance += (v - mean) * (v - mean)
+		}
+		jitter := math.Sqrt(variance / float64(len(ms)))
+		SetProbeExtra(ctx, "jitter_ms", jitter)
+		SetProbeExtra(ctx, "latency_ms", mean)
+		if max := float64(maxJitter) / float64(time.Millisecond); jitter > max {
+			return fmt.Errorf("jitter %.1fms exceeds %.1fms", jitter, max)
+		}
+		return nil
+	}
+}
+
+// udpLossTimeout bounds how long ProbeUDPLoss waits for echoes.
+const udpLossTimeout = 2 * time.Second
+
+// ProbeUDPLoss estimates packet loss by sending packets STUN binding requests
+// to addr, which must answer them (an empty addr selects a public STUN
+// server), and counting the responses that arrive in time. It fails when more
+// than half are lost and reports the loss percentage as "loss_pct" (see
+// SetProbeExtra). This catches networks where TCP handshakes still succeed but
+// datagrams are mostly dropped.
+func ProbeUDPLoss(addr string, packets int) ProbeFunc {
+	if addr == "" {
+		addr = defaultSTUNServer
+	}
+	if packets <= 0 {
+		packets = 10
+	}
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, udpLossTimeout)
+		defer cancel()
+		var d net.Dialer
+		c, err := d.DialContext(ctx, "udp", addr)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		stop := context.AfterFunc(ctx, func() { c.Close() })
+		defer stop()
+
+		pending := make(map[stunTxID]bool, packets)
+		reqs := make([][]byte, packets)
+		for i := range reqs {
+			id := newSTUNTxID()
+			pending[id] = true
+			reqs[i] = stunRequest(id)
+		}
+		go func() {
+			for _, req := range reqs {
+				if _, err := c.Write(req); err != nil {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}()
+		received := 0
+		buf := make([]byte, 1500)
+		for received < packets {
+			n, err := c.Read(buf)
+			if err != nil {
+				break
+			}
+			if id, _, ok := parseSTUNResponse(buf[:n]); ok && pending[id] {
+				delete(pending, id)
+				received++
+			}
+		}
+		loss := 100 * float64(packets-received) / float64(packets)
+		SetProbeExtra(ctx, "loss_pct", loss)
+		if loss > 50 {
+			return fmt.Errorf("udp loss %.0f%% (%d/%d answered)", loss, received, packets)
+		}
+		return nil
+	}
+}
+
+// DefaultProbeUserAgent is the User-Agent sent by HTTP probes unless
+// overridden with WithHTTPUserAgent.
+const DefaultProbeUserAgent = "netonline-probe/1.0"
+
+// HTTPProbeOptions holds the request settings of HTTP probes.
+type HTTPProbeOptions struct {
+	UserAgent string
+	Headers   http.Header
+	// PinnedCertSHA256, if set, is the SHA-256 fingerprint the server's leaf
+	// certificate must have. Only used by ProbeHTTPS.
+	PinnedCertSHA256 *[32]byte
+}
+
+// HTTPProbeOption configures an HTTP probe.
+type HTTPProbeOption func(*HTTPProbeOptions)
+
+// WithHTTPUserAgent sets the User-Agent of probe requests. Captive portals
+// often key their behaviour on it, so mimicking a browser can give a more
+// accurate result.
+func WithHTTPUserAgent(ua string) HTTPProbeOption {
+	return func(o *HTTPProbeOptions) { o.UserAgent = ua }
+}
+
+// WithHTTPHeaders adds headers to probe requests. A User-Agent given here
+// takes precedence over WithHTTPUserAgent.
+func WithHTTPHeaders(headers http.Header) HTTPProbeOption {
+	return func(o *HTTPProbeOptions) { o.Headers = headers }
+}
+
+// WithPinnedCertSHA256 makes ProbeHTTPS accept only a server whose leaf
+// certificate has the given SHA-256 fingerprint (of its DER encoding). The
+// pin replaces chain verification, so internal endpoints with private or
+// self-signed certificates can be pinned; a transparent TLS-intercepting
+// proxy fails the check.
+func WithPinnedCertSHA256(fingerprint [32]byte) HTTPProbeOption {
+	return func(o *HTTPProbeOptions) { o.PinnedCertSHA256 = &fingerprint }
+}
+
+func newHTTPProbeOptions(opts []HTTPProbeOption) *HTTPProbeOptions {
+	o := &HTTPProbeOptions{UserAgent: DefaultProbeUserAgent}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// newRequest builds a GET request for target carrying o's headers.
+func (o *HTTPProbeOptions) newRequest(ctx context.Context, target string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	for k, vs := range o.Headers {
+		req.Header.Del(k)
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	return req, nil
+}
+
+// ProbeHTTPWithProxy GETs url and expects a 204 No Content response. The
+// request goes through the proxy configured in the environment (HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY) or, on Windows, without one, through the system
+// proxy of the Internet Options, including auto-config scripts and WPAD, so
+// it also succeeds on networks that only allow outbound HTTP through a
+// mandatory proxy.
+func ProbeHTTPWithProxy(url string, opts ...HTTPProbeOption) ProbeFunc {
+	return probeHTTP204(url, systemProxy, newHTTPProbeOptions(opts))
+}
+
+// ProbeHTTPDirect is like ProbeHTTPWithProxy but always connects directly,
+// ignoring any proxy configuration.
+func ProbeHTTPDirect(url string, opts ...HTTPProbeOption) ProbeFunc {
+	return probeHTTP204(url, nil, newHTTPProbeOptions(opts))
+}
+
+// ProbeHTTPS GETs url over verified TLS and expects a 2xx response after
+// redirects. Unlike the plain HTTP probes it cannot be satisfied by a captive
+// portal or proxy that intercepts the connection. Proxies configured in the
+// environment are used for CONNECT tunnelling.
+func ProbeHTTPS(url string, opts ...HTTPProbeOption) ProbeFunc {
+	o := newHTTPProbeOptions(opts)
+	return func(ctx context.Context) error {
+		tlsConf := &tls.Config{}
+		if pin := o.PinnedCertSHA256; pin != nil {
+			tlsConf.InsecureSkipVerify = true
+			tlsConf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return errors.New("no server certificate")
+				}
+				if sum := sha256.Sum256(rawCerts[0]); subtle.ConstantTimeCompare(sum[:], pin[:]) != 1 {
+					return fmt.Errorf("certificate fingerprint %x does not match pin", sum)
+				}
+				return nil
+			}
+		}
+		tr := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConf}
+		defer tr.CloseIdleConnections()
+		cl := &http.Client{Transport: tr, Timeout: 3 * time.Second}
+		req, err := o.newRequest(ctx, url)
+		if err != nil {
+			return err
+		}
+		resp, err := cl.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+func probeHTTP204(target string, proxy func(*http.Request) (*url.URL, error), o *HTTPProbeOptions) ProbeFunc {
+	return func(ctx context.Context) error {
+		tr := &http.Transport{Proxy: proxy, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		defer tr.CloseIdleConnections()
+		cl := &http.Client{Transport: tr, Timeout: 1500 * time.Millisecond}
+		req, err := o.newRequest(ctx, target)
+		if err != nil {
+			return err
+		}
+		resp, err := cl.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+		return errors.New("non-204")
+	}
+}
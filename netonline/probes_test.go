@@ -0,0 +1,136 @@
+package netonline
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohServer starts a DoH resolver whose answer to every valid query is
+// made by answer, and points ProbeDoH's certificate check at it.
+func dohServer(t *testing.T, answer func(w http.ResponseWriter, q dnsmessage.Message)) string {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Accept") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		wire, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		var q dnsmessage.Message
+		if err == nil {
+			err = q.Unpack(wire)
+		}
+		if err != nil || len(q.Questions) != 1 || q.Questions[0].Name.String() != dohProbeName {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		answer(w, q)
+	}))
+	t.Cleanup(srv.Close)
+	old := dohRootCAs
+	dohRootCAs = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	t.Cleanup(func() { dohRootCAs = old })
+	return srv.URL + "/dns-query"
+}
+
+// dnsReply writes the response to q with rcode and the A records addrs.
+func dnsReply(t *testing.T, w http.ResponseWriter, q dnsmessage.Message, rcode dnsmessage.RCode, addrs ...[4]byte) {
+	t.Helper()
+	m := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RCode: rcode},
+		Questions: q.Questions,
+	}
+	for _, a := range addrs {
+		m.Answers = append(m.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: a},
+		})
+	}
+	b, err := m.Pack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(b)
+}
+
+func TestProbeDoH(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message)
+		wantErr string // "" for success
+	}{
+		{
+			name: "answer",
+			answer: func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message) {
+				dnsReply(t, w, q, dnsmessage.RCodeSuccess, [4]byte{93, 184, 215, 14})
+			},
+		},
+		{
+			name: "non-200",
+			answer: func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			wantErr: "doh: unexpected status 503",
+		},
+		{
+			name: "malformed answer",
+			answer: func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message) {
+				w.Header().Set("Content-Type", "application/dns-message")
+				w.Write([]byte{0x00, 0x01, 0x81})
+			},
+			wantErr: "doh: ",
+		},
+		{
+			name: "NXDOMAIN",
+			answer: func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message) {
+				dnsReply(t, w, q, dnsmessage.RCodeNameError)
+			},
+			wantErr: "doh: rcode RCodeNameError",
+		},
+		{
+			name: "no address",
+			answer: func(t *testing.T, w http.ResponseWriter, q dnsmessage.Message) {
+				dnsReply(t, w, q, dnsmessage.RCodeSuccess)
+			},
+			wantErr: "doh: no address in answer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := dohServer(t, func(w http.ResponseWriter, q dnsmessage.Message) { tt.answer(t, w, q) })
+			err := ProbeDoH(u)(context.Background())
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ProbeDoH: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Fatalf("ProbeDoH error = %v, want %q...", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestProbeDoHContext checks that the probe gives up with its context and
+// that the error wraps the context's.
+func TestProbeDoHContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	u := dohServer(t, func(w http.ResponseWriter, q dnsmessage.Message) { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := ProbeDoH(u)(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ProbeDoH error = %v, want one wrapping context.DeadlineExceeded", err)
+	}
+	if !strings.HasPrefix(err.Error(), "doh: ") {
+		t.Errorf("ProbeDoH error = %q, want a doh: prefix", err)
+	}
+}
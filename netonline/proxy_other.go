@@ -0,0 +1,14 @@
+//go:build !windows
+
+package netonline
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// systemProxy returns the proxy for req configured in the environment
+// (HTTP_PROXY, HTTPS_PROXY and NO_PROXY).
+func systemProxy(req *http.Request) (*url.URL, error) {
+	return http.ProxyFromEnvironment(req)
+}
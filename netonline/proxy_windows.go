@@ -0,0 +1,188 @@
+//go:build windows
+
+package netonline
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	winhttp                                   = windows.NewLazySystemDLL("winhttp.dll")
+	procWinHttpGetIEProxyConfigForCurrentUser = winhttp.NewProc("WinHttpGetIEProxyConfigForCurrentUser")
+	procWinHttpOpen                           = winhttp.NewProc("WinHttpOpen")
+	procWinHttpGetProxyForUrl                 = winhttp.NewProc("WinHttpGetProxyForUrl")
+	procGlobalFree                            = windows.NewLazySystemDLL("kernel32.dll").NewProc("GlobalFree")
+)
+
+const (
+	winhttpAccessTypeNoProxy    = 1
+	winhttpAccessTypeNamedProxy = 3
+
+	winhttpAutoproxyAutoDetect = 0x1
+	winhttpAutoproxyConfigURL  = 0x2
+	winhttpAutoDetectTypeDHCP  = 0x1
+	winhttpAutoDetectTypeDNSA  = 0x2
+)
+
+// WINHTTP_CURRENT_USER_IE_PROXY_CONFIG
+type winhttpIEProxyConfig struct {
+	AutoDetect    int32 // BOOL
+	AutoConfigURL *uint16
+	Proxy         *uint16
+	ProxyBypass   *uint16
+}
+
+// WINHTTP_AUTOPROXY_OPTIONS
+type winhttpAutoproxyOptions struct {
+	Flags                 uint32
+	AutoDetectFlags       uint32
+	AutoConfigURL         *uint16
+	Reserved              uintptr
+	Reserved2             uint32
+	AutoLogonIfChallenged int32 // BOOL
+}
+
+// WINHTTP_PROXY_INFO
+type winhttpProxyInfo struct {
+	AccessType  uint32
+	Proxy       *uint16
+	ProxyBypass *uint16
+}
+
+var (
+	winhttpSessionOnce sync.Once
+	winhttpSession     uintptr // for WinHttpGetProxyForUrl, 0 if unavailable
+)
+
+// globalString returns the string WinHTTP allocated at p and frees it.
+func globalString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	s := windows.UTF16PtrToString(p)
+	procGlobalFree.Call(uintptr(unsafe.Pointer(p)))
+	return s
+}
+
+// systemProxy returns the proxy for req configured in the environment
+// (HTTP_PROXY, HTTPS_PROXY and NO_PROXY) or, without one, in the current
+// user's Internet Options, which browsers and most Windows applications
+// follow. A proxy auto-config script, set there or found by WPAD, is run
+// with WinHttpGetProxyForUrl; if it cannot be, the static proxy applies.
+// Failing to read the settings means no proxy rather than a probe error.
+func systemProxy(req *http.Request) (*url.URL, error) {
+	if u, err := http.ProxyFromEnvironment(req); u != nil || err != nil {
+		return u, err
+	}
+	if procWinHttpGetIEProxyConfigForCurrentUser.Find() != nil {
+		return nil, nil
+	}
+	var ie winhttpIEProxyConfig
+	if r0, _, _ := procWinHttpGetIEProxyConfigForCurrentUser.Call(uintptr(unsafe.Pointer(&ie))); r0 == 0 {
+		return nil, nil
+	}
+	autoConfig, proxy, bypass := globalString(ie.AutoConfigURL), globalString(ie.Proxy), globalString(ie.ProxyBypass)
+	if ie.AutoDetect != 0 || autoConfig != "" {
+		if p, b, ok := winAutoProxy(req.URL.String(), ie.AutoDetect != 0, autoConfig); ok {
+			proxy, bypass = p, b
+		}
+	}
+	if proxy == "" || winProxyBypassed(req.URL.Hostname(), bypass) {
+		return nil, nil
+	}
+	return winProxyURL(proxy, req.URL.Scheme)
+}
+
+// winAutoProxy runs proxy auto-configuration for target with the script at
+// configURL, or the one WPAD finds if autoDetect is set. proxy is empty if
+// the script chose a direct connection; ok is false if no script could be
+// found or run. WPAD can take seconds and cannot be cancelled.
+func winAutoProxy(target string, autoDetect bool, configURL string) (proxy, bypass string, ok bool) {
+	winhttpSessionOnce.Do(func() {
+		if procWinHttpOpen.Find() != nil || procWinHttpGetProxyForUrl.Find() != nil {
+			return
+		}
+		agent, _ := windows.UTF16PtrFromString(DefaultProbeUserAgent)
+		winhttpSession, _, _ = procWinHttpOpen.Call(uintptr(unsafe.Pointer(agent)), winhttpAccessTypeNoProxy, 0, 0, 0)
+	})
+	if winhttpSession == 0 {
+		return "", "", false
+	}
+	u, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return "", "", false
+	}
+	opts := winhttpAutoproxyOptions{AutoLogonIfChallenged: 1}
+	if configURL != "" {
+		if opts.AutoConfigURL, err = windows.UTF16PtrFromString(configURL); err != nil {
+			return "", "", false
+		}
+		opts.Flags |= winhttpAutoproxyConfigURL
+	}
+	if autoDetect {
+		opts.Flags |= winhttpAutoproxyAutoDetect
+		opts.AutoDetectFlags = winhttpAutoDetectTypeDHCP | winhttpAutoDetectTypeDNSA
+	}
+	var info winhttpProxyInfo
+	if r0, _, _ := procWinHttpGetProxyForUrl.Call(winhttpSession, uintptr(unsafe.Pointer(u)), uintptr(unsafe.Pointer(&opts)), uintptr(unsafe.Pointer(&info))); r0 == 0 {
+		return "", "", false
+	}
+	proxy, bypass = globalString(info.Proxy), globalString(info.ProxyBypass)
+	if info.AccessType != winhttpAccessTypeNamedProxy {
+		proxy = ""
+	}
+	return proxy, bypass, true
+}
+
+// winProxyFields splits a WinINet proxy or bypass list, whose entries are
+// separated by semicolons or whitespace.
+func winProxyFields(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool { return r == ';' || unicode.IsSpace(r) })
+}
+
+// winProxyURL picks the proxy for scheme from a WinINet proxy list: either
+// "host:port" entries for every scheme or "scheme=host:port" ones. The
+// first match wins.
+func winProxyURL(list, scheme string) (*url.URL, error) {
+	for _, e := range winProxyFields(list) {
+		if s, addr, ok := strings.Cut(e, "="); ok {
+			if !strings.EqualFold(s, scheme) {
+				continue
+			}
+			e = addr
+		}
+		if !strings.Contains(e, "://") {
+			e = "http://" + e
+		}
+		return url.Parse(e)
+	}
+	return nil, nil
+}
+
+// winProxyBypassed reports whether host matches the WinINet bypass list,
+// where "<local>" stands for names without a dot and other entries may
+// contain * wildcards.
+func winProxyBypassed(host, list string) bool {
+	host = strings.ToLower(host)
+	for _, p := range winProxyFields(list) {
+		p = strings.ToLower(p)
+		if p == "<local>" {
+			if !strings.Contains(host, ".") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, host); ok {
+			return true
+		}
+	}
+	return false
+}
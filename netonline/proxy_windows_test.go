@@ -0,0 +1,51 @@
+package netonline
+
+import "testing"
+
+func TestWinProxyURL(t *testing.T) {
+	tests := []struct {
+		list, scheme, want string
+	}{
+		{"proxy.corp:8080", "http", "http://proxy.corp:8080"},
+		{"proxy.corp:8080", "https", "http://proxy.corp:8080"},
+		{"http=web.corp:80;https=tls.corp:443", "http", "http://web.corp:80"},
+		{"http=web.corp:80;https=tls.corp:443", "https", "http://tls.corp:443"},
+		{"https=tls.corp:443 socks=socks.corp:1080", "http", ""},
+		{"first.corp:3128; second.corp:3128", "http", "http://first.corp:3128"},
+		{"", "http", ""},
+	}
+	for _, tt := range tests {
+		u, err := winProxyURL(tt.list, tt.scheme)
+		if err != nil {
+			t.Errorf("winProxyURL(%q, %q): %v", tt.list, tt.scheme, err)
+			continue
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != tt.want {
+			t.Errorf("winProxyURL(%q, %q) = %q, want %q", tt.list, tt.scheme, got, tt.want)
+		}
+	}
+}
+
+func TestWinProxyBypassed(t *testing.T) {
+	const list = "<local>;*.corp.example; 10.*"
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"intranet", true},
+		{"wiki.corp.example", true},
+		{"WIKI.Corp.Example", true},
+		{"10.1.2.3", true},
+		{"corp.example", false},
+		{"connectivitycheck.gstatic.com", false},
+	}
+	for _, tt := range tests {
+		if got := winProxyBypassed(tt.host, list); got != tt.want {
+			t.Errorf("winProxyBypassed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package netonline
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventRouter delivers the events of a Watcher to channels selected by
+// matchers. Routes are evaluated in registration order; by default an event
+// goes to the first matching route only, with WithMulticast to every
+// matching route. Sends never block: an event a destination cannot take
+// right away is dropped and counted for that route.
+type EventRouter struct {
+	multicast bool
+
+	mu     sync.Mutex
+	routes []*eventRoute
+}
+
+type eventRoute struct {
+	match   func(Event) bool
+	dest    chan<- Event
+	dropped atomic.Uint64
+}
+
+// EventRouterOption configures an EventRouter.
+type EventRouterOption func(*EventRouter)
+
+// WithMulticast delivers each event to every matching route instead of the
+// first one.
+func WithMulticast() EventRouterOption {
+	return func(r *EventRouter) { r.multicast = true }
+}
+
+// MatchOnline matches state changes to online.
+func MatchOnline(ev Event) bool { return ev.Online && !ev.IsHeartbeat() }
+
+// MatchOffline matches state changes to offline.
+func MatchOffline(ev Event) bool { return !ev.Online && !ev.IsHeartbeat() }
+
+// NewEventRouter returns a router fed by every event w emits from now on,
+// heartbeats included.
+func NewEventRouter(w *Watcher, opts ...EventRouterOption) *EventRouter {
+	r := &EventRouter{}
+	for _, o := range opts {
+		o(r)
+	}
+	w.Notify(r.dispatch)
+	return r
+}
+
+// Route sends events for which matcher returns true to dest. matcher runs
+// on the watcher goroutine and must not block.
+func (r *EventRouter) Route(matcher func(Event) bool, dest chan<- Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, &eventRoute{match: matcher, dest: dest})
+}
+
+// Dropped reports how many events were dropped because dest was full,
+// summed over all routes to dest.
+func (r *EventRouter) Dropped(dest chan<- Event) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n uint64
+	for _, rt := range r.routes {
+		if rt.dest == dest {
+			n += rt.dropped.Load()
+		}
+	}
+	return n
+}
+
+func (r *EventRouter) dispatch(ev Event) {
+	r.mu.Lock()
+	routes := r.routes
+	r.mu.Unlock()
+	for _, rt := range routes {
+		if !rt.match(ev) {
+			continue
+		}
+		select {
+		case rt.dest <- ev:
+		default:
+			rt.dropped.Add(1)
+		}
+		if !r.multicast {
+			return
+		}
+	}
+}
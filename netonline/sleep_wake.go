@@ -6,12 +6,63 @@ import (
 	"time"
 )
 
-// StartWakeGapWatcher emits a signal after resume from sleep/hibernate.
-// It checks for a large jump in the monotonic clock, which is cross-platform.
-func StartWakeGapWatcher(ctx context.Context, sample, gapThreshold time.Duration) <-chan struct{} {
+// SleepEvent is a system power transition reported by StartSleepWatcher.
+type SleepEvent struct {
+	Suspending bool      // true: about to sleep; false: just woke
+	At         time.Time // when the notification arrived
+}
+
+// StartSleepWatcher reports the OS's own notifications before sleep and
+// after resume: systemd-logind's PrepareForSleep over D-Bus on Linux,
+// WM_POWERBROADCAST on Windows and IOKit's power notifications on macOS
+// (with cgo). The Linux D-Bus path is optional and needs the netonline_dbus
+// build tag. A Suspending event gives applications a moment to flush state
+// or close connections, but the OS does not wait for them. It fails if the
+// notifications are unavailable, such as on Linux without logind, with an
+// error wrapping errors.ErrUnsupported where they are not built in. The
+// channel is closed when ctx is done.
+func StartSleepWatcher(ctx context.Context) (<-chan SleepEvent, error) {
+	return startNativePowerWatcher(ctx)
+}
+
+// startNativeWakeWatcher is StartSleepWatcher reduced to wakes.
+func startNativeWakeWatcher(ctx context.Context) (<-chan struct{}, error) {
+	in, err := startNativePowerWatcher(ctx)
+	if err != nil { return nil, err }
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		for ev := range in {
+			if ev.Suspending { continue }
+			select { case out <- struct{}{}: default: }
+		}
+	}()
+	return out, nil
+}
+
+// StartWakeWatcher emits a signal after resume from sleep/hibernate. Where
+// the OS notifies wakes directly (see StartSleepWatcher) the signal comes
+// right away; elsewhere, or if subscribing fails, it falls back to
+// StartWakeEventWatcher with sample and gapThreshold.
+func StartWakeWatcher(ctx context.Context, sample, gapThreshold time.Duration) <-chan struct{} {
+	if out, err := startNativeWakeWatcher(ctx); err == nil { return out }
+	return wakeSignals(StartWakeEventWatcher(ctx, sample, gapThreshold))
+}
+
+// WakeEvent describes a resume from sleep/hibernate.
+type WakeEvent struct {
+	SleepDuration time.Duration // estimated time asleep
+	WokeAt        time.Time     // when the wake was noticed
+}
+
+// StartWakeEventWatcher emits a WakeEvent after resume from sleep/hibernate.
+// It checks for a large jump in the monotonic clock, which is cross-platform
+// but only notices a wake up to sample after it happened. SleepDuration is
+// the observed gap minus one sample period.
+func StartWakeEventWatcher(ctx context.Context, sample, gapThreshold time.Duration) <-chan WakeEvent {
 	if sample <= 0 { sample = time.Second }
 	if gapThreshold <= 0 { gapThreshold = 1500 * time.Millisecond }
-	out := make(chan struct{}, 1)
+	out := make(chan WakeEvent, 1)
 	t := time.NewTicker(sample)
 	last := time.Now()
 	go func() {
@@ -22,10 +73,31 @@ func StartWakeGapWatcher(ctx context.Context, sample, gapThreshold time.Duration
 			case now := <-t.C:
 				d := now.Sub(last); last = now
 				if d >= sample + gapThreshold {
-					select { case out <- struct{}{}: default: }
+					select { case out <- WakeEvent{SleepDuration: d - sample, WokeAt: now}: default: }
 				}
 			}
 		}
 	}()
 	return out
 }
+
+// StartWakeGapWatcher emits a signal after resume from sleep/hibernate. It
+// tries the OS's native wake notifications first, such as IOKit's on macOS,
+// and falls back to the clock gap check with sample and gapThreshold if
+// they are unavailable; it is StartWakeWatcher under its original name.
+// StartWakeEventWatcher always uses the gap check, on every platform.
+//
+// Deprecated: Use StartWakeWatcher, or StartWakeEventWatcher, which also
+// reports how long the system slept.
+func StartWakeGapWatcher(ctx context.Context, sample, gapThreshold time.Duration) <-chan struct{} {
+	return StartWakeWatcher(ctx, sample, gapThreshold)
+}
+
+func wakeSignals(in <-chan WakeEvent) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	go func() {
+		defer close(out)
+		for range in { select { case out <- struct{}{}: default: } }
+	}()
+	return out
+}
@@ -0,0 +1,141 @@
+// Package sqlitelog persists netonline events to a SQLite database for
+// auditing. It uses the pure-Go modernc.org/sqlite driver, so no CGo is
+// required.
+package sqlitelog
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/netonline/netonline"
+
+	_ "modernc.org/sqlite"
+)
+
+const createTable = `CREATE TABLE IF NOT EXISTS network_events (
+	id INTEGER PRIMARY KEY,
+	ts TEXT NOT NULL,
+	online INTEGER NOT NULL,
+	cause TEXT,
+	interface TEXT,
+	interface_type TEXT,
+	duration_ms INTEGER
+)`
+
+// tsLayout is fixed width so that timestamps compare correctly as text.
+const tsLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// SQLiteLogger writes every event of the watchers it is subscribed to into
+// the network_events table. Inserts happen on a dedicated goroutine; events
+// arriving while its queue is full are dropped and counted.
+type SQLiteLogger struct {
+	db      *sql.DB
+	queue   chan netonline.Event
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// NewSQLiteLogger opens (creating if needed) the database at dsn and starts
+// the writer goroutine.
+func NewSQLiteLogger(dsn string) (*SQLiteLogger, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, err
+	}
+	l := &SQLiteLogger{
+		db:    db,
+		queue: make(chan netonline.Event, 64),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.write()
+	return l, nil
+}
+
+// Subscribe logs all events emitted by w from now on. The returned function
+// stops logging them.
+func (l *SQLiteLogger) Subscribe(w *netonline.Watcher) (cancel func()) {
+	return w.Notify(func(ev netonline.Event) {
+		select {
+		case l.queue <- ev:
+		case <-l.quit:
+		default:
+			l.dropped.Add(1)
+		}
+	})
+}
+
+// Dropped reports how many events were not logged because the queue was full.
+func (l *SQLiteLogger) Dropped() uint64 { return l.dropped.Load() }
+
+// Close writes out queued events and closes the database.
+func (l *SQLiteLogger) Close() error {
+	l.once.Do(func() { close(l.quit) })
+	<-l.done
+	return l.db.Close()
+}
+
+// QueryHistory returns the logged events with from <= ChangedAt < to, oldest
+// first.
+func (l *SQLiteLogger) QueryHistory(from, to time.Time) ([]netonline.Event, error) {
+	rows, err := l.db.Query(`SELECT ts, online, cause, interface FROM network_events
+		WHERE ts >= ? AND ts < ? ORDER BY ts, id`,
+		from.UTC().Format(tsLayout), to.UTC().Format(tsLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []netonline.Event
+	for rows.Next() {
+		var ts string
+		var online bool
+		var cause, iface sql.NullString
+		if err := rows.Scan(&ts, &online, &cause, &iface); err != nil {
+			return nil, err
+		}
+		at, err := time.Parse(tsLayout, ts)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, netonline.Event{Online: online, ChangedAt: at, CauseDetail: cause.String, Interface: iface.String})
+	}
+	return out, rows.Err()
+}
+
+func (l *SQLiteLogger) write() {
+	defer close(l.done)
+	var last time.Time
+	insert := func(ev netonline.Event) {
+		var durationMS int64
+		if !last.IsZero() {
+			durationMS = ev.ChangedAt.Sub(last).Milliseconds()
+		}
+		last = ev.ChangedAt
+		_, _ = l.db.Exec(`INSERT INTO network_events (ts, online, cause, interface, interface_type, duration_ms)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			ev.ChangedAt.UTC().Format(tsLayout), ev.Online, ev.CauseDetail, ev.Interface, "", durationMS)
+	}
+	for {
+		select {
+		case ev := <-l.queue:
+			insert(ev)
+		case <-l.quit:
+			for {
+				select {
+				case ev := <-l.queue:
+					insert(ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
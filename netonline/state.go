@@ -0,0 +1,94 @@
+package netonline
+
+import "time"
+
+// transitionHistory is the number of transitions a Watcher remembers.
+const transitionHistory = 32
+
+// State is the state of a Watcher's state machine. A watcher starts in
+// StateUnknown and moves to StateOnline or StateOffline with every reported
+// event. With a ConnectivityChecker attached (see WithConnectivityChecker and
+// WithActiveValidation) it passes through StateValidating while the checker
+// runs and then moves to the state the result leads to.
+type State int
+
+const (
+	StateUnknown    State = iota // nothing evaluated yet
+	StateOffline                 // last reported offline
+	StateOnline                  // last reported online
+	StateValidating              // active validation in progress
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOffline:
+		return "offline"
+	case StateOnline:
+		return "online"
+	case StateValidating:
+		return "validating"
+	}
+	return "unknown"
+}
+
+// Transition is one change of a Watcher's State.
+type Transition struct {
+	From, To State
+	At       time.Time
+}
+
+// State returns the current state of the watcher.
+func (w *Watcher) State() State { return State(w.st.Load()) }
+
+// Transitions returns the most recent state changes, oldest first. Up to 32
+// are kept.
+func (w *Watcher) Transitions() []Transition {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := min(w.historyN, transitionHistory)
+	out := make([]Transition, 0, n)
+	for i := w.historyN - n; i < w.historyN; i++ {
+		out = append(out, w.history[i%transitionHistory])
+	}
+	return out
+}
+
+func (w *Watcher) setState(s State) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.setStateLocked(s)
+}
+
+func (w *Watcher) setStateLocked(s State) {
+	from := State(w.st.Swap(int32(s)))
+	if from == s {
+		return
+	}
+	w.history[w.historyN%transitionHistory] = Transition{From: from, To: s, At: time.Now()}
+	w.historyN++
+}
+
+// reportedState is the State matching the last reported event.
+func (w *Watcher) reportedState() State {
+	switch {
+	case !w.life.known:
+		return StateUnknown
+	case w.life.online:
+		return StateOnline
+	}
+	return StateOffline
+}
+
+// evaluate runs evaluateWith for the watcher, passing through
+// StateValidating while the active checker runs. Afterwards the state goes
+// back to the last reported one until observe records a new event.
+func (w *Watcher) evaluate() (netState, *CheckResult, error) {
+	st, res, err := evaluateWith(w.ctx, w.cfg, &w.cache, func() { w.setState(StateValidating) })
+	if res != nil {
+		w.logCheck(res)
+		w.mu.Lock()
+		w.setStateLocked(w.reportedState())
+		w.mu.Unlock()
+	}
+	return st, res, err
+}
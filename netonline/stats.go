@@ -0,0 +1,79 @@
+package netonline
+
+import "time"
+
+// LifetimeStats summarizes the states a Watcher reported over its lifetime.
+type LifetimeStats struct {
+	StartedAt            time.Time
+	StoppedAt            time.Time // zero while the watcher is running
+	TotalOnlineDuration  time.Duration
+	TotalOfflineDuration time.Duration
+	MaxOnlineDuration    time.Duration
+	MaxOfflineDuration   time.Duration
+	TransitionCount      uint64
+	DroppedEvents        uint64 // see Watcher.DroppedEvents
+}
+
+type lifetime struct {
+	stats  LifetimeStats
+	known  bool // a state has been reported
+	online bool
+	since  time.Time
+}
+
+// observe records the reported state at time at.
+func (l *lifetime) observe(online bool, at time.Time) {
+	if l.known {
+		if online == l.online {
+			return
+		}
+		l.close(at)
+		l.stats.TransitionCount++
+	}
+	l.known, l.online, l.since = true, online, at
+}
+
+// close accounts for the current state segment up to at.
+func (l *lifetime) close(at time.Time) {
+	if !l.known {
+		return
+	}
+	d := at.Sub(l.since)
+	if l.online {
+		l.stats.TotalOnlineDuration += d
+		if d > l.stats.MaxOnlineDuration {
+			l.stats.MaxOnlineDuration = d
+		}
+	} else {
+		l.stats.TotalOfflineDuration += d
+		if d > l.stats.MaxOfflineDuration {
+			l.stats.MaxOfflineDuration = d
+		}
+	}
+}
+
+// LifetimeStats returns durations and transition counts of the states the
+// watcher reported. It is meant to be read after Stop but may be called at
+// any time; while running, the current state counts up to now.
+func (w *Watcher) LifetimeStats() LifetimeStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	l := w.life
+	if l.stats.StoppedAt.IsZero() {
+		l.close(time.Now())
+	}
+	l.stats.DroppedEvents = w.dropped.Load()
+	return l.stats
+}
+
+func (w *Watcher) observe(ev Event) {
+	w.mu.Lock()
+	if !w.life.known || w.life.online != ev.Online {
+		close(w.changed)
+		w.changed = make(chan struct{})
+	}
+	w.life.observe(ev.Online, ev.ChangedAt)
+	w.iface = ev.Interface
+	w.setStateLocked(w.reportedState())
+	w.mu.Unlock()
+}
@@ -0,0 +1,131 @@
+package netonline
+
+import (
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StatsdPublisher sends events and probe results to a statsd agent over
+// UDP. Per event it sends
+//
+//	prefix.online:1|g (or 0)
+//	prefix.transitions:1|c
+//
+// and per ConnectivityChecker.Check, for every probe that finished,
+//
+//	prefix.probe.<name>.latency_ms:<n>|ms
+//	prefix.probe.<name>.success:1|c (or failure)
+//
+// Characters statsd reserves in probe names are replaced by underscores.
+// Datagrams are written synchronously; UDP sends do not wait for the agent.
+type StatsdPublisher struct {
+	prefix     string
+	sampleRate float64
+
+	mu   sync.Mutex
+	conn net.Conn
+	err  error
+}
+
+// StatsdOption configures a StatsdPublisher.
+type StatsdOption func(*StatsdPublisher)
+
+// WithSampleRate sends counters and timings with probability r and tags
+// them with |@r so the agent scales them back up. Gauges are always sent.
+func WithSampleRate(r float64) StatsdOption {
+	return func(p *StatsdPublisher) { p.sampleRate = r }
+}
+
+// NewStatsdPublisher returns a publisher sending to the statsd agent at addr
+// (host:port). A failure to set up the socket is returned by Close; until
+// then nothing is sent.
+func NewStatsdPublisher(addr string, prefix string, opts ...StatsdOption) *StatsdPublisher {
+	p := &StatsdPublisher{prefix: strings.TrimSuffix(prefix, "."), sampleRate: 1}
+	for _, o := range opts {
+		o(p)
+	}
+	p.conn, p.err = net.Dial("udp", addr)
+	return p
+}
+
+// Subscribe publishes the state changes of w from now on; heartbeats are
+// ignored. The returned function stops publishing them.
+func (p *StatsdPublisher) Subscribe(w *Watcher) (cancel func()) {
+	return w.Notify(func(ev Event) {
+		if ev.IsHeartbeat() {
+			return
+		}
+		online := "0"
+		if ev.Online {
+			online = "1"
+		}
+		p.send(p.metric("online", online, "g", false), p.metric("transitions", "1", "c", true))
+	})
+}
+
+// SubscribeChecker publishes the probe results of every Check of c. Like
+// ConnectivityChecker.OnCheck, it must not be called concurrently with Check.
+func (p *StatsdPublisher) SubscribeChecker(c *ConnectivityChecker) {
+	c.OnCheck(func(res *CheckResult) {
+		var lines []string
+		for _, r := range res.Probes {
+			name := "probe." + statsdName(r.Name)
+			outcome := "success"
+			if r.Err != nil {
+				outcome = "failure"
+			}
+			lines = append(lines,
+				p.metric(name+".latency_ms", strconv.FormatInt(r.Latency.Milliseconds(), 10), "ms", true),
+				p.metric(name+"."+outcome, "1", "c", true))
+		}
+		p.send(lines...)
+	})
+}
+
+// Close closes the socket and returns the setup error or the last send
+// error, if any.
+func (p *StatsdPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return p.err
+}
+
+// metric formats one statsd line, or returns "" if sampling drops it.
+func (p *StatsdPublisher) metric(name, value, typ string, sampled bool) string {
+	line := p.prefix + "." + name + ":" + value + "|" + typ
+	if sampled && p.sampleRate > 0 && p.sampleRate < 1 {
+		if rand.Float64() >= p.sampleRate {
+			return ""
+		}
+		line += "|@" + strconv.FormatFloat(p.sampleRate, 'g', -1, 64)
+	}
+	return line
+}
+
+// send writes each non-empty line as its own datagram.
+func (p *StatsdPublisher) send(lines ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return
+	}
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+		if _, err := p.conn.Write([]byte(l)); err != nil {
+			p.err = err
+		}
+	}
+}
+
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", ".", "_", " ", "_", "/", "_")
+
+func statsdName(s string) string { return statsdReplacer.Replace(s) }
@@ -0,0 +1,147 @@
+package netonline
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statusPageHistory is the number of recent events a status page shows.
+const statusPageHistory = 10
+
+// StatusPageOption configures the handler returned by NewStatusPageHandler.
+type StatusPageOption func(*statusPage)
+
+// WithRefreshInterval makes browsers reload the status page every d.
+func WithRefreshInterval(d time.Duration) StatusPageOption {
+	return func(p *statusPage) { p.refresh = d }
+}
+
+type statusPage struct {
+	w       *Watcher
+	refresh time.Duration
+
+	mu     sync.Mutex
+	events []Event // oldest first, at most statusPageHistory
+	probes map[string]*probeTally
+}
+
+type probeTally struct{ ok, total int }
+
+// NewStatusPageHandler returns a handler serving a self-contained HTML page
+// with the current state of w, its last 10 events and, if a
+// ConnectivityChecker is attached, the success rate of every probe. Events
+// and probe outcomes are collected from the moment the handler is created.
+func NewStatusPageHandler(w *Watcher, opts ...StatusPageOption) http.Handler {
+	p := &statusPage{w: w, probes: make(map[string]*probeTally)}
+	for _, o := range opts {
+		o(p)
+	}
+	w.Notify(p.record)
+	return p
+}
+
+func (p *statusPage) record(ev Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !ev.IsHeartbeat() {
+		p.events = append(p.events, ev)
+		if len(p.events) > statusPageHistory {
+			p.events = p.events[len(p.events)-statusPageHistory:]
+		}
+	}
+	if ev.CheckResult != nil {
+		for _, r := range ev.CheckResult.Probes {
+			t := p.probes[r.Name]
+			if t == nil {
+				t = &probeTally{}
+				p.probes[r.Name] = t
+			}
+			t.total++
+			if r.Err == nil {
+				t.ok++
+			}
+		}
+	}
+}
+
+type statusPageData struct {
+	Refresh string
+	State   string
+	Online  bool
+	Events  []Event
+	Checker bool
+	Probes  []statusPageProbe
+}
+
+type statusPageProbe struct {
+	Name        string
+	SuccessRate string
+	Samples     int
+}
+
+func (p *statusPage) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	online, known, _ := p.w.state()
+	d := statusPageData{State: "unknown", Online: online, Checker: p.w.cfg.checker != nil}
+	if known {
+		d.State = "offline"
+		if online {
+			d.State = "online"
+		}
+	}
+	if p.refresh > 0 {
+		d.Refresh = strconv.Itoa(int((p.refresh + time.Second - 1) / time.Second))
+	}
+	p.mu.Lock()
+	for i := len(p.events) - 1; i >= 0; i-- {
+		d.Events = append(d.Events, p.events[i])
+	}
+	for name, t := range p.probes {
+		d.Probes = append(d.Probes, statusPageProbe{
+			Name:        name,
+			SuccessRate: strconv.FormatFloat(100*float64(t.ok)/float64(t.total), 'f', 1, 64) + "%",
+			Samples:     t.total,
+		})
+	}
+	p.mu.Unlock()
+	sort.Slice(d.Probes, func(i, j int) bool { return d.Probes[i].Name < d.Probes[j].Name })
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	_ = statusPageTemplate.Execute(rw, d)
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if .Refresh}}<meta http-equiv="refresh" content="{{.Refresh}}">
+{{end}}<title>Network status: {{.State}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.online { color: #080; }
+.offline { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Network is <span class="{{.State}}">{{.State}}</span></h1>
+<h2>Recent events</h2>
+{{if .Events}}<table>
+<tr><th>Time</th><th>State</th><th>Cause</th><th>Interface</th><th>Address</th></tr>
+{{range .Events}}<tr><td>{{.ChangedAt.Format "2006-01-02 15:04:05"}}</td><td class="{{if .Online}}online">online{{else}}offline">offline{{end}}</td><td>{{.CauseDetail}}</td><td>{{.Interface}}</td><td>{{.Addr}}</td></tr>
+{{end}}</table>
+{{else}}<p>No events yet.</p>
+{{end}}{{if .Checker}}<h2>Probes</h2>
+{{if .Probes}}<table>
+<tr><th>Probe</th><th>Success rate</th><th>Samples</th></tr>
+{{range .Probes}}<tr><td>{{.Name}}</td><td>{{.SuccessRate}}</td><td>{{.Samples}}</td></tr>
+{{end}}</table>
+{{else}}<p>No probe results yet.</p>
+{{end}}{{end}}</body>
+</html>
+`))
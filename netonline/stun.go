@@ -0,0 +1,177 @@
+package netonline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Minimal RFC 5389 framing, enough for binding requests and responses.
+const (
+	stunHeaderLen      = 20
+	stunMagicCookie    = 0x2112A442
+	stunBindingRequest = 0x0001
+	stunBindingSuccess = 0x0101
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrChangeRequest    = 0x0003 // RFC 5780, from RFC 3489
+	stunAttrChangedAddress   = 0x0005 // RFC 3489
+	stunAttrXorMappedAddress = 0x0020
+	stunAttrOtherAddress     = 0x802c // RFC 5780
+
+	stunChangeIP   = 0x4
+	stunChangePort = 0x2
+
+	// defaultSTUNServer answers binding requests and is used as an echo
+	// service by the UDP probes.
+	defaultSTUNServer = "stun.l.google.com:19302"
+)
+
+type stunTxID [12]byte
+
+func newSTUNTxID() stunTxID {
+	var id stunTxID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// stunRequest encodes a binding request without attributes.
+func stunRequest(id stunTxID) []byte {
+	b := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(b[4:8], stunMagicCookie)
+	copy(b[8:20], id[:])
+	return b
+}
+
+// stunChangeRequest encodes a binding request asking the server to answer
+// from its alternate IP address and/or port (stunChangeIP, stunChangePort).
+func stunChangeRequest(id stunTxID, flags uint32) []byte {
+	b := append(stunRequest(id), 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(b[2:4], 8)
+	binary.BigEndian.PutUint16(b[stunHeaderLen:], stunAttrChangeRequest)
+	binary.BigEndian.PutUint16(b[stunHeaderLen+2:], 4)
+	binary.BigEndian.PutUint32(b[stunHeaderLen+4:], flags)
+	return b
+}
+
+// parseSTUNResponse returns the transaction ID and attributes of a binding
+// success response, or ok=false for anything else.
+func parseSTUNResponse(b []byte) (id stunTxID, attrs []byte, ok bool) {
+	if len(b) < stunHeaderLen || binary.BigEndian.Uint16(b[0:2]) != stunBindingSuccess ||
+		binary.BigEndian.Uint32(b[4:8]) != stunMagicCookie {
+		return id, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if stunHeaderLen+n > len(b) {
+		return id, nil, false
+	}
+	copy(id[:], b[8:20])
+	return id, b[stunHeaderLen : stunHeaderLen+n], true
+}
+
+// stunMappedIP returns the reflexive address from the attributes of a
+// binding response, preferring XOR-MAPPED-ADDRESS over MAPPED-ADDRESS.
+func stunMappedIP(id stunTxID, attrs []byte) net.IP {
+	if a := stunMappedAddr(id, attrs); a != nil {
+		return a.IP
+	}
+	return nil
+}
+
+// stunMappedAddr is stunMappedIP with the port.
+func stunMappedAddr(id stunTxID, attrs []byte) *net.UDPAddr {
+	var mapped *net.UDPAddr
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:2])
+		n := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+n > len(attrs) {
+			break
+		}
+		v := attrs[4 : 4+n]
+		if typ == stunAttrXorMappedAddress || typ == stunAttrMappedAddress {
+			if a := stunAddrValue(v); a != nil && typ == stunAttrXorMappedAddress {
+				var key [16]byte
+				binary.BigEndian.PutUint32(key[0:4], stunMagicCookie)
+				copy(key[4:], id[:])
+				for i := range a.IP {
+					a.IP[i] ^= key[i]
+				}
+				a.Port ^= stunMagicCookie >> 16
+				return a
+			} else if a != nil {
+				mapped = a
+			}
+		}
+		attrs = attrs[4+(n+3)&^3:]
+	}
+	return mapped
+}
+
+// stunOtherAddr returns the server's alternate address from OTHER-ADDRESS
+// (RFC 5780) or CHANGED-ADDRESS (RFC 3489), or nil if it has none.
+func stunOtherAddr(attrs []byte) *net.UDPAddr {
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:2])
+		n := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+n > len(attrs) {
+			break
+		}
+		if typ == stunAttrOtherAddress || typ == stunAttrChangedAddress {
+			return stunAddrValue(attrs[4 : 4+n])
+		}
+		attrs = attrs[4+(n+3)&^3:]
+	}
+	return nil
+}
+
+// stunAddrValue decodes the value of an address attribute, without XOR.
+func stunAddrValue(v []byte) *net.UDPAddr {
+	if len(v) < 8 {
+		return nil
+	}
+	port := int(binary.BigEndian.Uint16(v[2:4]))
+	switch v[1] {
+	case 0x01:
+		return &net.UDPAddr{IP: append(net.IP(nil), v[4:8]...), Port: port}
+	case 0x02:
+		if len(v) >= 20 {
+			return &net.UDPAddr{IP: append(net.IP(nil), v[4:20]...), Port: port}
+		}
+	}
+	return nil
+}
+
+// stunPublicIP asks server for the public address of this host.
+func stunPublicIP(ctx context.Context, server string) (net.IP, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+	id := newSTUNTxID()
+	if _, err := c.Write(stunRequest(id)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, err := c.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if rid, attrs, ok := parseSTUNResponse(buf[:n]); ok && rid == id {
+			if ip := stunMappedIP(id, attrs); ip != nil {
+				return ip, nil
+			}
+			return nil, errors.New("stun: response without mapped address")
+		}
+	}
+}
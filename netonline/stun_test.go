@@ -0,0 +1,99 @@
+package netonline_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+)
+
+// stunResponder answers every binding request on a loopback UDP socket
+// with a canned Binding Success that maps the client to mapped, in an
+// XOR-MAPPED-ADDRESS attribute, or a plain MAPPED-ADDRESS if xor is false.
+// It returns the responder's address.
+func stunResponder(t *testing.T, mapped *net.UDPAddr, xor bool) string {
+	t.Helper()
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := pc.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n < 20 || binary.BigEndian.Uint16(buf[0:2]) != 0x0001 {
+				continue
+			}
+			const cookie = 0x2112A442
+			attr := make([]byte, 12)
+			binary.BigEndian.PutUint16(attr[0:2], 0x0001) // MAPPED-ADDRESS
+			binary.BigEndian.PutUint16(attr[2:4], 8)
+			attr[5] = 0x01 // IPv4
+			binary.BigEndian.PutUint16(attr[6:8], uint16(mapped.Port))
+			copy(attr[8:12], mapped.IP.To4())
+			if xor {
+				binary.BigEndian.PutUint16(attr[0:2], 0x0020) // XOR-MAPPED-ADDRESS
+				binary.BigEndian.PutUint16(attr[6:8], uint16(mapped.Port)^cookie>>16)
+				binary.BigEndian.PutUint32(attr[8:12], binary.BigEndian.Uint32(mapped.IP.To4())^cookie)
+			}
+			resp := make([]byte, 20, 20+len(attr))
+			binary.BigEndian.PutUint16(resp[0:2], 0x0101) // Binding Success
+			binary.BigEndian.PutUint16(resp[2:4], uint16(len(attr)))
+			copy(resp[4:20], buf[4:20]) // magic cookie and transaction ID
+			pc.WriteToUDP(append(resp, attr...), from)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestSTUNProbe(t *testing.T) {
+	mapped := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 40000}
+	for _, xor := range []bool{true, false} {
+		name := "XOR-MAPPED-ADDRESS"
+		if !xor {
+			name = "MAPPED-ADDRESS"
+		}
+		t.Run(name, func(t *testing.T) {
+			server := stunResponder(t, mapped, xor)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := netonline.STUNProbe(ctx, server)
+			if err != nil {
+				t.Fatalf("STUNProbe: %v", err)
+			}
+			if res.ExternalAddr != mapped.String() {
+				t.Errorf("ExternalAddr = %q, want %q", res.ExternalAddr, mapped)
+			}
+			// The responder reports no OTHER-ADDRESS, so the NAT tests
+			// cannot run.
+			if res.NATType != netonline.NATUnknown {
+				t.Errorf("NATType = %v, want %v", res.NATType, netonline.NATUnknown)
+			}
+			if err := netonline.STUNCheckProbe(server).Check(ctx); err != nil {
+				t.Errorf("STUNCheckProbe: %v", err)
+			}
+		})
+	}
+}
+
+// TestSTUNProbeNoResponse checks that a server that never answers fails
+// the probe within its context.
+func TestSTUNProbeNoResponse(t *testing.T) {
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := netonline.STUNProbe(ctx, pc.LocalAddr().String()); err == nil {
+		t.Fatal("STUNProbe succeeded without a server response")
+	}
+}
@@ -0,0 +1,151 @@
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// superviseReconnectAttempts is the WithMaxReconnectAttempts default of
+	// supervised watchers, so that a dead OS event stream surfaces as
+	// ErrEventStreamLost instead of being retried forever.
+	superviseReconnectAttempts = 5
+	// superviseWindow is the period over which restarts count as
+	// consecutive failures.
+	superviseWindow = 5 * time.Minute
+)
+
+// SupervisedWatch is NewWatcher for environments where the OS event stream
+// can fail for good, for example after a kernel module reload or when the
+// network namespace is recreated. When the underlying watcher reports
+// ErrEventStreamLost, it is stopped and, after a backoff of 1s doubling up to
+// 60s, replaced by a new one. The first event of every replacement is
+// re-emitted with a Cause starting with "restart: ".
+//
+// The returned Watcher stays the same across restarts; its Errors channel,
+// which is also returned, carries the errors of the underlying watchers. Once
+// more than maxRestarts restarts happen within 5 minutes the supervisor gives
+// up: it sends a final error and stops the returned Watcher. Unless opts
+// include WithMaxReconnectAttempts, an underlying watcher gives up on its
+// stream after 5 reconnect attempts.
+func SupervisedWatch(ctx context.Context, maxRestarts int, opts ...Option) (*Watcher, <-chan error) {
+	opts = append([]Option{WithMaxReconnectAttempts(superviseReconnectAttempts)}, opts...)
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{
+		ctx:    ctx,
+		cancel: cancel,
+		cfg:    newConfig(opts),
+		out:    make(chan Event, 1),
+		errc:   make(chan error, 1),
+		done:   make(chan struct{}),
+
+		changed: make(chan struct{}),
+	}
+	// The underlying watchers log for themselves.
+	w.cfg.logger = nil
+	w.start.Do(func() {})
+	w.mu.Lock()
+	w.life.stats.StartedAt = time.Now()
+	w.mu.Unlock()
+	go w.supervise(maxRestarts, opts)
+	return w, w.errc
+}
+
+func (w *Watcher) supervise(maxRestarts int, opts []Option) {
+	defer close(w.done)
+	defer close(w.out)
+	defer close(w.errc)
+	defer func() {
+		w.mu.Lock()
+		now := time.Now()
+		w.life.close(now)
+		w.life.stats.StoppedAt = now
+		w.mu.Unlock()
+	}()
+	defer w.cancel()
+
+	backoff := minReconnectBackoff
+	var restarts []time.Time
+	restarted := false
+	for {
+		inner := NewWatcher(w.ctx, opts...)
+		lost := w.forward(inner, restarted)
+		inner.Stop()
+		if !lost {
+			return
+		}
+
+		now := time.Now()
+		recent := restarts[:0]
+		for _, t := range restarts {
+			if now.Sub(t) < superviseWindow {
+				recent = append(recent, t)
+			}
+		}
+		restarts = append(recent, now)
+		if len(restarts) > maxRestarts {
+			select {
+			case w.errc <- &WatchError{Err: fmt.Errorf("netonline: supervisor giving up after %d restarts within %v", len(restarts)-1, superviseWindow), Kind: ErrKindInternal}:
+			case <-w.ctx.Done():
+			}
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+		restarted = true
+	}
+}
+
+// forward relays the events and errors of inner until its stream is lost
+// (lost=true) or the supervisor is stopped.
+func (w *Watcher) forward(inner *Watcher, restarted bool) (lost bool) {
+	events, errs := inner.Events(), inner.Errors()
+	first := restarted
+	for events != nil || errs != nil {
+		select {
+		case <-w.ctx.Done():
+			return false
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if first {
+				first = false
+				ev.Cause = CauseRestart
+				ev.CauseDetail = "restart: " + strings.TrimPrefix(ev.CauseDetail, "initial: ")
+			}
+			w.observe(ev)
+			w.notify(ev)
+			select {
+			case w.out <- ev:
+			case <-w.ctx.Done():
+				return false
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			select {
+			case w.errc <- err:
+			case <-w.ctx.Done():
+				return false
+			}
+			if errors.Is(err, ErrEventStreamLost) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+//go:build windows
+// +build windows
+
+package netonline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	combase                    = windows.NewLazySystemDLL("combase.dll")
+	procRoInitialize           = combase.NewProc("RoInitialize")
+	procRoUninitialize         = combase.NewProc("RoUninitialize")
+	procRoActivateInstance     = combase.NewProc("RoActivateInstance")
+	procRoGetActivationFactory = combase.NewProc("RoGetActivationFactory")
+	procWindowsCreateString    = combase.NewProc("WindowsCreateString")
+	procWindowsDeleteString    = combase.NewProc("WindowsDeleteString")
+)
+
+var (
+	iidXmlDocument                     = windows.GUID{Data1: 0xf7f3a506, Data2: 0x1e87, Data3: 0x42d6, Data4: [8]byte{0xbc, 0xfb, 0xb8, 0xc8, 0x09, 0xfa, 0x54, 0x94}}
+	iidXmlDocumentIO                   = windows.GUID{Data1: 0x6cd0e74e, Data2: 0xee65, Data3: 0x4489, Data4: [8]byte{0x9e, 0xbf, 0xca, 0x43, 0xe8, 0x7b, 0xa6, 0x37}}
+	iidToastNotificationManagerStatics = windows.GUID{Data1: 0x50ac103f, Data2: 0xd235, Data3: 0x4598, Data4: [8]byte{0xbb, 0xef, 0x98, 0xfe, 0x4d, 0x1a, 0x3a, 0xd4}}
+	iidToastNotificationFactory        = windows.GUID{Data1: 0x04124b20, Data2: 0x82c6, Data3: 0x4229, Data4: [8]byte{0xb1, 0x09, 0xfd, 0x9e, 0xd4, 0x66, 0x2b, 0x53}}
+)
+
+// Vtable slots. Every WinRT interface starts with the 3 IUnknown and the 3
+// IInspectable methods.
+const (
+	vtQueryInterface = 0
+	vtRelease        = 2
+
+	vtXmlDocumentIOLoadXml             = 6
+	vtManagerCreateToastNotifierWithId = 7
+	vtFactoryCreateToastNotification   = 6
+	vtToastNotifierShow                = 6
+)
+
+// ToastNotifier shows a Windows toast notification whenever a subscribed
+// Watcher reports a state change. It requires Windows 8 or later and an
+// AppUserModelID registered for the application (for example through a
+// Start menu shortcut); Windows silently drops toasts for unknown IDs.
+type ToastNotifier struct {
+	appID string
+
+	queue chan Event
+	quit  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+// NewToastNotifier starts a notifier that shows toasts as appID.
+func NewToastNotifier(appID string) *ToastNotifier {
+	t := &ToastNotifier{
+		appID: appID,
+		queue: make(chan Event, 8),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Subscribe shows a toast for every state change w reports from now on;
+// heartbeats are ignored. The returned function stops the notifications.
+func (t *ToastNotifier) Subscribe(w *Watcher) (cancel func()) {
+	return w.Notify(func(ev Event) {
+		if ev.IsHeartbeat() {
+			return
+		}
+		select {
+		case t.queue <- ev:
+		default:
+		}
+	})
+}
+
+// Close stops the notifier and returns the error that disabled it, if any.
+func (t *ToastNotifier) Close() error {
+	t.once.Do(func() { close(t.quit) })
+	<-t.done
+	return t.err
+}
+
+func (t *ToastNotifier) run() {
+	defer close(t.done)
+	// WinRT objects are bound to the thread that initialized the runtime.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if v := windows.RtlGetVersion(); v.MajorVersion < 6 || (v.MajorVersion == 6 && v.MinorVersion < 2) {
+		t.err = fmt.Errorf("toast notifications need Windows 8 or later, running %d.%d", v.MajorVersion, v.MinorVersion)
+		return
+	}
+	const roInitMultithreaded = 1
+	if hr, _, _ := procRoInitialize.Call(roInitMultithreaded); int32(hr) < 0 {
+		t.err = fmt.Errorf("RoInitialize: %w", syscall.Errno(hr))
+		return
+	}
+	defer procRoUninitialize.Call()
+
+	for {
+		select {
+		case <-t.quit:
+			return
+		case ev := <-t.queue:
+			if err := t.show(ev); err != nil {
+				t.err = err
+			}
+		}
+	}
+}
+
+func (t *ToastNotifier) show(ev Event) error {
+	title := "Network offline"
+	if ev.Online {
+		title = "Network online"
+	}
+	body := ev.CauseDetail
+	if ev.Interface != "" {
+		body += " (" + ev.Interface + ")"
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`<toast><visual><binding template="ToastGeneric"><text>`)
+	_ = xml.EscapeText(&buf, []byte(title))
+	buf.WriteString(`</text><text>`)
+	_ = xml.EscapeText(&buf, []byte(body))
+	buf.WriteString(`</text></binding></visual></toast>`)
+
+	doc, err := roActivate("Windows.Data.Xml.Dom.XmlDocument")
+	if err != nil {
+		return err
+	}
+	defer comRelease(doc)
+	docIO, err := comQuery(doc, &iidXmlDocumentIO)
+	if err != nil {
+		return err
+	}
+	defer comRelease(docIO)
+	xmlStr, err := newHString(buf.String())
+	if err != nil {
+		return err
+	}
+	defer procWindowsDeleteString.Call(xmlStr)
+	if hr := comCall(docIO, vtXmlDocumentIOLoadXml, xmlStr); int32(hr) < 0 {
+		return fmt.Errorf("XmlDocument.LoadXml: %w", syscall.Errno(hr))
+	}
+	xmlDoc, err := comQuery(doc, &iidXmlDocument)
+	if err != nil {
+		return err
+	}
+	defer comRelease(xmlDoc)
+
+	factory, err := roFactory("Windows.UI.Notifications.ToastNotification", &iidToastNotificationFactory)
+	if err != nil {
+		return err
+	}
+	defer comRelease(factory)
+	var toast unsafe.Pointer
+	if hr := comCall(factory, vtFactoryCreateToastNotification, uintptr(xmlDoc), uintptr(unsafe.Pointer(&toast))); int32(hr) < 0 {
+		return fmt.Errorf("CreateToastNotification: %w", syscall.Errno(hr))
+	}
+	defer comRelease(toast)
+
+	manager, err := roFactory("Windows.UI.Notifications.ToastNotificationManager", &iidToastNotificationManagerStatics)
+	if err != nil {
+		return err
+	}
+	defer comRelease(manager)
+	appID, err := newHString(t.appID)
+	if err != nil {
+		return err
+	}
+	defer procWindowsDeleteString.Call(appID)
+	var notifier unsafe.Pointer
+	if hr := comCall(manager, vtManagerCreateToastNotifierWithId, appID, uintptr(unsafe.Pointer(&notifier))); int32(hr) < 0 {
+		return fmt.Errorf("CreateToastNotifier: %w", syscall.Errno(hr))
+	}
+	defer comRelease(notifier)
+	if hr := comCall(notifier, vtToastNotifierShow, uintptr(toast)); int32(hr) < 0 {
+		return fmt.Errorf("ToastNotifier.Show: %w", syscall.Errno(hr))
+	}
+	return nil
+}
+
+// -------------------- Minimal WinRT helpers --------------------
+
+func newHString(s string) (uintptr, error) {
+	u, err := windows.UTF16FromString(s)
+	if err != nil {
+		return 0, err
+	}
+	var h uintptr
+	if hr, _, _ := procWindowsCreateString.Call(uintptr(unsafe.Pointer(&u[0])), uintptr(len(u)-1), uintptr(unsafe.Pointer(&h))); int32(hr) < 0 {
+		return 0, fmt.Errorf("WindowsCreateString: %w", syscall.Errno(hr))
+	}
+	return h, nil
+}
+
+func roActivate(class string) (unsafe.Pointer, error) {
+	h, err := newHString(class)
+	if err != nil {
+		return nil, err
+	}
+	defer procWindowsDeleteString.Call(h)
+	var obj unsafe.Pointer
+	if hr, _, _ := procRoActivateInstance.Call(h, uintptr(unsafe.Pointer(&obj))); int32(hr) < 0 {
+		return nil, fmt.Errorf("RoActivateInstance %s: %w", class, syscall.Errno(hr))
+	}
+	return obj, nil
+}
+
+func roFactory(class string, iid *windows.GUID) (unsafe.Pointer, error) {
+	h, err := newHString(class)
+	if err != nil {
+		return nil, err
+	}
+	defer procWindowsDeleteString.Call(h)
+	var obj unsafe.Pointer
+	if hr, _, _ := procRoGetActivationFactory.Call(h, uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&obj))); int32(hr) < 0 {
+		return nil, fmt.Errorf("RoGetActivationFactory %s: %w", class, syscall.Errno(hr))
+	}
+	return obj, nil
+}
+
+func comQuery(obj unsafe.Pointer, iid *windows.GUID) (unsafe.Pointer, error) {
+	var out unsafe.Pointer
+	if hr := comCall(obj, vtQueryInterface, uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&out))); int32(hr) < 0 {
+		return nil, fmt.Errorf("QueryInterface: %w", syscall.Errno(hr))
+	}
+	return out, nil
+}
+
+func comRelease(obj unsafe.Pointer) {
+	if obj != nil {
+		comCall(obj, vtRelease)
+	}
+}
+
+// comCall invokes vtable slot idx of obj with obj as the implicit this.
+func comCall(obj unsafe.Pointer, idx int, args ...uintptr) uintptr {
+	vtbl := *(*unsafe.Pointer)(obj)
+	fn := *(*uintptr)(unsafe.Add(vtbl, uintptr(idx)*unsafe.Sizeof(uintptr(0))))
+	r, _, _ := syscall.SyscallN(fn, append([]uintptr{uintptr(obj)}, args...)...)
+	return r
+}
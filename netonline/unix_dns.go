@@ -10,7 +10,8 @@ import (
 	"strings"
 )
 
-func hasDNSResolver() bool {
+// resolvConfHasDNS reports whether /etc/resolv.conf lists a nameserver.
+func resolvConfHasDNS() bool {
 	f, err := os.Open("/etc/resolv.conf")
 	if err != nil { return false }
 	defer f.Close()
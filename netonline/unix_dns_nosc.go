@@ -0,0 +1,6 @@
+//go:build freebsd || (darwin && !cgo)
+// +build freebsd darwin,!cgo
+
+package netonline
+
+func hasDNSResolver() bool { return resolvConfHasDNS() }
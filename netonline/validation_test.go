@@ -0,0 +1,65 @@
+package netonline_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"example.com/netonline/netonline"
+	"example.com/netonline/netonline/netonlinetesting"
+)
+
+// switchChecker returns a checker with a single probe that passes while
+// *pass is true.
+func switchChecker(pass *atomic.Bool) *netonline.ConnectivityChecker {
+	c := netonline.NewChecker(time.Second, 1)
+	c.Register("switch", func(ctx context.Context) error {
+		if !pass.Load() {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+	return c
+}
+
+func TestActiveValidationFailsWhileOnline(t *testing.T) {
+	var pass atomic.Bool
+	pass.Store(true)
+	m := netonlinetesting.NewMockPlatform()
+	m.SetOnline(true)
+	events := startMock(t, m, true, netonline.WithActiveValidation(switchChecker(&pass)))
+
+	pass.Store(false)
+	change(m, true)
+	ev := nextEvent(t, events)
+	if ev.Online || ev.Cause != netonline.CauseValidationFailed {
+		t.Fatalf("after validation started failing: %v, want offline with CauseValidationFailed", ev)
+	}
+
+	// Still failing: going back online is held back.
+	change(m, true)
+	noPendingEvent(t, events)
+
+	pass.Store(true)
+	change(m, true)
+	if ev := nextEvent(t, events); !ev.Online {
+		t.Fatalf("after validation passed again: %v, want online", ev)
+	}
+}
+
+func TestActiveValidationHoldsOnline(t *testing.T) {
+	var pass atomic.Bool
+	m := netonlinetesting.NewMockPlatform()
+	events := startMock(t, m, false, netonline.WithActiveValidation(switchChecker(&pass)))
+
+	change(m, true)
+	noPendingEvent(t, events)
+
+	pass.Store(true)
+	change(m, true)
+	if ev := nextEvent(t, events); !ev.Online {
+		t.Fatalf("after validation passed: %v, want online", ev)
+	}
+}
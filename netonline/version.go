@@ -0,0 +1,16 @@
+package netonline
+
+import "runtime"
+
+// version is a variable rather than a constant so that release builds can
+// stamp it with
+//
+//	-ldflags "-X example.com/netonline/netonline.version=1.2.3"
+var version = "0.3.0"
+
+// Version returns the version of the package.
+func Version() string { return version }
+
+// BuildInfo returns the version followed by the OS and architecture the
+// binary was built for, such as "0.2.0 linux/amd64".
+func BuildInfo() string { return version + " " + runtime.GOOS + "/" + runtime.GOARCH }
@@ -0,0 +1,117 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package netonline
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+
+#include <stdlib.h>
+#include <unistd.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/pwr_mgt/IOPMLib.h>
+#include <IOKit/IOMessage.h>
+
+typedef struct {
+	io_connect_t port;
+	IONotificationPortRef notify;
+	io_object_t notifier;
+	int fd;
+} netonlineWake;
+
+// netonlinePowerCallback acknowledges sleep requests, which would otherwise
+// delay sleep by 30 seconds, and writes a byte to the pipe before sleep
+// ('s') and on wake ('w').
+static void netonlinePowerCallback(void *refcon, io_service_t service, natural_t type, void *arg) {
+	netonlineWake *w = refcon;
+	switch (type) {
+	case kIOMessageCanSystemSleep:
+		IOAllowPowerChange(w->port, (long)arg);
+		break;
+	case kIOMessageSystemWillSleep:
+		(void)write(w->fd, "s", 1);
+		IOAllowPowerChange(w->port, (long)arg);
+		break;
+	case kIOMessageSystemHasPoweredOn:
+		(void)write(w->fd, "w", 1);
+		break;
+	}
+}
+
+// netonlineWakeStart registers for power notifications on the current
+// thread's run loop. It returns NULL if IORegisterForSystemPower fails.
+static netonlineWake *netonlineWakeStart(int fd) {
+	netonlineWake *w = calloc(1, sizeof *w);
+	if (w == NULL) {
+		return NULL;
+	}
+	w->fd = fd;
+	w->port = IORegisterForSystemPower(w, &w->notify, netonlinePowerCallback, &w->notifier);
+	if (w->port == MACH_PORT_NULL) {
+		free(w);
+		return NULL;
+	}
+	CFRunLoopAddSource(CFRunLoopGetCurrent(), IONotificationPortGetRunLoopSource(w->notify), kCFRunLoopDefaultMode);
+	return w;
+}
+
+static void netonlineWakeRunFor(double seconds) {
+	CFRunLoopRunInMode(kCFRunLoopDefaultMode, seconds, false);
+}
+
+static void netonlineWakeStop(netonlineWake *w) {
+	CFRunLoopRemoveSource(CFRunLoopGetCurrent(), IONotificationPortGetRunLoopSource(w->notify), kCFRunLoopDefaultMode);
+	IODeregisterForSystemPower(&w->notifier);
+	IOServiceClose(w->port);
+	IONotificationPortDestroy(w->notify);
+	free(w);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// startNativePowerWatcher reports IOKit's system power notifications
+// (IORegisterForSystemPower): kIOMessageSystemWillSleep before sleep and
+// kIOMessageSystemHasPoweredOn on wake. The callback runs on a run loop
+// owned by a locked OS thread and signals Go through a pipe.
+func startNativePowerWatcher(ctx context.Context) (<-chan SleepEvent, error) {
+	var fds [2]int
+	if err := unix.Pipe(fds[:]); err != nil { return nil, err }
+	unix.CloseOnExec(fds[0]); unix.CloseOnExec(fds[1])
+	started := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer unix.Close(fds[1]) // the reader sees EOF
+		w := C.netonlineWakeStart(C.int(fds[1]))
+		if w == nil { started <- errors.New("IORegisterForSystemPower failed"); return }
+		started <- nil
+		// Waking up once a second is only for noticing ctx; the callback
+		// runs as soon as the notification arrives.
+		for ctx.Err() == nil { C.netonlineWakeRunFor(1) }
+		C.netonlineWakeStop(w)
+	}()
+	if err := <-started; err != nil { unix.Close(fds[0]); return nil, err }
+	out := make(chan SleepEvent, 4)
+	go func() {
+		defer close(out); defer unix.Close(fds[0])
+		buf := make([]byte, 16)
+		for {
+			n, err := unix.Read(fds[0], buf)
+			if err == unix.EINTR { continue }
+			if n <= 0 || err != nil { return }
+			for _, b := range buf[:n] {
+				select { case out <- SleepEvent{Suspending: b == 's', At: time.Now()}: default: }
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,71 @@
+//go:build linux && netonline_dbus
+
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindBusName          = "org.freedesktop.login1"
+	logindManagerInterface = "org.freedesktop.login1.Manager"
+)
+
+// startNativePowerWatcher reports systemd-logind's PrepareForSleep signal,
+// sent with true before sleep and false on wake. It fails when D-Bus is
+// unreachable or logind is not running. It is only built with the
+// netonline_dbus tag, so that starting a wake watcher does not connect to
+// the system bus unless asked to.
+func startNativePowerWatcher(ctx context.Context) (<-chan SleepEvent, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("logind: %w", err)
+	}
+	var running bool
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.NameHasOwner", 0, logindBusName).Store(&running); err != nil || !running {
+		conn.Close()
+		if err == nil {
+			err = errors.New("not running")
+		}
+		return nil, fmt.Errorf("logind: %w", err)
+	}
+	if err := conn.AddMatchSignalContext(ctx, dbus.WithMatchSender(logindBusName), dbus.WithMatchInterface(logindManagerInterface), dbus.WithMatchMember("PrepareForSleep")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("logind: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	out := make(chan SleepEvent, 4)
+	go func() {
+		defer close(out)
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if sig.Name != logindManagerInterface+".PrepareForSleep" || len(sig.Body) != 1 {
+					continue
+				}
+				suspending, ok := sig.Body[0].(bool)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- SleepEvent{Suspending: suspending, At: time.Now()}:
+				default:
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !(darwin && cgo) && !(linux && netonline_dbus)
+
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func startNativePowerWatcher(ctx context.Context) (<-chan SleepEvent, error) {
+	return nil, fmt.Errorf("netonline: no native sleep notifications in this build: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,35 @@
+//go:build !windows && !(darwin && cgo) && !(linux && netonline_dbus)
+
+package netonline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Without native notifications, StartSleepWatcher reports them as
+// unsupported, and StartWakeWatcher and StartWakeGapWatcher fall back to
+// the clock gap method instead of connecting anywhere.
+func TestSleepWatcherUnsupported(t *testing.T) {
+	if _, err := StartSleepWatcher(context.Background()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("StartSleepWatcher: %v, want errors.ErrUnsupported", err)
+	}
+	for name, start := range map[string]func(context.Context, time.Duration, time.Duration) <-chan struct{}{
+		"StartWakeWatcher":    StartWakeWatcher,
+		"StartWakeGapWatcher": StartWakeGapWatcher,
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		wakes := start(ctx, time.Millisecond, time.Hour)
+		cancel()
+		select {
+		case _, ok := <-wakes:
+			if ok {
+				t.Fatalf("%s: wake signal without a wake", name)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: gap wake watcher not stopped by cancellation", name)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+//go:build windows
+// +build windows
+
+package netonline
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32               = windows.NewLazySystemDLL("user32.dll")
+	procRegisterClassExW = user32.NewProc("RegisterClassExW")
+	procUnregisterClassW = user32.NewProc("UnregisterClassW")
+	procCreateWindowExW  = user32.NewProc("CreateWindowExW")
+	procDestroyWindow    = user32.NewProc("DestroyWindow")
+	procIsWindow         = user32.NewProc("IsWindow")
+	procDefWindowProcW   = user32.NewProc("DefWindowProcW")
+	procGetMessageW      = user32.NewProc("GetMessageW")
+	procDispatchMessageW = user32.NewProc("DispatchMessageW")
+	procPostMessageW     = user32.NewProc("PostMessageW")
+	procPostQuitMessage  = user32.NewProc("PostQuitMessage")
+)
+
+// Window messages and WM_POWERBROADCAST events.
+const (
+	wmDestroy        = 0x0002
+	wmClose          = 0x0010
+	wmPowerBroadcast = 0x0218
+
+	pbtAPMSuspend         = 0x0004
+	pbtAPMResumeAutomatic = 0x0012
+)
+
+// WNDCLASSEXW
+type wndClassEx struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   windows.Handle
+	Icon       windows.Handle
+	Cursor     windows.Handle
+	Background windows.Handle
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     windows.Handle
+}
+
+// MSG
+type winMsg struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      struct{ X, Y int32 }
+	Private uint32
+}
+
+var (
+	wakeProcOnce  sync.Once
+	wakeProc      uintptr // the window procedure of the class
+	wakeClassName = windows.StringToUTF16Ptr("netonlineWakeWindow")
+	wakeInstance  windows.Handle
+
+	// wakeClassRefs counts the watchers using the registered window
+	// class, and wakeWindows maps each wake window to their channel.
+	wakeMu        sync.Mutex
+	wakeClassRefs int
+	wakeWindows   = make(map[uintptr]chan SleepEvent)
+)
+
+// acquireWakeClass registers the window class shared by all wake windows
+// for the first watcher to start. The window procedure is created only
+// once, since callbacks made with windows.NewCallback are never freed.
+func acquireWakeClass() error {
+	wakeMu.Lock()
+	defer wakeMu.Unlock()
+	if wakeClassRefs == 0 {
+		if err := procRegisterClassExW.Find(); err != nil {
+			return fmt.Errorf("%w: RegisterClassExW: %w", ErrWin32API, err)
+		}
+		if wakeInstance == 0 {
+			if err := windows.GetModuleHandleEx(0, nil, &wakeInstance); err != nil {
+				return fmt.Errorf("%w: GetModuleHandleEx: %w", ErrWin32API, err)
+			}
+		}
+		wakeProcOnce.Do(func() { wakeProc = windows.NewCallback(wakeWndProc) })
+		wc := wndClassEx{
+			WndProc:   wakeProc,
+			Instance:  wakeInstance,
+			ClassName: wakeClassName,
+		}
+		wc.Size = uint32(unsafe.Sizeof(wc))
+		if r0, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); r0 == 0 {
+			return fmt.Errorf("%w: RegisterClassExW: %w", ErrWin32API, err)
+		}
+	}
+	wakeClassRefs++
+	return nil
+}
+
+// releaseWakeClass unregisters the window class when the last watcher
+// using it has destroyed its window; UnregisterClassW fails while windows
+// of the class exist.
+func releaseWakeClass() {
+	wakeMu.Lock()
+	defer wakeMu.Unlock()
+	if wakeClassRefs--; wakeClassRefs == 0 {
+		_, _, _ = procUnregisterClassW.Call(uintptr(unsafe.Pointer(wakeClassName)), uintptr(wakeInstance))
+	}
+}
+
+func wakeWndProc(hwnd, msg, wparam, lparam uintptr) uintptr {
+	switch msg {
+	case wmPowerBroadcast:
+		if wparam == pbtAPMSuspend || wparam == pbtAPMResumeAutomatic {
+			wakeMu.Lock()
+			ch := wakeWindows[hwnd]
+			wakeMu.Unlock()
+			select {
+			case ch <- SleepEvent{Suspending: wparam == pbtAPMSuspend, At: time.Now()}:
+			default:
+			}
+		}
+		return 1 // TRUE
+	case wmDestroy:
+		_, _, _ = procPostQuitMessage.Call(0)
+		return 0
+	}
+	r0, _, _ := procDefWindowProcW.Call(hwnd, msg, wparam, lparam)
+	return r0
+}
+
+// startNativePowerWatcher reports WM_POWERBROADCAST: PBT_APMSUSPEND before
+// sleep and PBT_APMRESUMEAUTOMATIC, which Windows sends on every resume,
+// whether or not a user is present. The window is a hidden top-level one
+// rather than a message-only window (HWND_MESSAGE), since those do not
+// receive broadcast messages; top-level windows get these without
+// RegisterPowerSettingNotification, so there is no notification handle to
+// release. The message loop runs on a locked OS thread until ctx is done,
+// after which the window is destroyed and, with the last watcher, its
+// class unregistered.
+func startNativePowerWatcher(ctx context.Context) (<-chan SleepEvent, error) {
+	if err := acquireWakeClass(); err != nil {
+		return nil, err
+	}
+	out := make(chan SleepEvent, 4)
+	started := make(chan error, 1)
+	go func() {
+		// The goroutine exits with the thread still locked, which ends the
+		// thread too: a WM_QUIT left in its message queue must not reach a
+		// later watcher's message loop.
+		runtime.LockOSThread()
+		defer releaseWakeClass()
+
+		hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(wakeClassName)), 0, 0,
+			0, 0, 0, 0, 0, 0, uintptr(wakeInstance), 0)
+		if hwnd == 0 {
+			started <- fmt.Errorf("%w: CreateWindowExW: %w", ErrWin32API, err)
+			return
+		}
+		defer close(out)
+		wakeMu.Lock()
+		wakeWindows[hwnd] = out
+		wakeMu.Unlock()
+		defer func() {
+			wakeMu.Lock()
+			delete(wakeWindows, hwnd)
+			wakeMu.Unlock()
+		}()
+		// Normally the window is gone by the time the loop ends; this
+		// covers GetMessageW failing.
+		defer func() {
+			if r0, _, _ := procIsWindow.Call(hwnd); r0 != 0 {
+				_, _, _ = procDestroyWindow.Call(hwnd)
+			}
+		}()
+		started <- nil
+
+		// WM_CLOSE makes DefWindowProcW destroy the window, and WM_DESTROY
+		// posts the WM_QUIT that ends the loop; both have to happen on this
+		// thread.
+		stop := context.AfterFunc(ctx, func() {
+			_, _, _ = procPostMessageW.Call(hwnd, wmClose, 0, 0)
+		})
+		defer stop()
+
+		var m winMsg
+		for {
+			r0, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+			if r0 == 0 || int32(r0) == -1 { // WM_QUIT or an error
+				return
+			}
+			_, _, _ = procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+		}
+	}()
+	if err := <-started; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package netonline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.uber.org/goleak"
+	"golang.org/x/sys/windows"
+
+	"example.com/netonline/netonline"
+)
+
+var (
+	procGetProcessHandleCount = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+	procGetGuiResources       = windows.NewLazySystemDLL("user32.dll").NewProc("GetGuiResources")
+)
+
+// processObjects returns the process's kernel object handle count and its
+// USER object count, which includes windows.
+func processObjects(t *testing.T) (handles, user uint32) {
+	t.Helper()
+	p := windows.CurrentProcess()
+	if r0, _, err := procGetProcessHandleCount.Call(uintptr(p), uintptr(unsafe.Pointer(&handles))); r0 == 0 {
+		t.Fatalf("GetProcessHandleCount: %v", err)
+	}
+	r0, _, _ := procGetGuiResources.Call(uintptr(p), 1) // GR_USEROBJECTS
+	return handles, uint32(r0)
+}
+
+// TestSleepWatcherNoLeaks starts and cancels the hidden-window power
+// watcher 100 times and checks that the goroutines, windows and kernel
+// handles of each are gone afterwards.
+func TestSleepWatcherNoLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	cycle := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := netonline.StartSleepWatcher(ctx)
+		if err != nil {
+			cancel()
+			t.Fatalf("StartSleepWatcher: %v", err)
+		}
+		cancel()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+			case <-deadline:
+				t.Fatal("sleep watcher channel not closed after cancel")
+			}
+		}
+	}
+	cycle() // load user32 and let the runtime settle
+	handles, user := processObjects(t)
+	for range 100 {
+		cycle()
+	}
+	// The runtime may keep a few more threads, each with a handle, but a
+	// leak of a handle or window per watcher would show here.
+	afterHandles, afterUser := processObjects(t)
+	if afterHandles > handles+20 {
+		t.Errorf("handle count %d -> %d after 100 watchers", handles, afterHandles)
+	}
+	if afterUser > user+5 {
+		t.Errorf("USER object count %d -> %d after 100 watchers", user, afterUser)
+	}
+}
@@ -8,62 +8,117 @@ import (
 type Event struct {
 	Online    bool
 	ChangedAt time.Time
-	Cause     string
+	// Cause classifies why the event was emitted; CauseDetail explains it
+	// for humans, for example "route change; default via eth0".
+	Cause       CauseCode
+	CauseDetail string
+	// Interface is the default interface the state was derived from, or
+	// empty when there is none.
+	Interface string
+	// InterfaceKind classifies Interface while online; it is
+	// InterfaceOther when offline or when the kind is unknown.
+	InterfaceKind InterfaceKind
+	// Addr is the preferred usable address on the default interface, or
+	// empty when none was found.
+	Addr string
+	// CheckResult is the active validation that ran alongside the passive
+	// check. It is nil unless a ConnectivityChecker is attached (see
+	// WithConnectivityChecker and WithActiveValidation) and the passive check
+	// reported online.
+	CheckResult *CheckResult
+	// InterfaceRenamed is set on events emitted because the default
+	// interface was renamed (for example by a udev rule after boot) while
+	// the network stayed online. OldInterface and NewInterface hold the
+	// names before and after; Interface equals NewInterface.
+	InterfaceRenamed bool
+	OldInterface     string
+	NewInterface     string
+	// CaptivePortalURL is the captive portal found by
+	// WithCaptivePortalDetection; nil when there is none. Online is false
+	// whenever it is set.
+	CaptivePortalURL *string
+	// Metered reports whether traffic over Interface is billed by volume,
+	// as on most cellular connections, so applications can postpone large
+	// background transfers; nil when unknown or offline. It comes from
+	// NetworkManager on Linux and the connectivity cost hint on Windows
+	// (10 2004 and later); elsewhere, or when those have no answer, only
+	// cellular interfaces are reported, as metered.
+	Metered *bool
 }
 
+// IsHeartbeat reports whether e is a periodic heartbeat (see
+// WithHeartbeatInterval) rather than a state change.
+func (e Event) IsHeartbeat() bool { return e.Cause == CauseHeartbeat }
+
+// CauseString returns the free-form cause, which Event.Cause held before it
+// became a CauseCode. It equals CauseDetail.
+func (e Event) CauseString() string { return e.CauseDetail }
+
 type osEvent struct{ reason string }
 
-func Watch(ctx context.Context) (<-chan Event, <-chan error) {
-	out := make(chan Event, 1)
-	errc := make(chan error, 1)
-	events, errs := startOSEventStream(ctx)
+// Watch starts watching the passive online state and returns its event and
+// error channels. It is shorthand for NewWatcher(ctx, opts...) followed by
+// Events and Errors. Both channels are always closed once ctx is cancelled,
+// whatever the options. Without options, OS changes are debounced for 750ms
+// and the first event reports the initial state; see WithDebounce,
+// WithEventBufferSize and WithSuppressInitial.
+func Watch(ctx context.Context, opts ...WatchOption) (<-chan Event, <-chan error) {
+	w := NewWatcher(ctx, opts...)
+	return w.Events(), w.Errors()
+}
 
-	online, why, err := recomputeOnline()
-	if err != nil {
-		errc <- err
+// evaluateWith runs the passive check and, when it reports online and a
+// checker is configured, the active probes. The returned state is online only
+// if both agree. validating, if not nil, is called before the probes run.
+func evaluateWith(ctx context.Context, cfg *config, cache *evalCache, validating func()) (netState, *CheckResult, error) {
+	st, err := passiveState(ctx, cfg, cache)
+	if err != nil || !st.online || cfg.checker == nil {
+		return st, nil, err
 	}
-	last := online
-	out <- Event{Online: online, ChangedAt: time.Now(), Cause: "initial: " + why}
+	if validating != nil {
+		validating()
+	}
+	res := cfg.checker.Check(ctx)
+	if !res.OK {
+		st.online = false
+		st.why += "; validation failed (" + res.Reason + ")"
+	}
+	return st, res, nil
+}
 
+// WatchMessage carries either an Event or an error from WatchCombined;
+// exactly one of the two fields is non-nil.
+type WatchMessage struct {
+	Event *Event
+	Err   error
+}
+
+// WatchCombined is Watch with events and errors merged into one channel, so
+// callers can consume everything with a single range loop. The channel is
+// closed once both underlying channels are closed.
+func WatchCombined(ctx context.Context, opts ...Option) <-chan WatchMessage {
+	events, errs := Watch(ctx, opts...)
+	out := make(chan WatchMessage, 1)
 	go func() {
 		defer close(out)
-		defer close(errc)
-		var lastReason string
-		var debounceTimer *time.Timer
-		trigger := func() {
-			online, why, err := recomputeOnline()
-			if err != nil {
-				errc <- err
-				return
-			}
-			if online != last {
-				last = online
-				cause := why
-				if lastReason != "" {
-					cause = lastReason + "; " + why
-				}
-				out <- Event{Online: online, ChangedAt: time.Now(), Cause: cause}
-			}
-		}
-		for {
+		for events != nil || errs != nil {
 			select {
-			case <-ctx.Done():
-				if debounceTimer != nil {
-					debounceTimer.Stop()
+			case ev, ok := <-events:
+				if !ok {
+					events = nil
+					continue
 				}
-				return
-			case e := <-events:
-				lastReason = e.reason
-				if debounceTimer != nil {
-					debounceTimer.Stop()
+				out <- WatchMessage{Event: &ev}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
 				}
-				debounceTimer = time.AfterFunc(750*time.Millisecond, trigger)
-			case err := <-errs:
 				if err != nil {
-					errc <- err
+					out <- WatchMessage{Err: err}
 				}
 			}
 		}
 	}()
-	return out, errc
+	return out
 }
@@ -0,0 +1,447 @@
+package netonline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrEventStreamLost is reported by a Watcher that gave up restarting its OS
+// event stream (see WithMaxReconnectAttempts).
+var ErrEventStreamLost = errors.New("netonline: os event stream lost")
+
+const (
+	minReconnectBackoff  = time.Second
+	maxReconnectBackoff  = time.Minute
+	fallbackPollInterval = 5 * time.Second
+)
+
+// Watcher owns one watch loop: the OS change subscription, the debounce and
+// the event stream built on top of them.
+type Watcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *config
+
+	start sync.Once
+	out   chan Event
+	errc  chan error
+	done  chan struct{}
+
+	mu        sync.Mutex
+	life      lifetime
+	changed   chan struct{} // closed and replaced whenever the state changes
+	st        atomic.Int32  // current State
+	history   [transitionHistory]Transition
+	historyN  int    // transitions recorded so far
+	iface     string // Interface of the last event
+	dropped   atomic.Uint64
+	listeners []listener
+	nextID    int
+	cache     evalCache // used by the watch loop only
+}
+
+type listener struct {
+	id int
+	fn func(Event)
+}
+
+// NewWatcher creates a Watcher and, unless WithLazyStart is given, starts it.
+// The watcher stops when ctx is cancelled or Stop is called.
+func NewWatcher(ctx context.Context, opts ...Option) *Watcher {
+	ctx, cancel := context.WithCancel(ctx)
+	cfg := newConfig(opts)
+	w := &Watcher{
+		ctx:    ctx,
+		cancel: cancel,
+		cfg:    cfg,
+		out:    make(chan Event, cfg.buffer),
+		errc:   make(chan error, 1),
+		done:   make(chan struct{}),
+
+		changed: make(chan struct{}),
+	}
+	if !w.cfg.lazy {
+		w.start.Do(w.begin)
+	}
+	return w
+}
+
+// Events returns the event channel, starting the watcher first if it was
+// created with WithLazyStart.
+func (w *Watcher) Events() <-chan Event {
+	w.start.Do(w.begin)
+	return w.out
+}
+
+// Errors returns the error channel. It does not start a lazy watcher.
+func (w *Watcher) Errors() <-chan error { return w.errc }
+
+// Stop cancels the watcher and waits for its goroutine to exit. The event and
+// error channels are closed when Stop returns.
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.start.Do(func() {
+		close(w.out)
+		close(w.errc)
+		close(w.done)
+	})
+	<-w.done
+}
+
+// Notify registers fn to be called with every event the watcher emits,
+// heartbeats included. fn runs on the watcher goroutine and must not block;
+// the Events channel still has to be drained. The returned function removes
+// the registration.
+func (w *Watcher) Notify(fn func(Event)) (cancel func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.nextID
+	w.nextID++
+	w.listeners = append(w.listeners, listener{id: id, fn: fn})
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, l := range w.listeners {
+			if l.id == id {
+				w.listeners = append(w.listeners[:i:i], w.listeners[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// DroppedEvents reports how many events were discarded because the event
+// channel was full: new ones under OrderedFIFO, buffered ones under
+// LatestWins.
+func (w *Watcher) DroppedEvents() uint64 { return w.dropped.Load() }
+
+// sendLatest enqueues ev, discarding the oldest buffered events while the
+// channel is full.
+func (w *Watcher) sendLatest(ev Event) {
+	for {
+		select {
+		case w.out <- ev:
+			return
+		default:
+		}
+		select {
+		case <-w.out:
+			w.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+func (w *Watcher) notify(ev Event) {
+	w.mu.Lock()
+	ls := w.listeners
+	w.mu.Unlock()
+	w.logEvent(ev)
+	for _, l := range ls {
+		l.fn(ev)
+	}
+}
+
+// begin runs run on the calling goroutine, or in the background with
+// WithAsyncInitialEvent.
+func (w *Watcher) begin() {
+	if w.cfg.asyncInitial {
+		go w.run()
+		return
+	}
+	w.run()
+}
+
+// run performs the initial evaluation and starts the watch loop.
+func (w *Watcher) run() {
+	ctx, cfg, out, errc := w.ctx, w.cfg, w.out, w.errc
+	now := time.Now()
+	w.mu.Lock()
+	w.life.stats.StartedAt = now
+	w.mu.Unlock()
+	if ctx.Err() != nil {
+		// Cancelled before it started: report nothing, not even the
+		// initial state.
+		w.mu.Lock()
+		w.life.stats.StoppedAt = now
+		w.mu.Unlock()
+		close(out)
+		close(errc)
+		close(w.done)
+		return
+	}
+	events, errs := startEventStream(ctx, cfg)
+
+	st, res, err := w.evaluate()
+	if err != nil {
+		err = newWatchError(ErrKindInternal, err)
+		w.logError(err)
+		errc <- err
+	}
+	cur := Event{Online: st.online, ChangedAt: time.Now(), Cause: CauseInitial, CauseDetail: "initial: " + st.why, Interface: st.iface, Addr: st.addr, CheckResult: res, CaptivePortalURL: st.portalURL(), Metered: st.metered, InterfaceKind: st.kind}
+	// With a stabilization delay, an initial online state is only announced
+	// once the network has been quiet for that long (see below).
+	stabilizing := cur.Online && cfg.stabilize > 0
+	// pass applies WithSuppressInitial and WithEmitOnlyTransitions to an
+	// event that is about to be emitted. Suppressed events still count as
+	// the last state.
+	var known, lastOnline bool
+	pass := func(ev Event, initial bool) bool {
+		dup := known && ev.Online == lastOnline
+		known, lastOnline = true, ev.Online
+		if initial {
+			return !cfg.suppressInit
+		}
+		return !cfg.transitions || !dup
+	}
+	if !stabilizing {
+		w.observe(cur)
+		if pass(cur, true) {
+			w.notify(cur)
+			out <- cur
+		}
+	}
+
+	go func() {
+		defer close(w.done)
+		defer close(out)
+		defer close(errc)
+		defer func() {
+			w.mu.Lock()
+			now := time.Now()
+			w.life.close(now)
+			w.life.stats.StoppedAt = now
+			w.mu.Unlock()
+		}()
+		emit := func(ev Event) {
+			w.notify(ev)
+			if cfg.semantics == LatestWins {
+				w.sendLatest(ev)
+				return
+			}
+			select {
+			case out <- ev:
+			default:
+				w.dropped.Add(1)
+			}
+		}
+		report := func(err error) {
+			err = newWatchError(ErrKindInternal, err)
+			w.logError(err)
+			select {
+			case errc <- err:
+			case <-ctx.Done():
+			}
+		}
+		var lastReason string
+		var lastCode CauseCode
+		lastIndex := st.index
+		// Every timer comes from cfg.clock, so that WithClock drives them all.
+		newTimer := func() ClockTimer {
+			t := cfg.clock.NewTimer(time.Hour)
+			t.Stop()
+			return t
+		}
+		debounce := newTimer()
+		defer debounce.Stop()
+		var debounceC <-chan time.Time
+		heartbeat := newTimer()
+		defer heartbeat.Stop()
+		var heartbeatC <-chan time.Time
+		if cfg.heartbeat > 0 {
+			heartbeat.Reset(cfg.heartbeat)
+			heartbeatC = heartbeat.C()
+		}
+		// When the OS stream dies, it is restarted with exponential backoff
+		// and the state is polled in the meantime.
+		backoff := minReconnectBackoff
+		attempts := 0
+		reconnect := newTimer()
+		defer reconnect.Stop()
+		var reconnectC <-chan time.Time
+		poll := newTimer()
+		defer poll.Stop()
+		var pollC <-chan time.Time
+		stable := newTimer()
+		defer stable.Stop()
+		var stableC <-chan time.Time
+		if stabilizing {
+			stable.Reset(cfg.stabilize)
+			stableC = stable.C()
+		}
+		// step tells the WithStepHook hook that an event or a timer has
+		// been handled.
+		step := func() {
+			if cfg.stepHook != nil {
+				cfg.stepHook()
+			}
+		}
+		// With WithOnlineDebounce or WithOfflineDebounce, a change is only
+		// emitted if it is still there when confirm fires.
+		confirm := newTimer()
+		defer confirm.Stop()
+		var confirmC <-chan time.Time
+		var pending, pendingOnline bool
+		cancelPending := func() {
+			if pending {
+				pending = false
+				confirm.Stop()
+				confirmC = nil
+			}
+		}
+		trigger := func(confirmed bool) {
+			st, res, err := w.evaluate()
+			if err != nil {
+				report(err)
+				return
+			}
+			// WithActiveValidation only holds back going online: a failed
+			// validation while online is reported as offline below.
+			if res != nil && !res.OK && cfg.holdOnline && !cur.Online {
+				return
+			}
+			if st.online != cur.Online {
+				if d := cfg.directionDebounce(st.online); d > 0 && !confirmed {
+					if !pending || pendingOnline != st.online {
+						pending, pendingOnline = true, st.online
+						confirm.Reset(d)
+						confirmC = confirm.C()
+					}
+					return
+				}
+				cancelPending()
+				cause := st.why
+				if lastReason != "" {
+					cause = lastReason + "; " + st.why
+				}
+				code := lastCode
+				switch {
+				case st.portal != "":
+					code = CauseCaptivePortal
+				case res != nil && !res.OK:
+					code = CauseValidationFailed
+				}
+				cur = Event{Online: st.online, ChangedAt: time.Now(), Cause: code, CauseDetail: cause, Interface: st.iface, Addr: st.addr, CheckResult: res, CaptivePortalURL: st.portalURL(), Metered: st.metered, InterfaceKind: st.kind}
+				lastIndex = st.index
+				w.observe(cur)
+				if pass(cur, false) {
+					emit(cur)
+				}
+				return
+			}
+			cancelPending()
+			// A rename keeps the interface index; a different index means
+			// the default route moved to another interface.
+			idx := st.index
+			if st.online && st.iface != cur.Interface && cur.Interface != "" && idx != 0 && idx == lastIndex {
+				old := cur.Interface
+				cur = Event{Online: true, ChangedAt: time.Now(), Cause: CauseInterfaceRenamed, CauseDetail: "interface renamed: " + old + " -> " + st.iface, Interface: st.iface, Addr: st.addr, CheckResult: res,
+					InterfaceRenamed: true, OldInterface: old, NewInterface: st.iface, Metered: st.metered, InterfaceKind: st.kind}
+				w.observe(cur)
+				if pass(cur, false) {
+					emit(cur)
+				}
+			}
+			cur.Interface, cur.Addr = st.iface, st.addr
+			lastIndex = idx
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					events = nil
+					if ctx.Err() != nil {
+						continue
+					}
+					if cfg.maxReconnect <= 0 || attempts < cfg.maxReconnect {
+						reconnect.Reset(backoff)
+						reconnectC = reconnect.C()
+					} else {
+						report(fmt.Errorf("%w after %d reconnect attempts, polling only", ErrEventStreamLost, attempts))
+					}
+					poll.Reset(fallbackPollInterval)
+					pollC = poll.C()
+					continue
+				}
+				w.cache.invalidate()
+				if pollC != nil {
+					// The restarted stream works again.
+					poll.Stop()
+					pollC = nil
+					backoff = minReconnectBackoff
+				}
+				if stableC != nil {
+					stable.Reset(cfg.stabilize)
+				} else {
+					lastReason, lastCode = e.reason, causeFromReason(e.reason)
+					debounce.Reset(cfg.debounce)
+					debounceC = debounce.C()
+				}
+				step()
+			case <-stableC:
+				stableC = nil
+				st, res, err := w.evaluate()
+				if err != nil {
+					report(err)
+				}
+				cur = Event{Online: st.online, ChangedAt: time.Now(), Cause: CauseInitial, CauseDetail: "initial: " + st.why, Interface: st.iface, Addr: st.addr, CheckResult: res, CaptivePortalURL: st.portalURL(), Metered: st.metered, InterfaceKind: st.kind}
+				lastIndex = st.index
+				w.observe(cur)
+				if pass(cur, true) {
+					emit(cur)
+				}
+				step()
+			case <-debounceC:
+				debounceC = nil
+				trigger(false)
+				step()
+			case <-confirmC:
+				confirmC = nil
+				pending = false
+				w.cache.invalidate()
+				trigger(true)
+				step()
+			case <-reconnectC:
+				reconnectC = nil
+				attempts++
+				backoff *= 2
+				if backoff > maxReconnectBackoff {
+					backoff = maxReconnectBackoff
+				}
+				events, errs = startEventStream(ctx, cfg)
+				step()
+			case <-pollC:
+				poll.Reset(fallbackPollInterval)
+				lastReason, lastCode = "poll", CauseOther
+				trigger(false)
+				step()
+			case <-heartbeatC:
+				heartbeat.Reset(cfg.heartbeat)
+				if stableC == nil {
+					hb := cur
+					hb.ChangedAt = time.Now()
+					hb.Cause, hb.CauseDetail = CauseHeartbeat, "heartbeat"
+					hb.InterfaceRenamed, hb.OldInterface, hb.NewInterface = false, "", ""
+					if pass(hb, false) {
+						emit(hb)
+					}
+				}
+				step()
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				if err != nil {
+					report(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,178 @@
+package netonline_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"example.com/netonline/netonline"
+	"example.com/netonline/netonline/netonlinetesting"
+)
+
+// TestWatcherPreCancelled starts watchers on a context that is already
+// cancelled: both channels must close promptly, without an initial event,
+// and nothing may be left running.
+func TestWatcherPreCancelled(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []netonline.Option
+	}{
+		{"sync initial event", nil},
+		{"async initial event", []netonline.Option{netonline.WithAsyncInitialEvent()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t)
+			m := netonlinetesting.NewMockPlatform()
+			m.SetOnline(true)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := netonline.NewWatcher(ctx, append(m.Options(), tt.opts...)...)
+			deadline := time.After(time.Second)
+			for events, errs := w.Events(), w.Errors(); events != nil || errs != nil; {
+				select {
+				case ev, ok := <-events:
+					if ok {
+						t.Fatalf("event %v from a cancelled watcher", ev)
+					}
+					events = nil
+				case err, ok := <-errs:
+					if ok {
+						t.Fatalf("error %v from a cancelled watcher", err)
+					}
+					errs = nil
+				case <-deadline:
+					t.Fatal("channels of a cancelled watcher not closed within 1s")
+				}
+			}
+			w.Stop()
+		})
+	}
+}
+
+// TestAsyncInitialEventReturnsImmediately checks that with
+// WithAsyncInitialEvent NewWatcher does not wait for the initial
+// evaluation: the evaluator blocks until after NewWatcher has returned.
+func TestAsyncInitialEventReturnsImmediately(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	release := make(chan struct{})
+	evaluator := func(ctx context.Context) (bool, string, error) {
+		select {
+		case <-release:
+			return true, "released", nil
+		case <-ctx.Done():
+			return false, "cancelled", ctx.Err()
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	begin := time.Now()
+	w := netonline.NewWatcher(ctx, append(m.Options(), netonline.WithCustomEvaluator(evaluator), netonline.WithAsyncInitialEvent())...)
+	if d := time.Since(begin); d > 100*time.Millisecond {
+		t.Errorf("NewWatcher took %v with a blocked evaluator, want it to return immediately", d)
+	}
+	defer w.Stop()
+	noPendingEvent(t, w.Events())
+	close(release)
+	if ev := nextEvent(t, w.Events()); !ev.Online || ev.Cause != netonline.CauseInitial {
+		t.Fatalf("initial event %v, want online from the released evaluator", ev)
+	}
+}
+
+// TestNoDuplicateStates drives a watcher through repeated evaluations of
+// the same state, OS events and heartbeats included, on a fake clock: with
+// WithEmitOnlyTransitions no two events in a row may report the same
+// state, and every change must still be reported once.
+func TestNoDuplicateStates(t *testing.T) {
+	m := netonlinetesting.NewMockPlatform()
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := append(m.Options(), netonline.WithEmitOnlyTransitions(), netonline.WithHeartbeatInterval(time.Second), netonline.WithEventBufferSize(32))
+	w := netonline.NewWatcher(ctx, opts...)
+	defer func() {
+		cancel()
+		w.Stop()
+	}()
+	events := w.Events()
+
+	var got []bool
+	collect := func() {
+		for {
+			select {
+			case ev := <-events:
+				got = append(got, ev.Online)
+			default:
+				return
+			}
+		}
+	}
+	states := []bool{false, false, true, true, true, false, false, true, true}
+	var want []bool
+	last := false // the suppressed initial state
+	for _, online := range states {
+		change(m, online)
+		m.Advance(time.Second) // a heartbeat, which repeats the state
+		collect()
+		if online != last {
+			want = append(want, online)
+			last = online
+		}
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Fatalf("events %v repeat a state at %d", got, i)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+}
+
+// TestChannelSemanticsFullBuffer checks which events a watcher drops when
+// nobody reads its single-slot channel: the new ones under OrderedFIFO,
+// the buffered ones under LatestWins, counting them either way.
+func TestChannelSemanticsFullBuffer(t *testing.T) {
+	tests := []struct {
+		name      string
+		semantics netonline.ChannelSemantics
+		kept      bool // online state of the event left in the channel
+	}{
+		{"OrderedFIFO keeps the oldest", netonline.OrderedFIFO, true},
+		{"LatestWins keeps the newest", netonline.LatestWins, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := netonlinetesting.NewMockPlatform()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			w := netonline.NewWatcher(ctx, append(m.Options(), netonline.WithChannelSemantics(tt.semantics))...)
+			defer w.Stop()
+			if ev := nextEvent(t, w.Events()); ev.Online {
+				t.Fatalf("initial event %v, want offline", ev)
+			}
+
+			// Each change returns once the watcher has handled it, so a
+			// watcher waiting on the full channel would hang the test.
+			for _, online := range []bool{true, false, true, false} {
+				change(m, online)
+			}
+			if ev := nextEvent(t, w.Events()); ev.Online != tt.kept {
+				t.Errorf("buffered event %v, want online %v", ev, tt.kept)
+			}
+			noPendingEvent(t, w.Events())
+			if got := w.DroppedEvents(); got != 3 {
+				t.Errorf("DroppedEvents() = %d, want 3", got)
+			}
+			if got := w.LifetimeStats().DroppedEvents; got != 3 {
+				t.Errorf("LifetimeStats().DroppedEvents = %d, want 3", got)
+			}
+
+			// The watcher kept going: the next change gets through.
+			change(m, true)
+			if ev := nextEvent(t, w.Events()); !ev.Online {
+				t.Errorf("event after the drops %v, want online", ev)
+			}
+		})
+	}
+}
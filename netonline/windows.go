@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 	"sync/atomic"
 	"unsafe"
 
@@ -22,6 +23,10 @@ var (
 	procCancelMibChangeNotify2  = iphlpapi.NewProc("CancelMibChangeNotify2")
 	procGetAdaptersAddresses    = iphlpapi.NewProc("GetAdaptersAddresses")
 	procGetBestInterfaceEx      = iphlpapi.NewProc("GetBestInterfaceEx")
+	procGetIfEntry2             = iphlpapi.NewProc("GetIfEntry2")
+
+	procGetNetworkConnectivityHint          = iphlpapi.NewProc("GetNetworkConnectivityHint")
+	procNotifyNetworkConnectivityHintChange = iphlpapi.NewProc("NotifyNetworkConnectivityHintChange")
 )
 
 const (
@@ -32,6 +37,12 @@ const (
 	GAA_FLAG_SKIP_ANYCAST     = 0x2
 	GAA_FLAG_SKIP_MULTICAST   = 0x4
 	GAA_FLAG_INCLUDE_GATEWAYS = 0x80
+
+	netIfAdminStatusDown = 2
+
+	ifTypePropVirtual = 53
+	ifTypeWWANPP      = 243
+	ifTypeWWANPP2     = 244
 )
 
 // Subset of IP_ADAPTER_ADDRESSES with fields we actually read.
@@ -41,10 +52,10 @@ type ipAdapterAddresses struct {
 	IfIndex               uint32
 	Next                  *ipAdapterAddresses
 	AdapterName           *byte
-	FirstUnicastAddress   uintptr
+	FirstUnicastAddress   *ipAdapterUnicastAddress
 	FirstAnycastAddress   uintptr
 	FirstMulticastAddress uintptr
-	FirstDnsServerAddress *socketAddress
+	FirstDnsServerAddress *ipAdapterDNSServerAddress
 	DnsSuffix             *uint16
 	Description           *uint16
 	FriendlyName          *uint16
@@ -59,11 +70,52 @@ type ipAdapterAddresses struct {
 	FirstGatewayAddress   *socketAddress
 }
 
+// IP_ADAPTER_UNICAST_ADDRESS_LH
+type ipAdapterUnicastAddress struct {
+	Length             uint32
+	Flags              uint32
+	Next               *ipAdapterUnicastAddress
+	Address            socketAddress
+	PrefixOrigin       int32
+	SuffixOrigin       int32
+	DadState           int32
+	ValidLifetime      uint32
+	PreferredLifetime  uint32
+	LeaseLifetime      uint32
+	OnLinkPrefixLength uint8
+}
+
+// IP_ADAPTER_DNS_SERVER_ADDRESS_XP
+type ipAdapterDNSServerAddress struct {
+	Length   uint32
+	Reserved uint32
+	Next     *ipAdapterDNSServerAddress
+	Address  socketAddress
+}
+
 type socketAddress struct {
 	Sockaddr *windows.RawSockaddrAny
 	Len      int32
 }
 
+// ip decodes the referenced sockaddr, returning nil for non-IP families.
+func (s socketAddress) ip() net.IP {
+	if s.Sockaddr == nil {
+		return nil
+	}
+	switch s.Sockaddr.Addr.Family {
+	case AF_INET:
+		sa := (*windows.RawSockaddrInet4)(unsafe.Pointer(s.Sockaddr))
+		return net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3])
+	case AF_INET6:
+		sa := (*windows.RawSockaddrInet6)(unsafe.Pointer(s.Sockaddr))
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa.Addr[:])
+		return ip
+	}
+	return nil
+}
+
 // sockaddr_in for GetBestInterfaceEx (IPv4)
 type sockaddrIn struct {
 	Family uint16 // AF_INET
@@ -81,16 +133,34 @@ type sockaddrIn6 struct {
 	ScopeId  uint32
 }
 
-func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
+func startOSEventStream(ctx context.Context, cfg *config) (<-chan osEvent, <-chan error) {
 	out := make(chan osEvent, 8)
 	errc := make(chan error, 1)
 
 	var stopped uint32 // 0 = running, 1 = stopping/stopped
 
+	family := uintptr(AF_UNSPEC)
+	switch cfg.family {
+	case FamilyIPv4:
+		family = AF_INET
+	case FamilyIPv6:
+		family = AF_INET6
+	}
+
 	go func() {
 		defer close(out)
 		defer close(errc)
 
+		// The MIB change notifications exist from Windows Vista (6.0) on;
+		// calling a missing procedure would panic.
+		if v := windows.RtlGetVersion(); v.MajorVersion < 6 || procNotifyIpInterfaceChange.Find() != nil || procNotifyRouteChange2.Find() != nil {
+			errc <- &WatchError{Err: fmt.Errorf("%w: change notifications need Windows Vista or later, running %d.%d build %d", ErrWin32API, v.MajorVersion, v.MinorVersion, v.BuildNumber), Kind: ErrKindOSSocket}
+			return
+		}
+		if cfg.logger != nil {
+			cfg.logger.Debug("os event stream subscribed", "notifications", "NotifyIpInterfaceChange,NotifyRouteChange2", "family", int(family))
+		}
+
 		var hIf, hRt handle
 
 		send := func(reason string) {
@@ -108,11 +178,11 @@ func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
 			send("ip interface change")
 			return 0 // NO_ERROR
 		})
-		r1, _, e1 := procNotifyIpInterfaceChange.Call(
-			uintptr(AF_UNSPEC), ifcb, 0, uintptr(1), uintptr(unsafe.Pointer(&hIf)),
+		r1, _, _ := procNotifyIpInterfaceChange.Call(
+			family, ifcb, 0, uintptr(1), uintptr(unsafe.Pointer(&hIf)),
 		)
 		if r1 != 0 {
-			errc <- fmt.Errorf("NotifyIpInterfaceChange failed: %v", e1)
+			errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("%w: NotifyIpInterfaceChange: %w", ErrWin32API, windows.Errno(r1)))
 			return
 		}
 
@@ -121,16 +191,24 @@ func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
 			send("route change")
 			return 0 // NO_ERROR
 		})
-		r2, _, e2 := procNotifyRouteChange2.Call(
-			uintptr(AF_UNSPEC), rtcb, 0, uintptr(1), uintptr(unsafe.Pointer(&hRt)),
+		r2, _, _ := procNotifyRouteChange2.Call(
+			family, rtcb, 0, uintptr(1), uintptr(unsafe.Pointer(&hRt)),
 		)
 		if r2 != 0 {
 			// Cleanup the first subscription before exiting
 			_, _, _ = procCancelMibChangeNotify2.Call(uintptr(hIf))
-			errc <- fmt.Errorf("NotifyRouteChange2 failed: %v", e2)
+			errc <- newWatchError(ErrKindOSSocket, fmt.Errorf("%w: NotifyRouteChange2: %w", ErrWin32API, windows.Errno(r2)))
 			return
 		}
 
+		// Connectivity hint changes (Windows 10 2004+) often arrive before
+		// the interface and route notifications settle. They supplement the
+		// two subscriptions above, so older systems simply go without.
+		hHint, hintErr := winStartConnectivityHintWatcher(send)
+		if hintErr == nil {
+			defer procCancelMibChangeNotify2.Call(uintptr(hHint))
+		}
+
 		// Wait for cancellation, then tear down subscriptions *before* returning,
 		// so callbacks can no longer enqueue events.
 		<-ctx.Done()
@@ -142,240 +220,455 @@ func startOSEventStream(ctx context.Context) (<-chan osEvent, <-chan error) {
 	return out, errc
 }
 
-func recomputeOnline() (bool, string, error) {
-	// Primary path: gateway from GAAs (works on many NICs)
-	hasDef, ifn, err := winDefaultRouteAndIface()
+func recomputeOnline(cfg *config) (netState, error) {
+	// Fast path: Windows already knows there is no connectivity at all.
+	if level, _, err := winConnectivityHint(); err == nil && level == nlConnectivityLevelNone {
+		return netState{why: "connectivity hint: none"}, nil
+	}
+
+	// Everything below is read from a single GetAdaptersAddresses call.
+	// Interfaces are tracked by index, which survives a rename, and only
+	// resolved to a name here.
+	snap, err := winAdapterSnapshot()
 	if err != nil {
-		return false, "default route check failed", err
+		return netState{why: "default route check failed"}, err
 	}
 
+	// Primary path: gateway from GAAs (works on many NICs).
+	ifIdx, disabledIdx := snap.defaultRoute()
+
 	// Fallback path: if gateway not surfaced by GAAs, ask the routing engine
-	if !hasDef || ifn == "" {
-		ifn2, ok := winDefaultRouteViaBestInterface()
-		if ok {
-			ifn = ifn2
-			hasDef = true
-		}
+	if ifIdx == 0 {
+		ifIdx = winDefaultRouteViaBestInterface()
 	}
 
-	if hasDef && ifn != "" {
-		ifi, err := net.InterfaceByName(ifn)
-		if err != nil || (ifi.Flags&net.FlagUp) == 0 || (ifi.Flags&net.FlagLoopback) != 0 {
-			return false, "default iface down/loopback", nil
+	if ifIdx != 0 {
+		a := snap.byIndex(ifIdx)
+		if a == nil || a.ifType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			return netState{why: "default iface down/loopback"}, nil
+		}
+		st := netState{iface: a.name, index: int(a.index), kind: a.kind()}
+		if st.why = cfg.ifaceFilteredKind(a.name, st.kind); st.why != "" {
+			return st, nil
 		}
-		if !ifaceHasUsableAddr(ifn) {
-			return false, "default iface has no usable IP", nil
+		if !a.up {
+			st.why = "default iface down/loopback"
+			return st, nil
 		}
-		if !winHasDNS() {
-			return false, "no DNS resolver", nil
+		addr, ok := a.usableAddr(cfg.preferStable)
+		if !ok {
+			st.why = "default iface has no usable IP"
+			return st, nil
 		}
-		return true, "default via " + ifn, nil
+		st.addr = addr
+		if !snap.dns {
+			st.why = "no DNS resolver"
+			return st, nil
+		}
+		st.online, st.why = true, "default via "+a.name
+		return st, nil
 	}
 
 	// Last resort: operational interface with global unicast (covers ICS/bridge, some VPNs)
-	alt, ok := winPickUpGlobalInterface()
-	if !ok {
-		return false, "no default route", nil
+	alt := snap.upGlobalInterface()
+	if alt == nil {
+		if a := snap.byIndex(disabledIdx); a != nil {
+			return netState{why: "default iface administratively disabled", iface: a.name, index: int(a.index)}, nil
+		}
+		return netState{why: "no default route"}, nil
+	}
+	st := netState{iface: alt.name, index: int(alt.index), kind: alt.kind()}
+	if why := cfg.ifaceFilteredKind(alt.name, st.kind); why != "" {
+		st.why = "fallback: " + why
+		return st, nil
 	}
-	if !winHasDNS() {
-		return false, "no DNS resolver", nil
+	if !snap.dns {
+		st.why = "no DNS resolver"
+		return st, nil
 	}
-	return true, "fallback: up iface " + alt, nil
+	st.online, st.why = true, "fallback: up iface "+alt.name
+	return st, nil
 }
 
 // -------------------- Default route detection helpers --------------------
 
-func winDefaultRouteAndIface() (bool, string, error) {
-	var size uint32 = 15 * 1024
-	for i := 0; i < 3; i++ {
-		buf := make([]byte, size)
-		r0, _, _ := procGetAdaptersAddresses.Call(
-			uintptr(windows.AF_UNSPEC),
-			uintptr(GAA_FLAG_INCLUDE_GATEWAYS|GAA_FLAG_SKIP_ANYCAST|GAA_FLAG_SKIP_MULTICAST),
-			0,
-			uintptr(unsafe.Pointer(&buf[0])),
-			uintptr(unsafe.Pointer(&size)),
-		)
-		if r0 == uintptr(windows.ERROR_BUFFER_OVERFLOW) {
-			continue // grow/retry
+// winAdapter is the part of an IP_ADAPTER_ADDRESSES record the passive
+// check needs, copied out of the GetAdaptersAddresses buffer.
+type winAdapter struct {
+	index, ipv6Index uint32
+	name             string // FriendlyName, as in net.Interface.Name
+	description      string
+	ifType           uint32
+	operStatus       uint32
+	up               bool // IfOperStatusUp
+	gateway          bool
+	addrs            []winUnicastAddr
+}
+
+type winUnicastAddr struct {
+	ip        net.IP
+	temporary bool // IPv6 privacy address
+}
+
+// winAdapters is one GetAdaptersAddresses snapshot, with the DNS check
+// already done over it.
+type winAdapters struct {
+	list []winAdapter
+	dns  bool // see adaptersHaveDNS
+}
+
+// winAdapterSnapshot lists the adapters, with their gateways, in a single
+// GetAdaptersAddresses call.
+func winAdapterSnapshot() (*winAdapters, error) {
+	head, err := winAdapterAddresses(GAA_FLAG_INCLUDE_GATEWAYS | GAA_FLAG_SKIP_ANYCAST | GAA_FLAG_SKIP_MULTICAST)
+	if err != nil {
+		return nil, err
+	}
+	snap := &winAdapters{dns: adaptersHaveDNS(head)}
+	for aa := head; aa != nil; aa = aa.Next {
+		a := winAdapter{
+			index:       aa.IfIndex,
+			ipv6Index:   aa.Ipv6IfIndex,
+			name:        windows.UTF16PtrToString(aa.FriendlyName),
+			description: windows.UTF16PtrToString(aa.Description),
+			ifType:      aa.IfType,
+			operStatus:  aa.OperStatus,
+			up:          aa.OperStatus == 1,
+			gateway:     aa.FirstGatewayAddress != nil,
 		}
-		if r0 != 0 {
-			return false, "", fmt.Errorf("GetAdaptersAddresses error %d", r0)
+		for ua := aa.FirstUnicastAddress; ua != nil; ua = ua.Next {
+			if ip := ua.Address.ip(); ip != nil {
+				a.addrs = append(a.addrs, winUnicastAddr{ip: ip, temporary: ua.SuffixOrigin == windows.IpSuffixOriginRandom})
+			}
 		}
-		head := (*ipAdapterAddresses)(unsafe.Pointer(&buf[0]))
-		for aa := head; aa != nil; aa = aa.Next {
-			if aa.OperStatus != 1 { // IfOperStatusUp
-				continue
+		snap.list = append(snap.list, a)
+	}
+	return snap, nil
+}
+
+// byIndex returns the adapter with IPv4 or IPv6 interface index idx, or nil.
+func (s *winAdapters) byIndex(idx uint32) *winAdapter {
+	if idx == 0 {
+		return nil
+	}
+	for i := range s.list {
+		if a := &s.list[i]; a.index == idx || a.ipv6Index == idx {
+			return a
+		}
+	}
+	return nil
+}
+
+// defaultRoute returns the index of the first adapter that is up and has a
+// gateway. Without one, disabled is the index of an adapter that is down
+// because it was administratively disabled, for the reason reported
+// offline: preferably one that still lists a gateway, otherwise an Ethernet
+// or WiFi adapter, since disabling one usually drops its addresses and
+// gateway too.
+func (s *winAdapters) defaultRoute() (ifIdx, disabled uint32) {
+	disabledGW := false
+	for i := range s.list {
+		a := &s.list[i]
+		if a.ifType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			continue
+		}
+		if a.up {
+			if a.gateway {
+				return a.index, 0
 			}
-			ifi, _ := net.InterfaceByIndex(int(aa.IfIndex))
-			if ifi == nil || (ifi.Flags&net.FlagLoopback) != 0 {
+			continue
+		}
+		// Only the interface row tells a disabled adapter from an
+		// unplugged one, so it is read just for the candidates.
+		physical := a.ifType == windows.IF_TYPE_ETHERNET_CSMACD || a.ifType == windows.IF_TYPE_IEEE80211
+		if a.operStatus == 2 && !disabledGW && (a.gateway || (physical && disabled == 0)) && winAdapterAdminState(a.index) { // IfOperStatusDown
+			disabled, disabledGW = a.index, a.gateway
+		}
+	}
+	return 0, disabled
+}
+
+// upGlobalInterface returns the first adapter that is up, not loopback and
+// has an IPv4 or non-link-local IPv6 address, or nil.
+func (s *winAdapters) upGlobalInterface() *winAdapter {
+	for i := range s.list {
+		a := &s.list[i]
+		if !a.up || a.ifType == windows.IF_TYPE_SOFTWARE_LOOPBACK {
+			continue
+		}
+		for _, ua := range a.addrs {
+			if ua.ip.IsLoopback() || ua.ip.IsUnspecified() {
 				continue
 			}
-			if aa.FirstGatewayAddress != nil {
-				if ifi != nil {
-					return true, ifi.Name, nil
-				}
-				return true, "", nil
+			// IPv6: accept non-link-local as "global" enough for our passive gate.
+			if ua.ip.To4() != nil || !ua.ip.IsLinkLocalUnicast() {
+				return a
 			}
 		}
-		return false, "", nil
 	}
-	return false, "", nil
+	return nil
+}
+
+// winAdapterAdminState reports whether the adapter with index ifIndex is
+// administratively disabled (MIB_IF_ROW2.AdminStatus is
+// netIfAdminStatusDown), as opposed to operationally down, for example
+// unplugged. Both have OperStatus IfOperStatusDown, but only the latter is
+// expected to recover by itself.
+func winAdapterAdminState(ifIndex uint32) bool {
+	if procGetIfEntry2.Find() != nil {
+		return false
+	}
+	row := windows.MibIfRow2{InterfaceIndex: ifIndex}
+	if r0, _, _ := procGetIfEntry2.Call(uintptr(unsafe.Pointer(&row))); r0 != 0 {
+		return false
+	}
+	return row.AdminStatus == netIfAdminStatusDown
 }
 
 // Route-engine fallback: ask Windows which interface it would use to reach well-known destinations.
 // Try IPv6 first (in case of v6-only), then IPv4.
-func winDefaultRouteViaBestInterface() (string, bool) {
+func winDefaultRouteViaBestInterface() uint32 {
 	// v6 target: 2606:4700:4700::1111 (Cloudflare)
 	var sa6 sockaddrIn6
 	sa6.Family = AF_INET6
 	sa6.Addr = [16]byte{0x26, 0x06, 0x47, 0x00, 0x47, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x11, 0x11}
-	if ifn, ok := winBestInterfaceName((*windows.RawSockaddrAny)(unsafe.Pointer(&sa6))); ok {
-		return ifn, true
+	if idx := winBestInterface((*windows.RawSockaddrAny)(unsafe.Pointer(&sa6))); idx != 0 {
+		return idx
 	}
 
 	// v4 target: 1.1.1.1
 	var sa4 sockaddrIn
 	sa4.Family = AF_INET
 	sa4.Addr = [4]byte{1, 1, 1, 1}
-	if ifn, ok := winBestInterfaceName((*windows.RawSockaddrAny)(unsafe.Pointer(&sa4))); ok {
-		return ifn, true
-	}
-	return "", false
+	return winBestInterface((*windows.RawSockaddrAny)(unsafe.Pointer(&sa4)))
 }
 
-func winBestInterfaceName(dst *windows.RawSockaddrAny) (string, bool) {
+// winBestInterface returns the index of the interface the routing engine
+// would use to reach dst, or 0. The caller rules out loopback.
+func winBestInterface(dst *windows.RawSockaddrAny) uint32 {
 	var idx uint32
 	r0, _, _ := procGetBestInterfaceEx.Call(
 		uintptr(unsafe.Pointer(dst)),
 		uintptr(unsafe.Pointer(&idx)),
 	)
-	if r0 != 0 || idx == 0 {
-		return "", false
-	}
-	ifi, err := net.InterfaceByIndex(int(idx))
-	if err != nil || ifi == nil || (ifi.Flags&net.FlagLoopback) != 0 {
-		return "", false
+	if r0 != 0 {
+		return 0
 	}
-	return ifi.Name, true
+	return idx
 }
 
-// -------------------- DNS / Interface helpers --------------------
+// -------------------- Connectivity hint --------------------
 
-func winHasDNS() bool {
-	var size uint32 = 12 * 1024
-	buf := make([]byte, size)
-	r0, _, _ := procGetAdaptersAddresses.Call(
-		uintptr(windows.AF_UNSPEC),
-		0, // include DNS info
-		0,
-		uintptr(unsafe.Pointer(&buf[0])),
-		uintptr(unsafe.Pointer(&size)),
-	)
-	if r0 == uintptr(windows.ERROR_BUFFER_OVERFLOW) {
+// NL_NETWORK_CONNECTIVITY_LEVEL_HINT and NL_NETWORK_CONNECTIVITY_COST_HINT
+// values.
+const (
+	nlConnectivityLevelUnknown     = 0
+	nlConnectivityLevelNone        = 1
+	nlConnectivityLevelLocalAccess = 2
+	nlConnectivityLevelInternet    = 3
+	nlConnectivityLevelConstrained = 4
+	nlConnectivityLevelHidden      = 5
+
+	nlConnectivityCostUnknown      = 0
+	nlConnectivityCostUnrestricted = 1
+	nlConnectivityCostFixed        = 2
+	nlConnectivityCostVariable     = 3
+)
+
+// connectivityHintMinBuild is the first build (Windows 10 2004) with the
+// connectivity hint APIs.
+const connectivityHintMinBuild = 19041
+
+// NL_NETWORK_CONNECTIVITY_HINT
+type nlNetworkConnectivityHint struct {
+	ConnectivityLevel    int32
+	ConnectivityCost     int32
+	ApproachingDataLimit byte
+	OverDataLimit        byte
+	Roaming              byte
+}
+
+func connectivityHintSupported() bool {
+	v := windows.RtlGetVersion()
+	if v.MajorVersion < 10 || v.BuildNumber < connectivityHintMinBuild {
 		return false
 	}
-	if r0 != 0 {
-		return false
+	return procGetNetworkConnectivityHint.Find() == nil
+}
+
+// winConnectivityHint returns the system-wide connectivity level and cost
+// from GetNetworkConnectivityHint (Windows 10 2004 and later).
+func winConnectivityHint() (level, cost uint32, err error) {
+	if !connectivityHintSupported() {
+		return 0, 0, fmt.Errorf("%w: GetNetworkConnectivityHint needs Windows 10 build %d or later", ErrWin32API, connectivityHintMinBuild)
+	}
+	var hint nlNetworkConnectivityHint
+	if r0, _, _ := procGetNetworkConnectivityHint.Call(uintptr(unsafe.Pointer(&hint))); r0 != 0 {
+		return 0, 0, fmt.Errorf("%w: GetNetworkConnectivityHint: %w", ErrWin32API, windows.Errno(r0))
+	}
+	return uint32(hint.ConnectivityLevel), uint32(hint.ConnectivityCost), nil
+}
+
+// platformMetered reads the system-wide connectivity cost hint, which
+// describes the preferred connection rather than ifname: a fixed or
+// variable cost counts as metered.
+func platformMetered(cfg *config, ifname string) *bool {
+	_, cost, err := winConnectivityHint()
+	if err != nil {
+		return nil
+	}
+	var metered bool
+	switch cost {
+	case nlConnectivityCostFixed, nlConnectivityCostVariable:
+		metered = true
+	case nlConnectivityCostUnrestricted:
+		metered = false
+	default:
+		return nil
 	}
-	head := (*ipAdapterAddresses)(unsafe.Pointer(&buf[0]))
+	return &metered
+}
+
+// winStartConnectivityHintWatcher registers send for connectivity hint
+// changes. The returned handle is released with CancelMibChangeNotify2.
+func winStartConnectivityHintWatcher(send func(reason string)) (handle, error) {
+	if !connectivityHintSupported() || procNotifyNetworkConnectivityHintChange.Find() != nil {
+		return 0, fmt.Errorf("%w: NotifyNetworkConnectivityHintChange needs Windows 10 build %d or later", ErrWin32API, connectivityHintMinBuild)
+	}
+	// The hint argument is a small struct; the callback only needs to know
+	// that it changed.
+	cb := windows.NewCallback(func(callerCtx uintptr, hint uintptr) uintptr {
+		send("connectivity hint change")
+		return 0
+	})
+	var h handle
+	if r0, _, _ := procNotifyNetworkConnectivityHintChange.Call(cb, 0, 0, uintptr(unsafe.Pointer(&h))); r0 != 0 {
+		return 0, fmt.Errorf("%w: NotifyNetworkConnectivityHintChange: %w", ErrWin32API, windows.Errno(r0))
+	}
+	return h, nil
+}
+
+// -------------------- DNS / Interface helpers --------------------
+
+// adaptersHaveDNS reports whether any adapter that is up lists a usable DNS
+// server. Down adapters can still carry servers from a previous connection,
+// and 169.254.x.x servers are APIPA stubs that never answer.
+func adaptersHaveDNS(head *ipAdapterAddresses) bool {
 	for aa := head; aa != nil; aa = aa.Next {
-		if aa.FirstDnsServerAddress != nil {
+		if aa.OperStatus != 1 { // IfOperStatusUp
+			continue
+		}
+		for ds := aa.FirstDnsServerAddress; ds != nil; ds = ds.Next {
+			ip := ds.Address.ip()
+			if ip == nil || ip.IsUnspecified() {
+				continue
+			}
+			if v4 := ip.To4(); v4 != nil && v4.IsLinkLocalUnicast() {
+				continue
+			}
 			return true
 		}
 	}
 	return false
 }
 
-func winPickUpGlobalInterface() (string, bool) {
-	var size uint32 = 16 * 1024
-	buf := make([]byte, size)
-	r0, _, _ := procGetAdaptersAddresses.Call(
-		uintptr(windows.AF_UNSPEC),
-		0,
-		0,
-		uintptr(unsafe.Pointer(&buf[0])),
-		uintptr(unsafe.Pointer(&size)),
-	)
-	if r0 == uintptr(windows.ERROR_BUFFER_OVERFLOW) {
-		return "", false
-	}
-	if r0 != 0 {
-		return "", false
-	}
-	head := (*ipAdapterAddresses)(unsafe.Pointer(&buf[0]))
-	for aa := head; aa != nil; aa = aa.Next {
-		if aa.OperStatus != 1 {
+// usableAddr returns the adapter's preferred usable address. Stable
+// addresses win over IPv6 temporary (privacy) addresses; with preferStable
+// set, an adapter that only has temporary addresses is reported as having
+// none.
+func (a *winAdapter) usableAddr(preferStable bool) (string, bool) {
+	temporary := ""
+	for _, ua := range a.addrs {
+		ip := ua.ip
+		if ip.IsLoopback() || ip.IsUnspecified() {
 			continue
 		}
-		ifi, _ := net.InterfaceByIndex(int(aa.IfIndex))
-		if ifi == nil || (ifi.Flags&net.FlagLoopback) != 0 {
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
 			continue
 		}
-		if ifHasGlobalUnicast(ifi) {
-			return ifi.Name, true
+		if ua.temporary {
+			if temporary == "" {
+				temporary = ip.String()
+			}
+			continue
 		}
+		return ip.String(), true
+	}
+	if temporary != "" && !preferStable {
+		return temporary, true
 	}
 	return "", false
 }
 
-func ifHasGlobalUnicast(ifi *net.Interface) bool {
-	addrs, err := ifi.Addrs()
+// interfaceKind classifies the adapter named ifname by its IANA ifType.
+func interfaceKind(ifname string) InterfaceKind {
+	snap, err := winAdapterSnapshot()
 	if err != nil {
-		return false
+		return InterfaceOther
 	}
-	for _, a := range addrs {
-		var ip net.IP
-		switch v := a.(type) {
-		case *net.IPNet:
-			ip = v.IP
-		case *net.IPAddr:
-			ip = v.IP
-		}
-		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
-			continue
+	for i := range snap.list {
+		if a := &snap.list[i]; a.name == ifname {
+			return a.kind()
 		}
-		if v4 := ip.To4(); v4 != nil {
-			return true
+	}
+	return InterfaceOther
+}
+
+// kind classifies a by its IANA ifType.
+func (a *winAdapter) kind() InterfaceKind {
+	switch a.ifType {
+	case windows.IF_TYPE_ETHERNET_CSMACD:
+		if winVirtualAdapter(a.description) {
+			return InterfaceVirtual
 		}
-		// IPv6: accept non-link-local as "global" enough for our passive gate.
-		if !ip.IsLinkLocalUnicast() {
+		return InterfaceEthernet
+	case windows.IF_TYPE_SOFTWARE_LOOPBACK:
+		return InterfaceLoopback
+	case windows.IF_TYPE_IEEE80211:
+		return InterfaceWiFi
+	case ifTypeWWANPP, ifTypeWWANPP2:
+		return InterfaceCellular
+	case windows.IF_TYPE_TUNNEL, windows.IF_TYPE_PPP, ifTypePropVirtual:
+		return InterfaceTunnel
+	}
+	return InterfaceOther
+}
+
+// winVirtualAdapter reports whether an Ethernet adapter with the given
+// description is emulated by a hypervisor, going by the descriptions
+// Hyper-V, VirtualBox and VMware give their host-side adapters.
+func winVirtualAdapter(desc string) bool {
+	for _, s := range []string{"Hyper-V Virtual", "VirtualBox", "VMware Virtual"} {
+		if strings.Contains(desc, s) {
 			return true
 		}
 	}
 	return false
 }
 
-func ifaceHasUsableAddr(ifname string) bool {
-	ifi, err := net.InterfaceByName(ifname)
-	if err != nil {
-		return false
-	}
-	addrs, err := ifi.Addrs()
-	if err != nil {
-		return false
-	}
-	for _, a := range addrs {
-		var ip net.IP
-		switch v := a.(type) {
-		case *net.IPNet:
-			ip = v.IP
-		case *net.IPAddr:
-			ip = v.IP
-		}
-		if ip == nil || ip.IsLoopback() {
-			continue
+// winAdapterAddresses calls GetAdaptersAddresses, growing the buffer as
+// requested, and returns the head of the adapter list (nil if there are none).
+func winAdapterAddresses(flags uint32) (*ipAdapterAddresses, error) {
+	var size uint32 = 15 * 1024
+	for i := 0; i < 3; i++ {
+		buf := make([]byte, size)
+		r0, _, _ := procGetAdaptersAddresses.Call(
+			uintptr(windows.AF_UNSPEC),
+			uintptr(flags),
+			0,
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(unsafe.Pointer(&size)),
+		)
+		if r0 == uintptr(windows.ERROR_BUFFER_OVERFLOW) {
+			continue // grow/retry
 		}
-		if v4 := ip.To4(); v4 != nil {
-			if !v4.IsUnspecified() {
-				return true
-			}
-			continue
+		if r0 == uintptr(windows.ERROR_NO_DATA) {
+			return nil, nil
 		}
-		if ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
-			continue
+		if r0 != 0 {
+			return nil, fmt.Errorf("%w: GetAdaptersAddresses: %w", ErrWin32API, windows.Errno(r0))
 		}
-		return true
+		return (*ipAdapterAddresses)(unsafe.Pointer(&buf[0])), nil
 	}
-	return false
+	return nil, fmt.Errorf("%w: GetAdaptersAddresses: buffer still too small after retries", ErrWin32API)
 }
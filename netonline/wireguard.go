@@ -0,0 +1,80 @@
+package netonline
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wgHandshakeMaxAge is how old the latest peer handshake may be before a
+// WireGuard default interface counts as down. WireGuard renews sessions
+// every two minutes while traffic flows and rejects them after three
+// (REJECT_AFTER_TIME).
+const wgHandshakeMaxAge = 180 * time.Second
+
+// WithWireGuardAware makes the passive check look past the route and
+// address of a WireGuard default interface, which stay in place whether or
+// not the tunnel works: the host only counts as online when a peer
+// completed a handshake within the last three minutes. An idle tunnel
+// without persistent keepalives therefore reads as offline. Kernel devices
+// on Linux are queried over generic netlink, which needs CAP_NET_ADMIN;
+// userspace implementations such as wireguard-go (utun* on macOS) through
+// their UAPI socket under /var/run/wireguard, which usually needs root.
+// When neither answers, or on Windows, the interface is treated like any
+// other.
+func WithWireGuardAware(enabled bool) Option {
+	return newOption(func(cfg *config) { cfg.wireGuard = enabled })
+}
+
+// wgLastHandshake returns the most recent peer handshake of ifname, zero if
+// there was none. ok is false when ifname is not a WireGuard interface or
+// cannot be queried.
+func wgLastHandshake(ifname string) (last time.Time, ok bool) {
+	if wgKernelInterface(ifname) {
+		return wgKernelLastHandshake(ifname)
+	}
+	return wgUAPILastHandshake(ifname)
+}
+
+// wgUAPILastHandshake asks a userspace WireGuard implementation over the
+// cross-platform configuration protocol ("get=1") on its UNIX socket.
+func wgUAPILastHandshake(ifname string) (last time.Time, ok bool) {
+	conn, err := net.DialTimeout("unix", "/var/run/wireguard/"+ifname+".sock", time.Second)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte("get=1\n\n")); err != nil {
+		return time.Time{}, false
+	}
+	var sec, nsec int64
+	peerDone := func() {
+		if sec != 0 || nsec != 0 {
+			if t := time.Unix(sec, nsec); t.After(last) {
+				last = t
+			}
+		}
+		sec, nsec = 0, 0
+	}
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		key, val, _ := strings.Cut(sc.Text(), "=")
+		switch key {
+		case "":
+			peerDone()
+			return last, ok
+		case "public_key":
+			peerDone()
+		case "last_handshake_time_sec":
+			sec, _ = strconv.ParseInt(val, 10, 64)
+		case "last_handshake_time_nsec":
+			nsec, _ = strconv.ParseInt(val, 10, 64)
+		case "errno":
+			ok = val == "0"
+		}
+	}
+	return time.Time{}, false
+}
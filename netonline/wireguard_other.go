@@ -0,0 +1,11 @@
+//go:build !linux
+
+package netonline
+
+import "time"
+
+// Kernel WireGuard devices only exist on Linux; elsewhere WireGuard runs in
+// userspace and is queried through its UAPI socket.
+func wgKernelInterface(ifname string) bool { return false }
+
+func wgKernelLastHandshake(ifname string) (time.Time, bool) { return time.Time{}, false }